@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"dualsense/internal/config"
+	"dualsense/internal/service"
+	"dualsense/internal/service/battery"
+	"dualsense/internal/service/bluetooth"
+	"dualsense/internal/service/drift"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func newRumbleCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "rumble [mac]",
+		Short: "Play a rumble test pattern on a controller",
+		Long:  "Play a rumble test pattern (left motor, right motor, then both) on the controller with the given MAC address, or on every connected controller. The rumble_scale setting of the controller is applied first as its motor power limit, which stays in effect and also caps the rumble from games.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			conf, err := config.Load()
+			if err != nil {
+				return err
+			}
+			mac := ""
+			if len(args) == 1 {
+				mac = args[0]
+			}
+			return service.TestRumble(context.Background(), conf, mac)
+		},
+	}
+}
+
+func newPairCmd() *cobra.Command {
+	var timeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "pair",
+		Short: "Pair, trust and connect a new controller",
+		Long:  "Search for a controller in pairing mode (hold PS and Create, or PS and Share on a DualShock 4, until the lightbar flashes), then pair, trust and connect it through BlueZ.",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			mac, err := bluetooth.PairNewController(ctx, func(msg string) {
+				log.Default().Println(msg)
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Println(mac)
+			return nil
+		},
+	}
+	cmd.Flags().DurationVarP(&timeout, "timeout", "t", time.Minute, "How long to search for a controller in pairing mode")
+	return cmd
+}
+
+func newCalibrateCmd() *cobra.Command {
+	var duration time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "calibrate [mac]",
+		Short: "Check the sticks for drift",
+		Long:  "Record the resting position of the sticks of the controller with the given MAC address, or of every connected controller, then print the offset and noise of each axis and the deadzones suppressing them.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			conf, err := config.Load()
+			if err != nil {
+				return err
+			}
+			mac := ""
+			if len(args) == 1 {
+				mac = args[0]
+			}
+
+			fmt.Printf("Put the controller down and leave the sticks at rest for %s...\n", duration)
+			results, err := service.Calibrate(context.Background(), conf, mac, duration)
+			if err != nil {
+				return err
+			}
+			for _, result := range results {
+				printCalibration(os.Stdout, result)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().DurationVarP(&duration, "duration", "t", 10*time.Second, "How long to record the resting sticks")
+	return cmd
+}
+
+func printCalibration(out io.Writer, result service.CalibrationResult) {
+	fmt.Fprintf(out, "\nController %s\n", result.MAC)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Axis\tSamples\tOffset\tNoise\t")
+	for _, axis := range result.Report.Axes {
+		if axis.Samples < drift.MinSamples {
+			fmt.Fprintf(w, "%s\t%d\t-\t-\t\n", axis.Axis, axis.Samples)
+			continue
+		}
+		flag := ""
+		if axis.Drifting {
+			flag = "drift"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%.0f\t%.0f\t%s\n", axis.Axis, axis.Samples, axis.Offset, axis.Noise, flag)
+	}
+	_ = w.Flush()
+
+	printSuggestion(out, "Left stick", result.Report.SuggestedLeft, result.Deadzones.LeftStick.Size)
+	printSuggestion(out, "Right stick", result.Report.SuggestedRight, result.Deadzones.RightStick.Size)
+	fmt.Fprintln(out, result.Report.Summary())
+}
+
+func printSuggestion(out io.Writer, stick string, suggested, current int) {
+	switch {
+	case suggested == 0:
+		fmt.Fprintf(out, "%s: steady, deadzone %d\n", stick, current)
+	case suggested > current:
+		fmt.Fprintf(out, "%s: deadzone %d suggested (currently %d)\n", stick, suggested, current)
+	default:
+		fmt.Fprintf(out, "%s: deadzone %d is enough (%d needed)\n", stick, current, suggested)
+	}
+}
+
+func newInfoCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "info <mac>",
+		Short: "Print the firmware, hardware and calibration information of a controller",
+		Long:  "Read the feature reports of the connected controller with the given MAC address and print its serial, firmware build date and versions, hardware version and factory motion sensor calibration as JSON. The DualShock 4 feature reports are not decoded, it is reported unsupported.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			infos, err := service.Info(args[0])
+			if err != nil {
+				return err
+			}
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			// the same controller connected over USB and Bluetooth reports the same information
+			return enc.Encode(infos[0])
+		},
+	}
+}
+
+func newBatteryCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "battery",
+		Short: "Query the controllers battery",
+	}
+	cmd.AddCommand(newBatteryStatusCmd())
+	cmd.AddCommand(newBatteryHistoryCmd())
+	cmd.AddCommand(newBatteryHealthCmd())
+	return cmd
+}
+
+func newBatteryStatusCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "status [mac]",
+		Short: "Print the battery level and time remaining",
+		Long:  "Print the battery level and charging status of the controller with the given MAC address, or of every connected controller, with the time remaining estimated from the recorded battery history.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			conf, err := config.Load()
+			if err != nil {
+				return err
+			}
+			battery.UseSource(conf.BatterySource)
+			history, err := battery.OpenHistory()
+			if err != nil {
+				return err
+			}
+			mac := ""
+			if len(args) == 1 {
+				mac = args[0]
+			}
+			reports, err := service.BatteryStatus(mac, history)
+			if err != nil {
+				return err
+			}
+			printBatteryStatus(os.Stdout, reports)
+			return nil
+		},
+	}
+}
+
+func printBatteryStatus(out io.Writer, reports []service.BatteryReport) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MAC\tConnection\tLevel\tStatus\tEstimate\t")
+	for _, report := range reports {
+		estimate := "-"
+		if report.Estimated {
+			estimate = report.Estimate.String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d%%\t%s\t%s\t\n", report.MAC, report.Transport, report.Level, report.Status, estimate)
+	}
+	_ = w.Flush()
+}
+
+func newBatteryHistoryCmd() *cobra.Command {
+	var format string
+	var since time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "history <mac>",
+		Short: "Print the battery history of a controller",
+		Long:  "Print the battery level and charging status recorded for the controller with the given MAC address, oldest first.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			history, err := battery.OpenHistory()
+			if err != nil {
+				return err
+			}
+			var from time.Time
+			if since > 0 {
+				from = time.Now().Add(-since)
+			}
+			samples, err := history.Samples(args[0], from)
+			if err != nil {
+				return err
+			}
+
+			switch format {
+			case "csv":
+				return writeBatteryCSV(os.Stdout, samples)
+			case "json":
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if samples == nil {
+					samples = []battery.Sample{}
+				}
+				return enc.Encode(samples)
+			default:
+				return fmt.Errorf("unknown format %q, expected csv or json", format)
+			}
+		},
+	}
+	cmd.Flags().StringVarP(&format, "format", "f", "csv", "Output format: csv or json")
+	cmd.Flags().DurationVarP(&since, "since", "s", 0, "Only print the samples of this last period, e.g. 24h (default everything)")
+	return cmd
+}
+
+func newBatteryHealthCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "health [mac]",
+		Short: "Print the battery wear of the controllers",
+		Long:  "Print the charge cycles and the runtime trend of the controller with the given MAC address, or of every controller seen so far, connected or not.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			health, err := battery.OpenHealth()
+			if err != nil {
+				return err
+			}
+			reports := health.Reports()
+			if len(args) == 1 {
+				report, ok := health.Report(args[0])
+				if !ok {
+					return fmt.Errorf("controller %s never seen", args[0])
+				}
+				reports = []battery.HealthReport{report}
+			}
+			printBatteryHealth(os.Stdout, reports)
+			return nil
+		},
+	}
+}
+
+func printBatteryHealth(out io.Writer, reports []battery.HealthReport) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MAC\tFirst seen\tCharges\tCycles\tRuntime\tTrend\t")
+	for _, report := range reports {
+		runtime, trend := "-", "-"
+		if report.RecentRuntime > 0 {
+			runtime, trend = strings.TrimSuffix(report.RecentRuntime.Round(time.Minute).String(), "0s"), report.Trend()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%.1f\t%s\t%s\t\n", report.MAC, report.FirstSeen.Format(time.DateOnly), report.Charges, report.Cycles, runtime, trend)
+	}
+	_ = w.Flush()
+}
+
+func writeBatteryCSV(out io.Writer, samples []battery.Sample) error {
+	w := csv.NewWriter(out)
+	if err := w.Write([]string{"time", "level", "status"}); err != nil {
+		return err
+	}
+	for _, s := range samples {
+		if err := w.Write([]string{s.Time.Format(time.RFC3339), strconv.Itoa(s.Level), s.Status}); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
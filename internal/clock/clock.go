@@ -0,0 +1,38 @@
+// Package clock abstracts time so that service loops can be driven by tests.
+package clock
+
+import "time"
+
+// Clock tells the time, waits and creates tickers.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	Sleep(d time.Duration)
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker delivers ticks at intervals, like time.Ticker.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Real is the system clock.
+type Real struct{}
+
+// Now returns the current local time.
+func (Real) Now() time.Time { return time.Now() }
+
+// After waits for d to elapse and then sends the current time on the returned channel.
+func (Real) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Sleep pauses the current goroutine for d.
+func (Real) Sleep(d time.Duration) { time.Sleep(d) }
+
+// NewTicker returns a time.Ticker ticking every d.
+func (Real) NewTicker(d time.Duration) Ticker { return realTicker{time.NewTicker(d)} }
+
+type realTicker struct{ t *time.Ticker }
+
+func (r realTicker) C() <-chan time.Time { return r.t.C }
+func (r realTicker) Stop()               { r.t.Stop() }
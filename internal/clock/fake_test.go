@@ -0,0 +1,72 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeTicker(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := NewFake(start)
+
+	ticks := make(chan time.Time, 10)
+	ticker := c.NewTicker(time.Second)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 2; i++ {
+			ticks <- <-ticker.C()
+		}
+	}()
+
+	c.BlockUntil(1)
+	c.Advance(500 * time.Millisecond)
+	c.Advance(500 * time.Millisecond)
+	// several periods at once fire a single tick
+	c.Advance(5 * time.Second)
+	<-done
+
+	if got := <-ticks; !got.Equal(start.Add(time.Second)) {
+		t.Fatalf("first tick at %v", got)
+	}
+	if got := <-ticks; !got.Equal(start.Add(6 * time.Second)) {
+		t.Fatalf("second tick at %v", got)
+	}
+
+	ticker.Stop()
+	// advancing past a stopped ticker must not block
+	c.Advance(time.Minute)
+	if got := c.Now(); !got.Equal(start.Add(66 * time.Second)) {
+		t.Fatalf("Now() = %v", got)
+	}
+}
+
+func TestFakeAfter(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := NewFake(start)
+
+	woke := make(chan struct{})
+	go func() {
+		c.Sleep(3 * time.Second)
+		close(woke)
+	}()
+	after := c.After(time.Second)
+
+	c.BlockUntil(2)
+	c.Advance(time.Second)
+	if got := <-after; !got.Equal(start.Add(time.Second)) {
+		t.Fatalf("After fired at %v", got)
+	}
+	select {
+	case <-woke:
+		t.Fatalf("Sleep returned before its deadline")
+	default:
+	}
+
+	c.Advance(2 * time.Second)
+	<-woke
+
+	if got := <-c.After(0); !got.Equal(start.Add(3 * time.Second)) {
+		t.Fatalf("After(0) = %v", got)
+	}
+}
@@ -13,16 +13,182 @@ import (
 type Config struct {
 	IdleMinutes  int `yaml:"idle_minutes"`
 	BatteryAlert int `yaml:"battery_alert"`
+	// BatteryAlerts replaces the single BatteryAlert threshold when set.
+	BatteryAlerts []BatteryAlert `yaml:"battery_alerts,omitempty"`
+	// NotifyFull sends a notification once a charging controller is full.
+	NotifyFull bool `yaml:"notify_full,omitempty"`
+	// CriticalAction is played on the controller when a critical alert fires.
+	CriticalAction string `yaml:"critical_action,omitempty"`
+	// BatterySource is where the battery is read from, sysfs when empty.
+	BatterySource string `yaml:"battery_source,omitempty"`
+	// MicMute toggles the default audio source mute with the controller mute button,
+	// and mirrors the source mute on the mute LED.
+	MicMute bool `yaml:"mic_mute,omitempty"`
+	// AnimationFPS is the frame rate of the LED animations, the default one when zero.
+	AnimationFPS int `yaml:"animation_fps,omitempty"`
+	// Animations are the user-defined LED animations, keyed by name.
+	Animations map[string]Animation `yaml:"animations,omitempty"`
 	// Per-controller configuration keyed by MAC address
 	Controllers map[string]ControllerConfig `yaml:"controllers,omitempty"`
 }
 
+// BatteryAlert is a low battery threshold, in percent.
+type BatteryAlert struct {
+	Level    int    `yaml:"level"`
+	Severity string `yaml:"severity"`
+}
+
+// Battery alert severities, from the least to the most urgent.
+const (
+	SeverityWarning  = "warning"
+	SeverityAlert    = "alert"
+	SeverityCritical = "critical"
+)
+
+// Animation is a user-defined LED animation, a looping sequence of keyframes.
+type Animation struct {
+	Keyframes []Keyframe `yaml:"keyframes"`
+}
+
+// Keyframe is a step of an animation: the lightbar color ("#RRGGBB") and player
+// LEDs ("10101", leftmost first) shown, and how long and with which easing they
+// blend into the next keyframe.
+type Keyframe struct {
+	Color    string `yaml:"color,omitempty"`
+	Players  string `yaml:"players,omitempty"`
+	Duration string `yaml:"duration"`
+	Easing   string `yaml:"easing,omitempty"`
+}
+
+// Battery sources: the power supply class in sysfs, or the UPower daemon over
+// D-Bus falling back to sysfs.
+const (
+	BatterySourceSysfs  = "sysfs"
+	BatterySourceUPower = "upower"
+)
+
+// Actions played on the controller when a critical battery alert fires.
+const (
+	CriticalActionNone   = "none"
+	CriticalActionFlash  = "flash"
+	CriticalActionRumble = "rumble"
+)
+
+// Alerts returns the low battery thresholds: BatteryAlerts, or the single
+// BatteryAlert threshold with the alert severity. A threshold without severity
+// is an alert.
+func (c *Config) Alerts() []BatteryAlert {
+	if len(c.BatteryAlerts) == 0 {
+		if c.BatteryAlert == 0 {
+			return nil
+		}
+		return []BatteryAlert{{Level: c.BatteryAlert, Severity: SeverityAlert}}
+	}
+	alerts := make([]BatteryAlert, len(c.BatteryAlerts))
+	for i, alert := range c.BatteryAlerts {
+		if alert.Severity == "" {
+			alert.Severity = SeverityAlert
+		}
+		alerts[i] = alert
+	}
+	return alerts
+}
+
 // ControllerConfig holds per-controller configuration overrides.
 type ControllerConfig struct {
-	Deadzone            int    `yaml:"deadzone"`
-	LedPlayerPreference int    `yaml:"led_player"`
-	LedRGBPreference    int    `yaml:"led_indicator"`
-	LedRGBStatic        string `yaml:"led_rgb_static"`
+	// Deadzone is the legacy deadzone shared by every axis, migrated to Deadzones on load.
+	Deadzone            int           `yaml:"deadzone,omitempty"`
+	Deadzones           Deadzones     `yaml:"deadzones,omitempty"`
+	LedPlayerPreference int           `yaml:"led_player"`
+	LedRGBPreference    int           `yaml:"led_indicator"`
+	LedRGBStatic        string        `yaml:"led_rgb_static"`
+	TriggerLeft         TriggerEffect `yaml:"trigger_left,omitempty"`
+	TriggerRight        TriggerEffect `yaml:"trigger_right,omitempty"`
+	RumbleScale         float64       `yaml:"rumble_scale,omitempty"`
+	// LedRGBAnimation is the animation played by the animated RGB mode.
+	LedRGBAnimation string `yaml:"led_rgb_animation,omitempty"`
+	// IdleMinutes overrides the global idle timeout: 0 uses the global value, -1 never times out.
+	IdleMinutes int    `yaml:"idle_minutes,omitempty"`
+	IdlePolicy  string `yaml:"idle_policy,omitempty"`
+}
+
+// Idle policies applied when a controller stays idle past its timeout.
+const (
+	IdlePolicyDisconnect = "disconnect"
+	IdlePolicyDim        = "dim"
+	IdlePolicyNone       = "none"
+)
+
+// IdleNever disables the idle timeout of a controller regardless of the global value.
+const IdleNever = -1
+
+// Deadzone shapes: axial checks each stick axis on its own, radial checks the
+// distance of the stick from its center.
+const (
+	DeadzoneAxial  = "axial"
+	DeadzoneRadial = "radial"
+)
+
+// Deadzones holds the deadzones of the sticks and triggers, in the joystick
+// interface scale (sticks offsets up to 32767, triggers from 0 to 32767).
+type Deadzones struct {
+	LeftStick    StickDeadzone `yaml:"left_stick,omitempty"`
+	RightStick   StickDeadzone `yaml:"right_stick,omitempty"`
+	LeftTrigger  int           `yaml:"left_trigger,omitempty"`
+	RightTrigger int           `yaml:"right_trigger,omitempty"`
+}
+
+// StickDeadzone is the deadzone size and shape of a stick.
+type StickDeadzone struct {
+	Size  int    `yaml:"size,omitempty"`
+	Shape string `yaml:"shape,omitempty"`
+}
+
+// migrateDeadzone moves the legacy single deadzone to every stick and trigger. The
+// legacy deadzone applied to each axis separately, so the sticks keep an axial shape.
+func (cc *ControllerConfig) migrateDeadzone() {
+	if cc.Deadzone == 0 {
+		return
+	}
+	for _, stick := range []*StickDeadzone{&cc.Deadzones.LeftStick, &cc.Deadzones.RightStick} {
+		if stick.Size == 0 {
+			stick.Size = cc.Deadzone
+		}
+		if stick.Shape == "" {
+			stick.Shape = DeadzoneAxial
+		}
+	}
+	if cc.Deadzones.LeftTrigger == 0 {
+		cc.Deadzones.LeftTrigger = cc.Deadzone
+	}
+	if cc.Deadzones.RightTrigger == 0 {
+		cc.Deadzones.RightTrigger = cc.Deadzone
+	}
+	cc.Deadzone = 0
+}
+
+// TriggerEffect configures an adaptive trigger effect. Positions are zones 0-9
+// along the trigger travel and strengths range from 1 to 8.
+type TriggerEffect struct {
+	Mode        string `yaml:"mode,omitempty"`
+	Start       int    `yaml:"start,omitempty"`
+	End         int    `yaml:"end,omitempty"`
+	Strength    int    `yaml:"strength,omitempty"`
+	EndStrength int    `yaml:"end_strength,omitempty"`
+	Frequency   int    `yaml:"frequency,omitempty"`
+}
+
+// IdleTimeout returns the idle timeout in minutes applying to ctrlConf, falling back
+// to the global value. 0 means the controller never times out.
+func (c *Config) IdleTimeout(ctrlConf *ControllerConfig) int {
+	switch {
+	case ctrlConf.IdleMinutes == IdleNever:
+		return 0
+	case ctrlConf.IdleMinutes > 0:
+		return ctrlConf.IdleMinutes
+	default:
+		return c.IdleMinutes
+	}
 }
 
 // ControllerConfig returns the configuration for a specific controller MAC.
@@ -30,9 +196,16 @@ type ControllerConfig struct {
 func (c *Config) ControllerConfig(mac string) *ControllerConfig {
 	// Start with reasonable defaults
 	res := &ControllerConfig{
-		Deadzone:            1500,
+		Deadzones: Deadzones{
+			LeftStick:    StickDeadzone{Size: 1500, Shape: DeadzoneRadial},
+			RightStick:   StickDeadzone{Size: 1500, Shape: DeadzoneRadial},
+			LeftTrigger:  1500,
+			RightTrigger: 1500,
+		},
 		LedPlayerPreference: 1,
 		LedRGBPreference:    0,
+		RumbleScale:         1,
+		IdlePolicy:          IdlePolicyDisconnect,
 	}
 
 	if c.Controllers == nil {
@@ -40,8 +213,14 @@ func (c *Config) ControllerConfig(mac string) *ControllerConfig {
 	}
 
 	if cc, ok := c.Controllers[mac]; ok {
-		if cc.Deadzone != 0 {
-			res.Deadzone = cc.Deadzone
+		cc.migrateDeadzone()
+		mergeStickDeadzone(&res.Deadzones.LeftStick, cc.Deadzones.LeftStick)
+		mergeStickDeadzone(&res.Deadzones.RightStick, cc.Deadzones.RightStick)
+		if cc.Deadzones.LeftTrigger != 0 {
+			res.Deadzones.LeftTrigger = cc.Deadzones.LeftTrigger
+		}
+		if cc.Deadzones.RightTrigger != 0 {
+			res.Deadzones.RightTrigger = cc.Deadzones.RightTrigger
 		}
 		if cc.LedPlayerPreference != 1 {
 			res.LedPlayerPreference = cc.LedPlayerPreference
@@ -52,10 +231,38 @@ func (c *Config) ControllerConfig(mac string) *ControllerConfig {
 		if cc.LedRGBStatic != "" {
 			res.LedRGBStatic = cc.LedRGBStatic
 		}
+		if cc.LedRGBAnimation != "" {
+			res.LedRGBAnimation = cc.LedRGBAnimation
+		}
+		if cc.TriggerLeft.Mode != "" {
+			res.TriggerLeft = cc.TriggerLeft
+		}
+		if cc.TriggerRight.Mode != "" {
+			res.TriggerRight = cc.TriggerRight
+		}
+		if cc.RumbleScale != 0 {
+			res.RumbleScale = cc.RumbleScale
+		}
+		if cc.IdleMinutes != 0 {
+			res.IdleMinutes = cc.IdleMinutes
+		}
+		if cc.IdlePolicy != "" {
+			res.IdlePolicy = cc.IdlePolicy
+		}
 	}
 
 	return res
 }
+
+func mergeStickDeadzone(res *StickDeadzone, stick StickDeadzone) {
+	if stick.Size != 0 {
+		res.Size = stick.Size
+	}
+	if stick.Shape != "" {
+		res.Shape = stick.Shape
+	}
+}
+
 func SaveControllerConfig(mac string, conf *Config, newCtrlConf *ControllerConfig) {
 
 	if mac == "" {
@@ -72,15 +279,37 @@ func SaveControllerConfig(mac string, conf *Config, newCtrlConf *ControllerConfi
 	}
 }
 func configPath() (string, error) {
-	home, err := os.UserHomeDir()
+	path, err := Dir()
 	if err != nil {
 		return "", err
 	}
-	path := filepath.Join(home, ".config", "dualsense-manager")
 	_ = os.MkdirAll(path, 0755)
 	return filepath.Join(path, "config.yaml"), nil
 }
 
+// Dir returns the directory holding config.yaml: ~/.config/dualsense-manager.
+func Dir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "dualsense-manager"), nil
+}
+
+// StateDir returns the directory holding the application state, such as the battery
+// history: $XDG_STATE_HOME/dualsense-manager, or ~/.local/state/dualsense-manager.
+func StateDir() (string, error) {
+	base := os.Getenv("XDG_STATE_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		base = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(base, "dualsense-manager"), nil
+}
+
 // Save writes the provided configuration to disk.
 func Save(conf *Config) error {
 	path, err := configPath()
@@ -122,5 +351,10 @@ func Load() (*Config, error) {
 		return nil, err
 	}
 
+	for mac, cc := range conf.Controllers {
+		cc.migrateDeadzone()
+		conf.Controllers[mac] = cc
+	}
+
 	return conf, nil
 }
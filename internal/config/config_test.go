@@ -0,0 +1,89 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestControllerConfigMigratesLegacyDeadzone(t *testing.T) {
+	conf := &Config{Controllers: map[string]ControllerConfig{
+		"AA:BB:CC:DD:EE:FF": {Deadzone: 3000},
+		"11:22:33:44:55:66": {Deadzone: 3000, Deadzones: Deadzones{
+			LeftStick:    StickDeadzone{Size: 5000, Shape: DeadzoneRadial},
+			RightTrigger: 500,
+		}},
+	}}
+
+	legacy := conf.ControllerConfig("AA:BB:CC:DD:EE:FF")
+	want := Deadzones{
+		LeftStick:    StickDeadzone{Size: 3000, Shape: DeadzoneAxial},
+		RightStick:   StickDeadzone{Size: 3000, Shape: DeadzoneAxial},
+		LeftTrigger:  3000,
+		RightTrigger: 3000,
+	}
+	if legacy.Deadzones != want {
+		t.Fatalf("expected legacy deadzone migrated to %+v, got %+v", want, legacy.Deadzones)
+	}
+	if legacy.Deadzone != 0 {
+		t.Fatalf("expected legacy deadzone cleared, got %d", legacy.Deadzone)
+	}
+
+	partial := conf.ControllerConfig("11:22:33:44:55:66")
+	want = Deadzones{
+		LeftStick:    StickDeadzone{Size: 5000, Shape: DeadzoneRadial},
+		RightStick:   StickDeadzone{Size: 3000, Shape: DeadzoneAxial},
+		LeftTrigger:  3000,
+		RightTrigger: 500,
+	}
+	if partial.Deadzones != want {
+		t.Fatalf("expected new deadzones to win over the legacy one %+v, got %+v", want, partial.Deadzones)
+	}
+
+	defaults := conf.ControllerConfig("00:00:00:00:00:00")
+	if defaults.Deadzones.LeftStick.Shape != DeadzoneRadial || defaults.Deadzones.LeftTrigger != 1500 {
+		t.Fatalf("unexpected default deadzones %+v", defaults.Deadzones)
+	}
+}
+
+func TestControllerConfigKeepsAnimationAcrossReload(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	const mac = "AA:BB:CC:DD:EE:FF"
+
+	conf, err := Load()
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	ctrlConf := conf.ControllerConfig(mac)
+	ctrlConf.LedRGBPreference = 3
+	ctrlConf.LedRGBAnimation = "rainbow"
+	SaveControllerConfig(mac, conf, ctrlConf)
+
+	reloaded, err := Load()
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	got := reloaded.ControllerConfig(mac)
+	if got.LedRGBPreference != 3 || got.LedRGBAnimation != "rainbow" {
+		t.Fatalf("expected the animated mode and animation kept, got mode %d animation %q", got.LedRGBPreference, got.LedRGBAnimation)
+	}
+}
+
+func TestAlerts(t *testing.T) {
+	tests := []struct {
+		name string
+		conf Config
+		want []BatteryAlert
+	}{
+		{"disabled", Config{}, nil},
+		{"single threshold", Config{BatteryAlert: 15}, []BatteryAlert{{Level: 15, Severity: SeverityAlert}}},
+		{"thresholds replace the single one", Config{BatteryAlert: 15, BatteryAlerts: []BatteryAlert{
+			{Level: 30, Severity: SeverityWarning},
+			{Level: 10},
+		}}, []BatteryAlert{{Level: 30, Severity: SeverityWarning}, {Level: 10, Severity: SeverityAlert}}},
+	}
+	for _, tt := range tests {
+		if got := tt.conf.Alerts(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Alerts() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+// Package dbustest provides an in-process fake D-Bus peer so that code using
+// godbus can be tested without a system bus.
+package dbustest
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Handler answers a method call with a reply body, or with a D-Bus error.
+type Handler func(msg *dbus.Message) ([]interface{}, *dbus.Error)
+
+// Call records a method call received by the fake bus.
+type Call struct {
+	Path   dbus.ObjectPath
+	Method string
+	Body   []interface{}
+}
+
+// Bus is a fake message bus answering method calls with registered handlers.
+// Its Connect method has the same signature as dbus.ConnectSystemBus.
+type Bus struct {
+	mu       sync.Mutex
+	handlers map[string]Handler
+	calls    []Call
+	clients  []*client
+}
+
+type client struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// New returns an empty fake bus.
+func New() *Bus {
+	return &Bus{handlers: make(map[string]Handler)}
+}
+
+// Handle registers the handler for a fully qualified method name such as
+// "org.bluez.Device1.Disconnect". The handler receives calls on any object path.
+func (b *Bus) Handle(method string, h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[method] = h
+}
+
+// Calls returns the method calls received so far, bus daemon calls excluded.
+func (b *Bus) Calls() []Call {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Call(nil), b.calls...)
+}
+
+// CallsTo returns the received calls to the given fully qualified method name.
+func (b *Bus) CallsTo(method string) []Call {
+	var calls []Call
+	for _, c := range b.Calls() {
+		if c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// Connect returns a client connection to the fake bus, authenticated and registered.
+func (b *Bus) Connect(opts ...dbus.ConnOption) (*dbus.Conn, error) {
+	clientSide, serverSide := net.Pipe()
+	c := &client{w: serverSide}
+
+	b.mu.Lock()
+	b.clients = append(b.clients, c)
+	b.mu.Unlock()
+
+	go b.serve(serverSide, c)
+
+	conn, err := dbus.NewConn(clientSide, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Auth([]dbus.Auth{dbus.AuthAnonymous()}); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if err := conn.Hello(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Emit broadcasts a signal to every connected client. name is the fully
+// qualified signal name, such as "org.freedesktop.DBus.Properties.PropertiesChanged".
+func (b *Bus) Emit(path dbus.ObjectPath, name string, body ...interface{}) {
+	dot := strings.LastIndex(name, ".")
+	msg := &dbus.Message{
+		Type: dbus.TypeSignal,
+		Headers: map[dbus.HeaderField]dbus.Variant{
+			dbus.FieldPath:      dbus.MakeVariant(path),
+			dbus.FieldInterface: dbus.MakeVariant(name[:dot]),
+			dbus.FieldMember:    dbus.MakeVariant(name[dot+1:]),
+			dbus.FieldSender:    dbus.MakeVariant(":1.0"),
+		},
+		Body: body,
+	}
+	if len(body) > 0 {
+		msg.Headers[dbus.FieldSignature] = dbus.MakeVariant(dbus.SignatureOf(body...))
+	}
+
+	b.mu.Lock()
+	clients := append([]*client(nil), b.clients...)
+	b.mu.Unlock()
+	for _, c := range clients {
+		_ = c.send(msg)
+	}
+}
+
+func (c *client) send(msg *dbus.Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return msg.EncodeTo(c.w, binary.LittleEndian)
+}
+
+func (b *Bus) serve(conn net.Conn, c *client) {
+	defer func() { _ = conn.Close() }()
+
+	r := bufio.NewReader(conn)
+	if err := authenticate(r, conn); err != nil {
+		return
+	}
+
+	for {
+		msg, err := dbus.DecodeMessage(r)
+		if err != nil {
+			return
+		}
+		if msg.Type != dbus.TypeMethodCall {
+			continue
+		}
+
+		body, dbusErr := b.dispatch(msg)
+		if msg.Flags&dbus.FlagNoReplyExpected != 0 {
+			continue
+		}
+		if err := c.send(reply(msg, body, dbusErr)); err != nil {
+			return
+		}
+	}
+}
+
+func (b *Bus) dispatch(msg *dbus.Message) ([]interface{}, *dbus.Error) {
+	path, _ := msg.Headers[dbus.FieldPath].Value().(dbus.ObjectPath)
+	iface, _ := msg.Headers[dbus.FieldInterface].Value().(string)
+	member, _ := msg.Headers[dbus.FieldMember].Value().(string)
+
+	if iface == "org.freedesktop.DBus" {
+		switch member {
+		case "Hello":
+			return []interface{}{":1.42"}, nil
+		default:
+			// AddMatch, RemoveMatch and friends always succeed.
+			return nil, nil
+		}
+	}
+
+	method := iface + "." + member
+	b.mu.Lock()
+	b.calls = append(b.calls, Call{Path: path, Method: method, Body: msg.Body})
+	h, ok := b.handlers[method]
+	b.mu.Unlock()
+
+	if !ok {
+		return nil, dbus.NewError("org.freedesktop.DBus.Error.UnknownMethod", []interface{}{"no handler for " + method})
+	}
+	return h(msg)
+}
+
+func reply(call *dbus.Message, body []interface{}, dbusErr *dbus.Error) *dbus.Message {
+	msg := &dbus.Message{
+		Type: dbus.TypeMethodReply,
+		Headers: map[dbus.HeaderField]dbus.Variant{
+			dbus.FieldReplySerial: dbus.MakeVariant(call.Serial()),
+			dbus.FieldDestination: dbus.MakeVariant(":1.42"),
+		},
+		Body: body,
+	}
+	if dbusErr != nil {
+		msg.Type = dbus.TypeError
+		msg.Headers[dbus.FieldErrorName] = dbus.MakeVariant(dbusErr.Name)
+		msg.Body = dbusErr.Body
+	}
+	if len(msg.Body) > 0 {
+		msg.Headers[dbus.FieldSignature] = dbus.MakeVariant(dbus.SignatureOf(msg.Body...))
+	}
+	return msg
+}
+
+// authenticate runs the server side of the SASL handshake, accepting ANONYMOUS.
+func authenticate(r *bufio.Reader, w io.Writer) error {
+	if nul, err := r.ReadByte(); err != nil || nul != 0 {
+		return errors.New("dbustest: missing initial null byte")
+	}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "AUTH":
+			_, err = io.WriteString(w, "REJECTED ANONYMOUS\r\n")
+		case strings.HasPrefix(line, "AUTH ANONYMOUS"):
+			_, err = io.WriteString(w, "OK 0123456789abcdef0123456789abcdef\r\n")
+		case line == "BEGIN":
+			return nil
+		default:
+			_, err = io.WriteString(w, "ERROR\r\n")
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package hidraw
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Feature reports of the DualSense, as read by the hid-playstation driver.
+const (
+	FeatureCalibration  = 0x05
+	FeaturePairingInfo  = 0x09
+	FeatureFirmwareInfo = 0x20
+
+	FeatureCalibrationSz  = 41
+	FeaturePairingInfoSz  = 20
+	FeatureFirmwareInfoSz = 64
+
+	featureCRCSeed = 0xA3
+)
+
+// ErrUnsupported is returned for controllers whose feature reports are laid out
+// differently from the DualSense ones, such as the DualShock 4.
+var ErrUnsupported = errors.New("feature reports unsupported")
+
+// GetFeature reads the feature report whose ID is in buf[0] from a hidraw node
+// into buf, and returns its length.
+// It is a package-level variable so tests can override it with a fake implementation.
+var GetFeature = getFeature
+
+// ReadFeature reads the feature report id of size bytes from the controller behind
+// jsPath. Over Bluetooth the report ends with a CRC, which is checked.
+func ReadFeature(jsPath string, id byte, size int) ([]byte, error) {
+	node, err := NodePath(jsPath)
+	if err != nil {
+		return nil, err
+	}
+	bus, err := Bus(jsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, size)
+	buf[0] = id
+	n, err := GetFeature(node, buf)
+	if err != nil {
+		return nil, fmt.Errorf("reading feature report 0x%02x: %w", id, err)
+	}
+	if n != size {
+		return nil, fmt.Errorf("feature report 0x%02x: got %d bytes, want %d", id, n, size)
+	}
+	if bus == BusBluetooth {
+		want := binary.LittleEndian.Uint32(buf[size-4:])
+		if got := bluetoothCRC(featureCRCSeed, buf[:size-4]); got != want {
+			return nil, fmt.Errorf("feature report 0x%02x: bad CRC %08x, want %08x", id, got, want)
+		}
+	}
+	return buf, nil
+}
+
+// Info is the serial, firmware and calibration information of a controller.
+type Info struct {
+	Serial      string       `json:"serial"`
+	Firmware    FirmwareInfo `json:"firmware"`
+	Calibration Calibration  `json:"calibration"`
+}
+
+// ReadInfo reads and decodes the pairing info, firmware info and calibration
+// feature reports of the controller behind jsPath. They are only laid out this
+// way on the DualSense and DualSense Edge: callers check the model first and
+// report ErrUnsupported for the others.
+func ReadInfo(jsPath string) (Info, error) {
+	var info Info
+	report, err := ReadFeature(jsPath, FeaturePairingInfo, FeaturePairingInfoSz)
+	if err != nil {
+		return info, err
+	}
+	pairing, err := ParsePairingInfo(report)
+	if err != nil {
+		return info, err
+	}
+	info.Serial = pairing.MAC
+
+	if report, err = ReadFeature(jsPath, FeatureFirmwareInfo, FeatureFirmwareInfoSz); err != nil {
+		return info, err
+	}
+	if info.Firmware, err = ParseFirmwareInfo(report); err != nil {
+		return info, err
+	}
+
+	if report, err = ReadFeature(jsPath, FeatureCalibration, FeatureCalibrationSz); err != nil {
+		return info, err
+	}
+	if info.Calibration, err = ParseCalibration(report); err != nil {
+		return info, err
+	}
+	return info, nil
+}
+
+// Version is a hardware or firmware version, shown in hexadecimal like the driver
+// does in sysfs, e.g. "0x0110002a".
+type Version uint32
+
+// String returns the version in hexadecimal.
+func (v Version) String() string { return fmt.Sprintf("0x%08x", uint32(v)) }
+
+// MarshalText encodes the version in hexadecimal.
+func (v Version) MarshalText() ([]byte, error) { return []byte(v.String()), nil }
+
+// UpdateVersion is the firmware update version, shown in hexadecimal, e.g. "0x0630".
+type UpdateVersion uint16
+
+// String returns the update version in hexadecimal.
+func (v UpdateVersion) String() string { return fmt.Sprintf("0x%04x", uint16(v)) }
+
+// MarshalText encodes the update version in hexadecimal.
+func (v UpdateVersion) MarshalText() ([]byte, error) { return []byte(v.String()), nil }
+
+// FirmwareInfo is the firmware info feature report.
+type FirmwareInfo struct {
+	BuildDate       time.Time     `json:"build_date"`
+	FirmwareType    uint16        `json:"firmware_type"`
+	SoftwareSeries  uint16        `json:"software_series"`
+	HardwareVersion Version       `json:"hardware_version"`
+	FirmwareVersion Version       `json:"firmware_version"`
+	UpdateVersion   UpdateVersion `json:"update_version"`
+}
+
+// ParseFirmwareInfo decodes a firmware info feature report. The build date is
+// stored as the C __DATE__ and __TIME__ strings, e.g. "Jun 21 2021" "10:48:07".
+func ParseFirmwareInfo(b []byte) (FirmwareInfo, error) {
+	if err := checkFeature(b, FeatureFirmwareInfo, FeatureFirmwareInfoSz); err != nil {
+		return FirmwareInfo{}, err
+	}
+	date := strings.TrimRight(string(b[1:12]), "\x00")
+	clock := strings.TrimRight(string(b[12:20]), "\x00")
+	built, err := time.Parse("Jan _2 2006 15:04:05", date+" "+clock)
+	if err != nil {
+		return FirmwareInfo{}, fmt.Errorf("firmware build date %q %q: %w", date, clock, err)
+	}
+	return FirmwareInfo{
+		BuildDate:       built,
+		FirmwareType:    binary.LittleEndian.Uint16(b[20:]),
+		SoftwareSeries:  binary.LittleEndian.Uint16(b[22:]),
+		HardwareVersion: Version(binary.LittleEndian.Uint32(b[24:])),
+		FirmwareVersion: Version(binary.LittleEndian.Uint32(b[28:])),
+		UpdateVersion:   UpdateVersion(binary.LittleEndian.Uint16(b[44:])),
+	}, nil
+}
+
+// PairingInfo is the pairing info feature report.
+type PairingInfo struct {
+	// MAC is the Bluetooth address of the controller, which is also its serial.
+	MAC string `json:"mac"`
+}
+
+// ParsePairingInfo decodes a pairing info feature report. The address is stored
+// least significant byte first.
+func ParsePairingInfo(b []byte) (PairingInfo, error) {
+	if err := checkFeature(b, FeaturePairingInfo, FeaturePairingInfoSz); err != nil {
+		return PairingInfo{}, err
+	}
+	mac := make([]string, 6)
+	for i := range mac {
+		mac[i] = fmt.Sprintf("%02X", b[6-i])
+	}
+	return PairingInfo{MAC: strings.Join(mac, ":")}, nil
+}
+
+// Calibration is the factory calibration of the motion sensors, in raw sensor units.
+type Calibration struct {
+	GyroPitchBias  int16 `json:"gyro_pitch_bias"`
+	GyroYawBias    int16 `json:"gyro_yaw_bias"`
+	GyroRollBias   int16 `json:"gyro_roll_bias"`
+	GyroPitchPlus  int16 `json:"gyro_pitch_plus"`
+	GyroPitchMinus int16 `json:"gyro_pitch_minus"`
+	GyroYawPlus    int16 `json:"gyro_yaw_plus"`
+	GyroYawMinus   int16 `json:"gyro_yaw_minus"`
+	GyroRollPlus   int16 `json:"gyro_roll_plus"`
+	GyroRollMinus  int16 `json:"gyro_roll_minus"`
+	GyroSpeedPlus  int16 `json:"gyro_speed_plus"`
+	GyroSpeedMinus int16 `json:"gyro_speed_minus"`
+	AccelXPlus     int16 `json:"accel_x_plus"`
+	AccelXMinus    int16 `json:"accel_x_minus"`
+	AccelYPlus     int16 `json:"accel_y_plus"`
+	AccelYMinus    int16 `json:"accel_y_minus"`
+	AccelZPlus     int16 `json:"accel_z_plus"`
+	AccelZMinus    int16 `json:"accel_z_minus"`
+}
+
+// ParseCalibration decodes a calibration feature report.
+func ParseCalibration(b []byte) (Calibration, error) {
+	if err := checkFeature(b, FeatureCalibration, FeatureCalibrationSz); err != nil {
+		return Calibration{}, err
+	}
+	v := func(off int) int16 { return int16(binary.LittleEndian.Uint16(b[off:])) }
+	return Calibration{
+		GyroPitchBias:  v(1),
+		GyroYawBias:    v(3),
+		GyroRollBias:   v(5),
+		GyroPitchPlus:  v(7),
+		GyroPitchMinus: v(9),
+		GyroYawPlus:    v(11),
+		GyroYawMinus:   v(13),
+		GyroRollPlus:   v(15),
+		GyroRollMinus:  v(17),
+		GyroSpeedPlus:  v(19),
+		GyroSpeedMinus: v(21),
+		AccelXPlus:     v(23),
+		AccelXMinus:    v(25),
+		AccelYPlus:     v(27),
+		AccelYMinus:    v(29),
+		AccelZPlus:     v(31),
+		AccelZMinus:    v(33),
+	}, nil
+}
+
+func checkFeature(b []byte, id byte, size int) error {
+	if len(b) < size {
+		return fmt.Errorf("feature report 0x%02x: got %d bytes, want %d", id, len(b), size)
+	}
+	if b[0] != id {
+		return fmt.Errorf("feature report 0x%02x: unexpected report ID 0x%02x", id, b[0])
+	}
+	return nil
+}
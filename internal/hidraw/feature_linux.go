@@ -0,0 +1,28 @@
+package hidraw
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// hidiocgfeature returns the HIDIOCGFEATURE ioctl request for a buffer of size bytes.
+func hidiocgfeature(size int) uintptr {
+	const iocRead, iocWrite = 2, 1
+	return uintptr((iocRead|iocWrite)<<30 | size<<16 | 'H'<<8 | 0x07)
+}
+
+// getFeature reads a feature report with the HIDIOCGFEATURE ioctl.
+func getFeature(node string, buf []byte) (int, error) {
+	f, err := os.OpenFile(node, os.O_RDWR, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = f.Close() }()
+
+	n, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), hidiocgfeature(len(buf)), uintptr(unsafe.Pointer(&buf[0])))
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(n), nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package hidraw
+
+import "errors"
+
+func getFeature(_ string, _ []byte) (int, error) {
+	return 0, errors.New("feature reports are only available on Linux")
+}
@@ -0,0 +1,146 @@
+package hidraw
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"dualsense/internal/sysfs"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of the feature report tests")
+
+// readReport reads a report from a testdata hex dump, skipping # comments.
+func readReport(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("reading report: %v", err)
+	}
+	var digits strings.Builder
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		digits.WriteString(strings.Join(strings.Fields(line), ""))
+	}
+	b, err := hex.DecodeString(digits.String())
+	if err != nil {
+		t.Fatalf("decoding %s: %v", name, err)
+	}
+	return b
+}
+
+// checkGolden compares the JSON encoding of v with the golden file name.
+func checkGolden(t *testing.T, name string, v any) {
+	t.Helper()
+	got, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatalf("encoding %s: %v", name, err)
+	}
+	got = append(got, '\n')
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatalf("writing golden file: %v", err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading golden file: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("%s mismatch:\ngot:\n%s\nwant:\n%s", name, got, want)
+	}
+}
+
+func TestParseFirmwareInfo(t *testing.T) {
+	for _, name := range []string{"firmware_info_usb", "firmware_info_bt"} {
+		info, err := ParseFirmwareInfo(readReport(t, name+".hex"))
+		if err != nil {
+			t.Fatalf("%s: ParseFirmwareInfo error: %v", name, err)
+		}
+		checkGolden(t, "firmware_info.golden", info)
+	}
+}
+
+func TestParsePairingInfo(t *testing.T) {
+	info, err := ParsePairingInfo(readReport(t, "pairing_info_usb.hex"))
+	if err != nil {
+		t.Fatalf("ParsePairingInfo error: %v", err)
+	}
+	checkGolden(t, "pairing_info.golden", info)
+}
+
+func TestParseCalibration(t *testing.T) {
+	calibration, err := ParseCalibration(readReport(t, "calibration_usb.hex"))
+	if err != nil {
+		t.Fatalf("ParseCalibration error: %v", err)
+	}
+	checkGolden(t, "calibration.golden", calibration)
+}
+
+func TestParseFeatureErrors(t *testing.T) {
+	report := readReport(t, "firmware_info_usb.hex")
+	if _, err := ParseFirmwareInfo(report[:30]); err == nil {
+		t.Error("expected an error for a short report")
+	}
+	if _, err := ParsePairingInfo(report); err == nil {
+		t.Error("expected an error for another report ID")
+	}
+	bad := append([]byte(nil), report...)
+	copy(bad[1:12], "not a date!")
+	if _, err := ParseFirmwareInfo(bad); err == nil {
+		t.Error("expected an error for an invalid build date")
+	}
+}
+
+func TestReadFeature(t *testing.T) {
+	oldFS, oldGet := sysfs.FS, GetFeature
+	defer func() { sysfs.FS, GetFeature = oldFS, oldGet }()
+
+	tests := []struct {
+		bustype string
+		report  string
+		wantErr bool
+	}{
+		{"0003\n", "firmware_info_usb.hex", false},
+		{"0005\n", "firmware_info_bt.hex", false},
+		// the USB report has no CRC
+		{"0005\n", "firmware_info_usb.hex", true},
+	}
+	for _, tt := range tests {
+		sysfs.FS = fakeFS{
+			files: map[string][]byte{"/sys/class/input/js0/device/id/bustype": []byte(tt.bustype)},
+			globs: map[string][]string{
+				"/sys/class/input/js0/device/device/hidraw/hidraw*": {"/sys/class/input/js0/device/device/hidraw/hidraw3"},
+			},
+		}
+		report := readReport(t, tt.report)
+		GetFeature = func(node string, buf []byte) (int, error) {
+			if node != "/dev/hidraw3" || buf[0] != FeatureFirmwareInfo {
+				t.Fatalf("unexpected feature read of 0x%02x from %s", buf[0], node)
+			}
+			return copy(buf, report), nil
+		}
+
+		got, err := ReadFeature("/dev/input/js0", FeatureFirmwareInfo, FeatureFirmwareInfoSz)
+		if tt.wantErr {
+			if err == nil {
+				t.Fatalf("%s over %q: expected a CRC error", tt.report, tt.bustype)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s over %q: ReadFeature error: %v", tt.report, tt.bustype, err)
+		}
+		if !bytes.Equal(got, report) {
+			t.Fatalf("ReadFeature = % x, want % x", got, report)
+		}
+	}
+}
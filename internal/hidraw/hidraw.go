@@ -0,0 +1,88 @@
+// Package hidraw provides access to DualSense controllers through their /dev/hidraw nodes.
+package hidraw
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"dualsense/internal/sysfs"
+)
+
+// HID bus types as reported in the input device id/bustype attribute.
+const (
+	BusUSB       = 0x03
+	BusBluetooth = 0x05
+)
+
+// Open opens a hidraw device node for reading and writing.
+// It is a package-level variable so tests can override it with a fake implementation.
+var Open = func(path string) (io.ReadWriteCloser, error) {
+	return os.OpenFile(path, os.O_RDWR, 0)
+}
+
+var (
+	sequenceMu sync.Mutex
+	sequences  = map[string]byte{}
+)
+
+// NodePath returns the /dev/hidraw node of the controller behind the given js device path.
+func NodePath(jsPath string) (string, error) {
+	pattern := fmt.Sprintf("/sys/class/input/%s/device/device/hidraw/hidraw*", filepath.Base(jsPath))
+	matches, err := sysfs.FS.Glob(pattern)
+	if err != nil {
+		return "", err
+	}
+	if len(matches) == 0 {
+		return "", fmt.Errorf("hidraw node not found for %s", jsPath)
+	}
+	return filepath.Join("/dev", filepath.Base(matches[0])), nil
+}
+
+// Bus returns the HID bus type (BusUSB or BusBluetooth) of the controller behind jsPath.
+func Bus(jsPath string) (uint16, error) {
+	data, err := sysfs.FS.ReadFile(fmt.Sprintf("/sys/class/input/%s/device/id/bustype", filepath.Base(jsPath)))
+	if err != nil {
+		return 0, err
+	}
+	bus, err := strconv.ParseUint(strings.TrimSpace(string(data)), 16, 16)
+	if err != nil {
+		return 0, err
+	}
+	return uint16(bus), nil
+}
+
+// Send writes an output report to the hidraw node of the controller behind jsPath,
+// framing it for USB or Bluetooth depending on the controller bus.
+func Send(jsPath string, report *OutputReport) error {
+	node, err := NodePath(jsPath)
+	if err != nil {
+		return err
+	}
+	bus, err := Bus(jsPath)
+	if err != nil {
+		return err
+	}
+
+	f, err := Open(node)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	_, err = f.Write(report.Marshal(bus == BusBluetooth, nextSequence(node)))
+	return err
+}
+
+// nextSequence returns the 4-bit sequence number for the next Bluetooth report sent to node.
+func nextSequence(node string) byte {
+	sequenceMu.Lock()
+	defer sequenceMu.Unlock()
+	seq := sequences[node]
+	sequences[node] = (seq + 1) & 0x0F
+	return seq
+}
@@ -0,0 +1,161 @@
+package hidraw
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	"dualsense/internal/sysfs"
+)
+
+type fakeFS struct {
+	files map[string][]byte
+	globs map[string][]string
+}
+
+func (f fakeFS) ReadFile(path string) ([]byte, error) {
+	if b, ok := f.files[path]; ok {
+		return b, nil
+	}
+	return nil, fmt.Errorf("not found: %s", path)
+}
+func (f fakeFS) WriteFile(_ string, _ []byte, _ os.FileMode) error {
+	return fmt.Errorf("not implemented")
+}
+func (f fakeFS) Glob(pattern string) ([]string, error) { return f.globs[pattern], nil }
+func (f fakeFS) Stat(_ string) (os.FileInfo, error)    { return nil, fmt.Errorf("not implemented") }
+
+// fakeHidraw captures every report written to it.
+type fakeHidraw struct {
+	writes [][]byte
+}
+
+func (f *fakeHidraw) Read(_ []byte) (int, error) { return 0, io.EOF }
+func (f *fakeHidraw) Write(p []byte) (int, error) {
+	f.writes = append(f.writes, append([]byte(nil), p...))
+	return len(p), nil
+}
+func (f *fakeHidraw) Close() error { return nil }
+
+func TestMarshalUSB(t *testing.T) {
+	report := &OutputReport{
+		Flags1:     Flag1Lightbar | Flag1PlayerIndicator,
+		PlayerLeds: 0x04,
+		Red:        0xFF,
+		Green:      0x80,
+		Blue:       0x01,
+	}
+
+	b := report.Marshal(false, 0)
+	if len(b) != OutputReportUSBSz {
+		t.Fatalf("expected %d bytes, got %d", OutputReportUSBSz, len(b))
+	}
+	want := map[int]byte{0: 0x02, 2: 0x14, 44: 0x04, 45: 0xFF, 46: 0x80, 47: 0x01}
+	for i, v := range b {
+		if v != want[i] {
+			t.Fatalf("byte %d: want 0x%02x got 0x%02x (report % x)", i, want[i], v, b)
+		}
+	}
+}
+
+func TestMarshalBluetooth(t *testing.T) {
+	report := &OutputReport{Flags1: Flag1Lightbar, Red: 0xFF, Green: 0x80}
+
+	b := report.Marshal(true, 1)
+	if len(b) != OutputReportBTSz {
+		t.Fatalf("expected %d bytes, got %d", OutputReportBTSz, len(b))
+	}
+	if b[0] != 0x31 || b[1] != 0x10 || b[2] != 0x10 {
+		t.Fatalf("unexpected header % x", b[:3])
+	}
+	if b[4] != Flag1Lightbar || b[47] != 0xFF || b[48] != 0x80 {
+		t.Fatalf("unexpected common block % x", b[3:50])
+	}
+	// CRC32 over 0xA2 followed by the first 74 bytes, computed independently.
+	if crc := binary.LittleEndian.Uint32(b[74:]); crc != 0x7d5a4c7b {
+		t.Fatalf("unexpected CRC %08x", crc)
+	}
+}
+
+func TestParseOutputReport(t *testing.T) {
+	report := &OutputReport{
+		Flags0:       Flag0RightTrigger | Flag0LeftTrigger,
+		Flags1:       Flag1MicMuteLed,
+		MuteLed:      1,
+		RightTrigger: [11]byte{0x25, 0x08, 0x01, 0x07},
+		LeftTrigger:  [11]byte{0x05},
+		Blue:         0x42,
+	}
+
+	for _, bt := range []bool{false, true} {
+		got, err := ParseOutputReport(report.Marshal(bt, 7))
+		if err != nil {
+			t.Fatalf("ParseOutputReport(bluetooth=%v) error: %v", bt, err)
+		}
+		if *got != *report {
+			t.Fatalf("round trip mismatch (bluetooth=%v): %+v != %+v", bt, got, report)
+		}
+	}
+
+	corrupted := report.Marshal(true, 0)
+	corrupted[10] ^= 0xFF
+	if _, err := ParseOutputReport(corrupted); err == nil {
+		t.Fatalf("expected CRC error for corrupted Bluetooth report")
+	}
+	if _, err := ParseOutputReport([]byte{0x11, 0x00}); err == nil {
+		t.Fatalf("expected error for unknown report id")
+	}
+}
+
+func TestSend(t *testing.T) {
+	oldFS, oldOpen := sysfs.FS, Open
+	defer func() { sysfs.FS, Open = oldFS, oldOpen }()
+
+	tests := []struct {
+		bustype string
+		wantID  byte
+		wantLen int
+	}{
+		{"0003\n", OutputReportUSB, OutputReportUSBSz},
+		{"0005\n", OutputReportBT, OutputReportBTSz},
+	}
+
+	for _, tt := range tests {
+		sysfs.FS = fakeFS{
+			files: map[string][]byte{"/sys/class/input/js0/device/id/bustype": []byte(tt.bustype)},
+			globs: map[string][]string{
+				"/sys/class/input/js0/device/device/hidraw/hidraw*": {"/sys/class/input/js0/device/device/hidraw/hidraw3"},
+			},
+		}
+		dev := &fakeHidraw{}
+		var opened string
+		Open = func(path string) (io.ReadWriteCloser, error) {
+			opened = path
+			return dev, nil
+		}
+
+		if err := Send("/dev/input/js0", &OutputReport{Flags1: Flag1Lightbar, Green: 0xFF}); err != nil {
+			t.Fatalf("Send error: %v", err)
+		}
+		if opened != "/dev/hidraw3" {
+			t.Fatalf("expected /dev/hidraw3 to be opened, got %q", opened)
+		}
+		if len(dev.writes) != 1 {
+			t.Fatalf("expected 1 write, got %d", len(dev.writes))
+		}
+		w := dev.writes[0]
+		if w[0] != tt.wantID || len(w) != tt.wantLen {
+			t.Fatalf("unexpected report id 0x%02x / length %d", w[0], len(w))
+		}
+		got, err := ParseOutputReport(w)
+		if err != nil {
+			t.Fatalf("ParseOutputReport error: %v", err)
+		}
+		if !bytes.Equal([]byte{got.Flags1, got.Green}, []byte{Flag1Lightbar, 0xFF}) {
+			t.Fatalf("unexpected decoded report %+v", got)
+		}
+	}
+}
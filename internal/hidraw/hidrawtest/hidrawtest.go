@@ -0,0 +1,97 @@
+// Package hidrawtest fakes the hidraw node of a controller so that code sending
+// output reports can be tested without a device.
+package hidrawtest
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"testing"
+
+	"dualsense/internal/hidraw"
+	"dualsense/internal/sysfs"
+)
+
+// FS is a fake sysfs tree of /dev/input/js0 with the given HID bus type, e.g.
+// "0005" for Bluetooth, and the hidraw node Node, e.g. "hidraw1", or none.
+type FS struct {
+	Bustype string
+	Node    string
+}
+
+func (f FS) ReadFile(path string) ([]byte, error) {
+	if path == "/sys/class/input/js0/device/id/bustype" {
+		return []byte(f.Bustype + "\n"), nil
+	}
+	return nil, fmt.Errorf("not found: %s", path)
+}
+func (f FS) WriteFile(path string, _ []byte, _ os.FileMode) error {
+	return fmt.Errorf("unexpected sysfs write: %s", path)
+}
+func (f FS) Glob(pattern string) ([]string, error) {
+	if pattern == "/sys/class/input/js0/device/device/hidraw/hidraw*" && f.Node != "" {
+		return []string{"/sys/class/input/js0/device/device/hidraw/" + f.Node}, nil
+	}
+	return nil, nil
+}
+func (f FS) Stat(path string) (os.FileInfo, error) {
+	return nil, fmt.Errorf("not found: %s", path)
+}
+
+// Device is a fake hidraw node capturing the reports written to it.
+type Device struct {
+	mu     sync.Mutex
+	writes [][]byte
+}
+
+func (d *Device) Read(_ []byte) (int, error) { return 0, io.EOF }
+func (d *Device) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.writes = append(d.writes, append([]byte(nil), p...))
+	return len(p), nil
+}
+func (d *Device) Close() error { return nil }
+
+// Writes returns the raw reports written so far.
+func (d *Device) Writes() [][]byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([][]byte(nil), d.writes...)
+}
+
+// Reports decodes the output reports written so far, failing t on an invalid one.
+func (d *Device) Reports(t testing.TB) []*hidraw.OutputReport {
+	t.Helper()
+	var reports []*hidraw.OutputReport
+	for _, w := range d.Writes() {
+		r, err := hidraw.ParseOutputReport(w)
+		if err != nil {
+			t.Fatalf("invalid report % x: %v", w, err)
+		}
+		reports = append(reports, r)
+	}
+	return reports
+}
+
+// UseDevice makes hidraw.Open return a new Device for any node until the test
+// ends, and returns it.
+func UseDevice(t testing.TB) *Device {
+	t.Helper()
+	old := hidraw.Open
+	dev := &Device{}
+	hidraw.Open = func(_ string) (io.ReadWriteCloser, error) { return dev, nil }
+	t.Cleanup(func() { hidraw.Open = old })
+	return dev
+}
+
+// Use fakes js0 over the given bus type with the /dev/hidraw1 node until the
+// test ends, and returns the node.
+func Use(t testing.TB, bustype string) *Device {
+	t.Helper()
+	old := sysfs.FS
+	sysfs.FS = FS{Bustype: bustype, Node: "hidraw1"}
+	t.Cleanup(func() { sysfs.FS = old })
+	return UseDevice(t)
+}
@@ -0,0 +1,34 @@
+package hidraw
+
+// Input report framing, as sent by the controller once hid-playstation enabled
+// the full Bluetooth reports.
+const (
+	InputReportUSB   = 0x01
+	InputReportBT    = 0x31
+	InputReportUSBSz = 64
+	InputReportBTSz  = 78
+
+	// the third button byte follows the sticks, triggers and sequence number
+	offButtons2USB = 1 + 9
+	offButtons2BT  = 2 + 9
+)
+
+// Buttons of the third button byte of an input report.
+const (
+	Button2PS      = 1 << 0
+	Button2Pad     = 1 << 1
+	Button2MicMute = 1 << 2
+)
+
+// MuteButton reports whether the mute button is held in a USB or Bluetooth input
+// report. ok is false for other reports, such as the reduced Bluetooth report sent
+// before the driver enabled the full ones.
+func MuteButton(b []byte) (pressed, ok bool) {
+	switch {
+	case len(b) >= InputReportUSBSz && b[0] == InputReportUSB:
+		return b[offButtons2USB]&Button2MicMute != 0, true
+	case len(b) >= InputReportBTSz && b[0] == InputReportBT:
+		return b[offButtons2BT]&Button2MicMute != 0, true
+	}
+	return false, false
+}
@@ -0,0 +1,38 @@
+package hidraw
+
+import "testing"
+
+func TestMuteButton(t *testing.T) {
+	usb := make([]byte, InputReportUSBSz)
+	usb[0] = InputReportUSB
+	bt := make([]byte, InputReportBTSz)
+	bt[0] = InputReportBT
+	reduced := make([]byte, 10)
+	reduced[0] = InputReportUSB
+
+	tests := []struct {
+		name        string
+		report      []byte
+		offset      int
+		buttons     byte
+		wantPressed bool
+		wantOK      bool
+	}{
+		{"usb released", usb, 0, 0, false, true},
+		{"usb pressed", usb, 10, Button2MicMute, true, true},
+		{"bluetooth pressed", bt, 11, Button2MicMute, true, true},
+		{"bluetooth PS button", bt, 11, Button2PS, false, true},
+		{"reduced bluetooth report", reduced, 0, 0, false, false},
+		{"empty report", nil, 0, 0, false, false},
+	}
+	for _, tt := range tests {
+		report := append([]byte(nil), tt.report...)
+		if tt.buttons != 0 {
+			report[tt.offset] = tt.buttons
+		}
+		pressed, ok := MuteButton(report)
+		if pressed != tt.wantPressed || ok != tt.wantOK {
+			t.Errorf("%s: MuteButton = %v, %v, want %v, %v", tt.name, pressed, ok, tt.wantPressed, tt.wantOK)
+		}
+	}
+}
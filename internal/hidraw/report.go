@@ -0,0 +1,181 @@
+package hidraw
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+// Output report framing, as used by the hid-playstation driver.
+const (
+	OutputReportUSB   = 0x02
+	OutputReportBT    = 0x31
+	OutputReportUSBSz = 63
+	OutputReportBTSz  = 78
+
+	outputTagBT   = 0x10
+	outputCRCSeed = 0xA2
+)
+
+// Valid flags selecting which fields of an output report the controller applies.
+const (
+	Flag0CompatibleVibration = 1 << 0
+	Flag0HapticsSelect       = 1 << 1
+	Flag0RightTrigger        = 1 << 2
+	Flag0LeftTrigger         = 1 << 3
+
+	Flag1MicMuteLed      = 1 << 0
+	Flag1PowerSave       = 1 << 1
+	Flag1Lightbar        = 1 << 2
+	Flag1ReleaseLeds     = 1 << 3
+	Flag1PlayerIndicator = 1 << 4
+	Flag1MotorPower      = 1 << 6
+
+	Flag2LightbarSetup        = 1 << 1
+	Flag2CompatibleVibration2 = 1 << 2
+)
+
+// Power save control bits, applied with Flag1PowerSave.
+const (
+	PowerSaveHaptics = 1 << 2
+	PowerSaveAudio   = 1 << 3
+	PowerSaveMicMute = 1 << 4
+)
+
+// OutputReport holds the common part of a DualSense output report.
+// Only the fields whose valid flag is set are applied by the controller.
+type OutputReport struct {
+	Flags0        byte
+	Flags1        byte
+	MotorRight    byte
+	MotorLeft     byte
+	MuteLed       byte
+	PowerSave     byte
+	RightTrigger  [11]byte
+	LeftTrigger   [11]byte
+	MotorPower    byte
+	Flags2        byte
+	LightbarSetup byte
+	LedBrightness byte
+	PlayerLeds    byte
+	Red           byte
+	Green         byte
+	Blue          byte
+}
+
+// Offsets of the fields inside the 47-byte common block.
+const (
+	commonSize          = 47
+	offFlags0           = 0
+	offFlags1           = 1
+	offMotorRight       = 2
+	offMotorLeft        = 3
+	offMuteLed          = 8
+	offPowerSave        = 9
+	offRightTrigger     = 10
+	offLeftTrigger      = 21
+	offMotorPower       = 36
+	offFlags2           = 38
+	offLightbarSetup    = 41
+	offLedBrightness    = 42
+	offPlayerLeds       = 43
+	offRed              = 44
+	offGreen            = 45
+	offBlue             = 46
+	commonOffsetUSB     = 1
+	commonOffsetBT      = 3
+	crcOffsetBT         = OutputReportBTSz - 4
+	btSequenceTagOffset = 1
+	btTagOffset         = 2
+)
+
+func (r *OutputReport) encodeCommon(b []byte) {
+	b[offFlags0] = r.Flags0
+	b[offFlags1] = r.Flags1
+	b[offMotorRight] = r.MotorRight
+	b[offMotorLeft] = r.MotorLeft
+	b[offMuteLed] = r.MuteLed
+	b[offPowerSave] = r.PowerSave
+	copy(b[offRightTrigger:], r.RightTrigger[:])
+	copy(b[offLeftTrigger:], r.LeftTrigger[:])
+	b[offMotorPower] = r.MotorPower
+	b[offFlags2] = r.Flags2
+	b[offLightbarSetup] = r.LightbarSetup
+	b[offLedBrightness] = r.LedBrightness
+	b[offPlayerLeds] = r.PlayerLeds
+	b[offRed] = r.Red
+	b[offGreen] = r.Green
+	b[offBlue] = r.Blue
+}
+
+func (r *OutputReport) decodeCommon(b []byte) {
+	r.Flags0 = b[offFlags0]
+	r.Flags1 = b[offFlags1]
+	r.MotorRight = b[offMotorRight]
+	r.MotorLeft = b[offMotorLeft]
+	r.MuteLed = b[offMuteLed]
+	r.PowerSave = b[offPowerSave]
+	copy(r.RightTrigger[:], b[offRightTrigger:offRightTrigger+11])
+	copy(r.LeftTrigger[:], b[offLeftTrigger:offLeftTrigger+11])
+	r.MotorPower = b[offMotorPower]
+	r.Flags2 = b[offFlags2]
+	r.LightbarSetup = b[offLightbarSetup]
+	r.LedBrightness = b[offLedBrightness]
+	r.PlayerLeds = b[offPlayerLeds]
+	r.Red = b[offRed]
+	r.Green = b[offGreen]
+	r.Blue = b[offBlue]
+}
+
+// Marshal encodes the report as USB report 0x02, or as Bluetooth report 0x31
+// with the given 4-bit sequence number and its CRC32 trailer.
+func (r *OutputReport) Marshal(bluetooth bool, seq byte) []byte {
+	if !bluetooth {
+		b := make([]byte, OutputReportUSBSz)
+		b[0] = OutputReportUSB
+		r.encodeCommon(b[commonOffsetUSB : commonOffsetUSB+commonSize])
+		return b
+	}
+
+	b := make([]byte, OutputReportBTSz)
+	b[0] = OutputReportBT
+	b[btSequenceTagOffset] = (seq & 0x0F) << 4
+	b[btTagOffset] = outputTagBT
+	r.encodeCommon(b[commonOffsetBT : commonOffsetBT+commonSize])
+	binary.LittleEndian.PutUint32(b[crcOffsetBT:], bluetoothCRC(outputCRCSeed, b[:crcOffsetBT]))
+	return b
+}
+
+// ParseOutputReport decodes a USB or Bluetooth output report, verifying the Bluetooth CRC.
+func ParseOutputReport(b []byte) (*OutputReport, error) {
+	if len(b) == 0 {
+		return nil, fmt.Errorf("empty output report")
+	}
+
+	r := &OutputReport{}
+	switch b[0] {
+	case OutputReportUSB:
+		if len(b) < commonOffsetUSB+commonSize {
+			return nil, fmt.Errorf("short USB output report: %d bytes", len(b))
+		}
+		r.decodeCommon(b[commonOffsetUSB : commonOffsetUSB+commonSize])
+	case OutputReportBT:
+		if len(b) != OutputReportBTSz {
+			return nil, fmt.Errorf("bad Bluetooth output report size: %d bytes", len(b))
+		}
+		want := binary.LittleEndian.Uint32(b[crcOffsetBT:])
+		if got := bluetoothCRC(outputCRCSeed, b[:crcOffsetBT]); got != want {
+			return nil, fmt.Errorf("bad Bluetooth output report CRC: got %08x want %08x", got, want)
+		}
+		r.decodeCommon(b[commonOffsetBT : commonOffsetBT+commonSize])
+	default:
+		return nil, fmt.Errorf("unknown output report id 0x%02x", b[0])
+	}
+	return r, nil
+}
+
+// bluetoothCRC computes the CRC32 the controller expects over seed followed by data.
+func bluetoothCRC(seed byte, data []byte) uint32 {
+	crc := crc32.Update(0, crc32.IEEETable, []byte{seed})
+	return crc32.Update(crc, crc32.IEEETable, data)
+}
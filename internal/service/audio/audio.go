@@ -0,0 +1,166 @@
+// Package audio mutes the default audio source of the PulseAudio or PipeWire sound
+// server through pactl, and watches its mute state.
+package audio
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"dualsense/internal/clock"
+)
+
+// DefaultSource names the default audio source in pactl commands.
+const DefaultSource = "@DEFAULT_SOURCE@"
+
+// retryDelay is how long WatchSourceMute waits before subscribing again when the
+// sound server is unavailable or restarted.
+const retryDelay = 5 * time.Second
+
+var (
+	// Debug enables debug logging within the audio package.
+	Debug bool
+
+	// Clock delays the subscription retries. Tests replace it with a fake clock.
+	Clock clock.Clock = clock.Real{}
+
+	// Run runs pactl with args and returns its output.
+	// It is a package-level variable so tests can override it with a fake implementation.
+	Run = func(ctx context.Context, args ...string) ([]byte, error) {
+		return pactl(ctx, args...).Output()
+	}
+
+	// Subscribe runs pactl subscribe and returns its output, one event per line,
+	// until it is closed.
+	// It is a package-level variable so tests can override it with a fake implementation.
+	Subscribe = func(ctx context.Context) (io.ReadCloser, error) {
+		cmd := pactl(ctx, "subscribe")
+		out, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, err
+		}
+		return subscription{ReadCloser: out, cmd: cmd}, nil
+	}
+)
+
+// pactl returns the pactl command running args, with untranslated output.
+func pactl(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "pactl", args...)
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	return cmd
+}
+
+// subscription is the output of a running pactl subscribe.
+type subscription struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+// Close stops pactl subscribe.
+func (s subscription) Close() error {
+	_ = s.cmd.Process.Kill()
+	_ = s.ReadCloser.Close()
+	_ = s.cmd.Wait()
+	return nil
+}
+
+// SourceMuted reports whether the default audio source is muted.
+func SourceMuted(ctx context.Context) (bool, error) {
+	out, err := Run(ctx, "get-source-mute", DefaultSource)
+	if err != nil {
+		return false, fmt.Errorf("reading source mute: %w", err)
+	}
+	return parseMute(string(out))
+}
+
+// ToggleSourceMute mutes the default audio source, or unmutes it when muted.
+func ToggleSourceMute(ctx context.Context) error {
+	if _, err := Run(ctx, "set-source-mute", DefaultSource, "toggle"); err != nil {
+		return fmt.Errorf("toggling source mute: %w", err)
+	}
+	return nil
+}
+
+// WatchSourceMute sends the mute state of the default audio source, then every
+// change of it, including the default source being replaced, until ctx is cancelled.
+func WatchSourceMute(ctx context.Context) <-chan bool {
+	states := make(chan bool)
+	go func() {
+		defer close(states)
+		known, last := false, false
+		send := func() bool {
+			muted, err := SourceMuted(ctx)
+			if err != nil {
+				if Debug {
+					log.Default().Println(err)
+				}
+				return true
+			}
+			if known && muted == last {
+				return true
+			}
+			known, last = true, muted
+			select {
+			case states <- muted:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for ctx.Err() == nil {
+			events, err := Subscribe(ctx)
+			if err == nil {
+				if !send() {
+					_ = events.Close()
+					return
+				}
+				scanner := bufio.NewScanner(events)
+				for scanner.Scan() {
+					if isSourceEvent(scanner.Text()) && !send() {
+						break
+					}
+				}
+				_ = events.Close()
+			} else if Debug {
+				log.Default().Println("Error subscribing to sound server events:", err)
+			}
+
+			select {
+			case <-ctx.Done():
+			case <-Clock.After(retryDelay):
+			}
+		}
+	}()
+	return states
+}
+
+// parseMute decodes the output of pactl get-source-mute, e.g. "Mute: yes".
+func parseMute(out string) (bool, error) {
+	value, ok := strings.CutPrefix(strings.TrimSpace(out), "Mute:")
+	if !ok {
+		return false, fmt.Errorf("unexpected source mute %q", out)
+	}
+	switch strings.TrimSpace(value) {
+	case "yes":
+		return true, nil
+	case "no":
+		return false, nil
+	}
+	return false, fmt.Errorf("unexpected source mute %q", out)
+}
+
+// isSourceEvent reports whether a pactl subscribe event may change the mute state
+// of the default source: a source changed, or the server default source did.
+func isSourceEvent(line string) bool {
+	return strings.Contains(line, " on source #") || strings.Contains(line, " on server")
+}
@@ -0,0 +1,130 @@
+package audio
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"dualsense/internal/clock"
+)
+
+// drain stops a watch and waits for it to return, before the fakes are restored.
+func drain(cancel context.CancelFunc, watch <-chan bool) {
+	cancel()
+	for range watch {
+	}
+}
+
+func TestParseMute(t *testing.T) {
+	tests := []struct {
+		out     string
+		want    bool
+		wantErr bool
+	}{
+		{"Mute: yes\n", true, false},
+		{"Mute: no\n", false, false},
+		{"Stummschalten: ja\n", false, true},
+		{"Mute: maybe\n", false, true},
+	}
+	for _, tt := range tests {
+		got, err := parseMute(tt.out)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseMute(%q) = %v, %v, want %v, error %v", tt.out, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestIsSourceEvent(t *testing.T) {
+	tests := map[string]bool{
+		"Event 'change' on source #52":         true,
+		"Event 'change' on server #4294967295": true,
+		"Event 'new' on source-output #12":     false,
+		"Event 'change' on sink #50":           false,
+		"Event 'remove' on client #88":         false,
+	}
+	for line, want := range tests {
+		if got := isSourceEvent(line); got != want {
+			t.Errorf("isSourceEvent(%q) = %v, want %v", line, got, want)
+		}
+	}
+}
+
+func TestWatchSourceMute(t *testing.T) {
+	oldRun, oldSubscribe := Run, Subscribe
+	defer func() { Run, Subscribe = oldRun, oldSubscribe }()
+
+	// each query returns the next state
+	states := []string{"Mute: no", "Mute: no", "Mute: yes", "Mute: no"}
+	Run = func(_ context.Context, args ...string) ([]byte, error) {
+		if strings.Join(args, " ") != "get-source-mute "+DefaultSource {
+			t.Fatalf("unexpected pactl %v", args)
+		}
+		if len(states) == 0 {
+			return nil, errors.New("no more states")
+		}
+		state := states[0]
+		states = states[1:]
+		return []byte(state), nil
+	}
+	events := strings.Join([]string{
+		"Event 'change' on source #52", // unchanged, not sent
+		"Event 'change' on sink #50",   // ignored
+		"Event 'change' on server #4294967295",
+		"Event 'change' on source #52",
+	}, "\n")
+	Subscribe = func(_ context.Context) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(events)), nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	watch := WatchSourceMute(ctx)
+	defer drain(cancel, watch)
+
+	var got []bool
+	for range 3 {
+		select {
+		case muted := <-watch:
+			got = append(got, muted)
+		case <-time.After(time.Second):
+			t.Fatalf("timed out after states %v", got)
+		}
+	}
+	if want := []bool{false, true, false}; len(got) != 3 || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Fatalf("got states %v, want %v", got, want)
+	}
+}
+
+func TestWatchSourceMuteRetries(t *testing.T) {
+	oldRun, oldSubscribe, oldClock := Run, Subscribe, Clock
+	defer func() { Run, Subscribe, Clock = oldRun, oldSubscribe, oldClock }()
+	fake := clock.NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	Clock = fake
+
+	Run = func(_ context.Context, _ ...string) ([]byte, error) { return []byte("Mute: yes"), nil }
+	attempts := 0
+	Subscribe = func(_ context.Context) (io.ReadCloser, error) {
+		attempts++
+		if attempts == 1 {
+			return nil, errors.New("connection refused")
+		}
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	watch := WatchSourceMute(ctx)
+	defer drain(cancel, watch)
+
+	fake.BlockUntil(1)
+	fake.Advance(retryDelay)
+	select {
+	case muted := <-watch:
+		if !muted {
+			t.Fatalf("expected the source to be muted")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected the state once subscribed again")
+	}
+}
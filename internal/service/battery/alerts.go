@@ -0,0 +1,73 @@
+package battery
+
+import "dualsense/internal/config"
+
+// AlertHysteresis is how far above a threshold the level must rise before its
+// alert fires again. It is more than a 10% step, so that a reading bouncing
+// between two steps does not repeat it.
+const AlertHysteresis = 15
+
+// AlertFull is the severity of the fully charged alert.
+const AlertFull = "full"
+
+// Alert is a battery alert to send.
+type Alert struct {
+	// Severity is one of the config severities, or AlertFull.
+	Severity string
+	Level    int
+}
+
+// Alerter decides when the battery alerts of a controller fire: each low battery
+// threshold once per discharge, and the fully charged alert once per charge.
+type Alerter struct {
+	// fired holds the thresholds already fired, by level.
+	fired map[int]bool
+	full  bool
+}
+
+// NewAlerter returns an Alerter with no alert fired yet.
+func NewAlerter() *Alerter {
+	return &Alerter{fired: make(map[int]bool)}
+}
+
+// Check returns the alert to send for a reading under the thresholds of conf, if any.
+// When several thresholds are crossed at once, only the most urgent one fires.
+func (a *Alerter) Check(conf *config.Config, level int, status string) (Alert, bool) {
+	thresholds := conf.Alerts()
+	for _, t := range thresholds {
+		if a.fired[t.Level] && level >= t.Level+AlertHysteresis {
+			a.fired[t.Level] = false
+		}
+	}
+
+	switch status {
+	case "Full":
+		if conf.NotifyFull && !a.full {
+			a.full = true
+			return Alert{Severity: AlertFull, Level: level}, true
+		}
+		return Alert{}, false
+	case "Charging":
+		return Alert{}, false
+	}
+	a.full = false
+
+	var crossed *config.BatteryAlert
+	fire := false
+	for i, t := range thresholds {
+		if level > t.Level {
+			continue
+		}
+		if !a.fired[t.Level] {
+			fire = true
+			a.fired[t.Level] = true
+		}
+		if crossed == nil || t.Level < crossed.Level {
+			crossed = &thresholds[i]
+		}
+	}
+	if !fire {
+		return Alert{}, false
+	}
+	return Alert{Severity: crossed.Severity, Level: level}, true
+}
@@ -0,0 +1,67 @@
+package battery
+
+import (
+	"reflect"
+	"testing"
+
+	"dualsense/internal/config"
+)
+
+func TestAlerter(t *testing.T) {
+	thresholds := &config.Config{
+		BatteryAlerts: []config.BatteryAlert{
+			{Level: 30, Severity: config.SeverityWarning},
+			{Level: 15},
+			{Level: 5, Severity: config.SeverityCritical},
+		},
+		NotifyFull: true,
+	}
+
+	type reading struct {
+		level  int
+		status string
+	}
+	tests := []struct {
+		name     string
+		conf     *config.Config
+		readings []reading
+		want     []Alert
+	}{
+		{"each threshold once per discharge", thresholds, []reading{
+			{45, "Discharging"}, {35, "Discharging"}, {25, "Discharging"}, {25, "Discharging"},
+			{15, "Discharging"}, {5, "Discharging"}, {5, "Discharging"},
+		}, []Alert{{config.SeverityWarning, 25}, {config.SeverityAlert, 15}, {config.SeverityCritical, 5}}},
+		{"bouncing between steps", thresholds, []reading{
+			{35, "Discharging"}, {25, "Discharging"}, {35, "Discharging"}, {25, "Discharging"}, {35, "Discharging"}, {25, "Discharging"},
+		}, []Alert{{config.SeverityWarning, 25}}},
+		{"connected below several thresholds", thresholds, []reading{
+			{5, "Discharging"}, {15, "Discharging"}, {5, "Discharging"},
+		}, []Alert{{config.SeverityCritical, 5}}},
+		{"short charge", thresholds, []reading{
+			{15, "Discharging"}, {15, "Charging"}, {25, "Charging"}, {25, "Discharging"}, {15, "Discharging"},
+		}, []Alert{{config.SeverityAlert, 15}}},
+		{"next discharge", thresholds, []reading{
+			{25, "Discharging"}, {25, "Charging"}, {95, "Charging"}, {100, "Full"}, {100, "Full"},
+			{95, "Discharging"}, {25, "Discharging"},
+		}, []Alert{{config.SeverityWarning, 25}, {AlertFull, 100}, {config.SeverityWarning, 25}}},
+		{"legacy single threshold", &config.Config{BatteryAlert: 15}, []reading{
+			{25, "Discharging"}, {15, "Discharging"}, {5, "Discharging"}, {100, "Full"},
+		}, []Alert{{config.SeverityAlert, 15}}},
+		{"alerts disabled", &config.Config{}, []reading{{5, "Discharging"}}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alerter := NewAlerter()
+			var got []Alert
+			for _, r := range tt.readings {
+				if alert, ok := alerter.Check(tt.conf, r.level, r.status); ok {
+					got = append(got, alert)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("alerts = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -7,17 +7,46 @@ import (
 	"strconv"
 	"strings"
 
+	"dualsense/internal/clock"
 	"dualsense/internal/sysfs"
 )
 
+var (
+	// Debug enables debug logging within the battery package.
+	Debug bool
+
+	// Clock times the retries of the UPower device lookups. Tests replace it with a fake clock.
+	Clock clock.Clock = clock.Real{}
+)
+
 // Battery interface defines methods to read battery information.
 type Battery interface {
 	ActualBatteryLevel(jsPath string) (int, error)
 	ChargingStatus(jsPath string) (string, error)
 }
 
-// ActualBatteryLevel reads the capacity file and returns the current battery percent.
+// Source is the Battery read by ActualBatteryLevel and ChargingStatus, selected
+// with UseSource.
+var Source Battery = Sysfs{}
+
+// Sysfs reads the battery from the power supply class device exposed by the driver.
+type Sysfs struct{}
+
+var _ Battery = Sysfs{}
+
+// ActualBatteryLevel returns the current battery percent of the controller behind jsPath.
 func ActualBatteryLevel(jsPath string) (int, error) {
+	return Source.ActualBatteryLevel(jsPath)
+}
+
+// ChargingStatus returns the charging status of the controller behind jsPath, as
+// named by the power supply class: Charging, Discharging, Full or Not charging.
+func ChargingStatus(jsPath string) (string, error) {
+	return Source.ChargingStatus(jsPath)
+}
+
+// ActualBatteryLevel reads the capacity file and returns the current battery percent.
+func (Sysfs) ActualBatteryLevel(jsPath string) (int, error) {
 	basePath, err := batteryPath(jsPath)
 	if err != nil {
 		return 0, err
@@ -37,7 +66,7 @@ func ActualBatteryLevel(jsPath string) (int, error) {
 }
 
 // ChargingStatus returns the charging status string for the controller battery.
-func ChargingStatus(jsPath string) (string, error) {
+func (Sysfs) ChargingStatus(jsPath string) (string, error) {
 	basePath, err := batteryPath(jsPath)
 	if err != nil {
 		return "", err
@@ -51,14 +80,21 @@ func ChargingStatus(jsPath string) (string, error) {
 	return strings.TrimSpace(string(data)), nil
 }
 
+// batteryNames are the power supply names of the controller battery: hid-playstation
+// names the DualSense one ps-controller-battery-<mac>, hid-sony names the DualShock 4
+// one sony_controller_battery_<mac>.
+var batteryNames = []string{"ps-controller-battery-*", "sony_controller_battery_*"}
+
 func batteryPath(jsPath string) (string, error) {
 	devicePath := fmt.Sprintf("/sys/class/input/%s/device/device/power_supply", filepath.Base(jsPath))
-	matches, err := sysfs.FS.Glob(filepath.Join(devicePath, "ps-controller-battery-*"))
-	if err != nil {
-		return "", err
-	}
-	if len(matches) > 0 {
-		return matches[0], nil
+	for _, name := range batteryNames {
+		matches, err := sysfs.FS.Glob(filepath.Join(devicePath, name))
+		if err != nil {
+			return "", err
+		}
+		if len(matches) > 0 {
+			return matches[0], nil
+		}
 	}
 	return "", fmt.Errorf("battery path not found")
 }
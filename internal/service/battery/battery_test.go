@@ -80,3 +80,36 @@ func TestChargingStatus(t *testing.T) {
 		t.Fatalf("expected Charging got %q", status)
 	}
 }
+
+func TestActualBatteryLevelDualShock4(t *testing.T) {
+	old := sysfs.FS
+	fake := fakeFS{
+		files: map[string][]byte{},
+		globs: map[string][]string{},
+	}
+
+	// hid-sony names the power supply sony_controller_battery_<mac>
+	devicePath := "/sys/class/input/js0/device/device/power_supply"
+	baseMatch := "/sys/class/power_supply/sony_controller_battery_aa:bb:cc:dd:ee:ff"
+	fake.globs[filepath.Join(devicePath, "sony_controller_battery_*")] = []string{baseMatch}
+	fake.files[filepath.Join(baseMatch, "capacity")] = []byte("55\n")
+	fake.files[filepath.Join(baseMatch, "status")] = []byte("Discharging\n")
+
+	sysfs.FS = fake
+	defer func() { sysfs.FS = old }()
+
+	level, err := ActualBatteryLevel("/dev/input/js0")
+	if err != nil {
+		t.Fatalf("ActualBatteryLevel error: %v", err)
+	}
+	if level != 55 {
+		t.Fatalf("expected 55 got %d", level)
+	}
+	status, err := ChargingStatus("/dev/input/js0")
+	if err != nil {
+		t.Fatalf("ChargingStatus error: %v", err)
+	}
+	if status != "Discharging" {
+		t.Fatalf("expected Discharging got %q", status)
+	}
+}
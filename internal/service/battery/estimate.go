@@ -0,0 +1,159 @@
+package battery
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// EstimateWindow is how far back level changes are used to compute the
+	// charge or discharge rate.
+	EstimateWindow = 6 * time.Hour
+	// estimateMinSpan is the shortest time between the first and last level
+	// change used for an estimate: the DualSense reports 10% steps, so the rate
+	// of two close changes is mostly noise.
+	estimateMinSpan = 15 * time.Minute
+	// estimateMaxRemaining bounds the estimates, longer ones come from a rate too
+	// flat to be meaningful.
+	estimateMaxRemaining = 48 * time.Hour
+	// levelStep is half the resolution of the capacity reported by hid-playstation,
+	// which rounds to the middle of 10% steps.
+	levelStep = 5
+)
+
+// Estimate is the time left until the battery is empty, or full when charging.
+type Estimate struct {
+	Charging  bool
+	Remaining time.Duration
+	// Rate is the charge (positive) or discharge (negative) rate in percent per hour.
+	Rate float64
+}
+
+// String describes the estimate, e.g. "~3h 20m remaining" or "full in ~45m".
+func (e Estimate) String() string {
+	if e.Charging {
+		return "full in " + formatRemaining(e.Remaining)
+	}
+	return formatRemaining(e.Remaining) + " remaining"
+}
+
+func formatRemaining(d time.Duration) string {
+	if d.Round(5*time.Minute) <= 0 {
+		return "<5m"
+	}
+	return "~" + formatDuration(d)
+}
+
+// formatDuration rounds d to 5 minutes, e.g. "3h 20m", "45m" or "2h".
+func formatDuration(d time.Duration) string {
+	d = d.Round(5 * time.Minute)
+	hours, minutes := int(d.Hours()), int(d.Minutes())%60
+	switch {
+	case hours == 0:
+		return fmt.Sprintf("%dm", minutes)
+	case minutes == 0:
+		return fmt.Sprintf("%dh", hours)
+	}
+	return fmt.Sprintf("%dh %dm", hours, minutes)
+}
+
+// Estimator computes the battery Estimate of a controller from its readings. It
+// fits a line through the times the reported level first reached each step
+// during the current charge or discharge, which smooths out the 10% steps and
+// ignores readings bouncing back to the previous step.
+type Estimator struct {
+	current    Sample
+	hasCurrent bool
+	// reached is the lowest level reported while discharging, or the highest
+	// while charging, since the controller started charging or discharging.
+	reached int
+	// changes are the readings that reached a new level.
+	changes []Sample
+}
+
+// NewEstimator returns an Estimator fed with the given past readings, oldest first.
+func NewEstimator(samples []Sample) *Estimator {
+	e := &Estimator{}
+	for _, s := range samples {
+		e.Add(s)
+	}
+	return e
+}
+
+// Add records a reading. Readings must be added in chronological order.
+func (e *Estimator) Add(s Sample) {
+	switch {
+	case !e.hasCurrent, s.Status != e.current.Status, s.Time.Sub(e.current.Time) > 2*HeartbeatInterval:
+		// plugged, unplugged or turned off: the previous rate no longer applies
+		e.changes = nil
+		e.reached = s.Level
+	case (s.Status == "Discharging" && s.Level < e.reached) || (s.Status == "Charging" && s.Level > e.reached):
+		e.changes = append(e.changes, s)
+		e.reached = s.Level
+	}
+	e.current, e.hasCurrent = s, true
+
+	cutoff := s.Time.Add(-EstimateWindow)
+	for len(e.changes) > 0 && e.changes[0].Time.Before(cutoff) {
+		e.changes = e.changes[1:]
+	}
+}
+
+// Estimate returns the estimate at the given time, and false when the readings
+// are too few or too noisy for one.
+func (e *Estimator) Estimate(now time.Time) (Estimate, bool) {
+	charging := e.current.Status == "Charging"
+	if !e.hasCurrent || (!charging && e.current.Status != "Discharging") || len(e.changes) < 2 {
+		return Estimate{}, false
+	}
+	first, last := e.changes[0], e.changes[len(e.changes)-1]
+	if last.Time.Sub(first.Time) < estimateMinSpan {
+		return Estimate{}, false
+	}
+
+	// least squares fit of the level against the hours since the first change
+	var sumX, sumY, sumXY, sumXX float64
+	for _, s := range e.changes {
+		x := s.Time.Sub(first.Time).Hours()
+		y := float64(s.Level)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	n := float64(len(e.changes))
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return Estimate{}, false
+	}
+	rate := (n*sumXY - sumX*sumY) / denominator
+	intercept := (sumY - rate*sumX) / n
+	if (charging && rate <= 0) || (!charging && rate >= 0) {
+		return Estimate{}, false
+	}
+
+	// the fitted level now, kept within the step the controller reports
+	level := intercept + rate*now.Sub(first.Time).Hours()
+	level = clamp(level, float64(e.current.Level-levelStep), float64(e.current.Level+levelStep))
+	level = clamp(level, 0, 100)
+
+	hours := level / -rate
+	if charging {
+		hours = (100 - level) / rate
+	}
+	remaining := time.Duration(hours * float64(time.Hour))
+	if remaining > estimateMaxRemaining {
+		return Estimate{}, false
+	}
+	return Estimate{Charging: charging, Remaining: remaining, Rate: rate}, true
+}
+
+func clamp(v, low, high float64) float64 {
+	if v < low {
+		return low
+	}
+	if v > high {
+		return high
+	}
+	return v
+}
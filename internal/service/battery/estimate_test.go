@@ -0,0 +1,144 @@
+package battery
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+var estimateStart = time.Unix(1700000000, 0)
+
+// reportedLevel rounds a true level the way hid-playstation reports it: the
+// middle of its 10% step.
+func reportedLevel(level float64) int {
+	return min(int(level/10)*10+5, 100)
+}
+
+// simulate returns a reading every 10 seconds for the given duration, from the
+// true level drained or charged at rate percent per hour.
+func simulate(start float64, rate float64, d time.Duration, status string, report func(t time.Duration, level float64) int) []Sample {
+	var samples []Sample
+	for t := time.Duration(0); t <= d; t += 10 * time.Second {
+		level := start + rate*t.Hours()
+		samples = append(samples, Sample{Time: estimateStart.Add(t), Level: report(t, level), Status: status})
+	}
+	return samples
+}
+
+func stepped(_ time.Duration, level float64) int { return reportedLevel(level) }
+
+func expectEstimate(t *testing.T, samples []Sample, charging bool, want, tolerance time.Duration) Estimate {
+	t.Helper()
+	now := samples[len(samples)-1].Time
+	got, ok := NewEstimator(samples).Estimate(now)
+	if !ok {
+		t.Fatalf("expected an estimate")
+	}
+	if got.Charging != charging {
+		t.Fatalf("expected charging=%v, got %+v", charging, got)
+	}
+	if diff := got.Remaining - want; diff > tolerance || diff < -tolerance {
+		t.Fatalf("expected about %v remaining, got %v (%+v)", want, got.Remaining, got)
+	}
+	return got
+}
+
+func TestEstimateSteppedDischarge(t *testing.T) {
+	// 100% drained at 15%/h for 3h10m: 52.5% left, 3h30m remaining
+	samples := simulate(100, -15, 3*time.Hour+10*time.Minute, "Discharging", stepped)
+	got := expectEstimate(t, samples, false, 3*time.Hour+30*time.Minute, 15*time.Minute)
+	if math.Abs(got.Rate+15) > 1.5 {
+		t.Fatalf("expected a 15%%/h discharge rate, got %.1f", got.Rate)
+	}
+}
+
+func TestEstimateNoisyDischarge(t *testing.T) {
+	// the reading bounces between two steps for 3 minutes around every change
+	noisy := func(t time.Duration, level float64) int {
+		reported := reportedLevel(level)
+		sinceChange := math.Mod(100-level, 10) / 15 * 60 // minutes since the level crossed a step
+		if sinceChange < 3 && int(t.Seconds()/20)%2 == 0 {
+			return reportedLevel(level + 10)
+		}
+		return reported
+	}
+	samples := simulate(100, -15, 3*time.Hour+10*time.Minute, "Discharging", noisy)
+	expectEstimate(t, samples, false, 3*time.Hour+30*time.Minute, 20*time.Minute)
+}
+
+func TestEstimateCharging(t *testing.T) {
+	// charging at 40%/h from 20% for 1h: full in 1h
+	samples := simulate(20, 40, time.Hour, "Charging", stepped)
+	got := expectEstimate(t, samples, true, time.Hour, 10*time.Minute)
+	if got.String()[:8] != "full in " {
+		t.Fatalf("unexpected description %q", got.String())
+	}
+}
+
+func TestEstimateFromHistory(t *testing.T) {
+	// the history only keeps level changes and a heartbeat every 5 minutes
+	dense := simulate(100, -15, 3*time.Hour+10*time.Minute, "Discharging", stepped)
+	var sparse []Sample
+	for _, s := range dense {
+		if n := len(sparse); n == 0 || s.Level != sparse[n-1].Level || s.Time.Sub(sparse[n-1].Time) >= HeartbeatInterval {
+			sparse = append(sparse, s)
+		}
+	}
+	now := dense[len(dense)-1].Time
+	want, _ := NewEstimator(dense).Estimate(now)
+	got, ok := NewEstimator(sparse).Estimate(now)
+	if !ok || got != want {
+		t.Fatalf("expected the history to give the same estimate %+v, got %+v", want, got)
+	}
+}
+
+func TestEstimateUnavailable(t *testing.T) {
+	discharge := simulate(100, -15, 2*time.Hour, "Discharging", stepped)
+	end := discharge[len(discharge)-1]
+
+	tests := []struct {
+		name    string
+		samples []Sample
+	}{
+		{"no reading", nil},
+		{"single level change", simulate(100, -15, 30*time.Minute, "Discharging", stepped)},
+		{"changes too close", []Sample{
+			{estimateStart, 55, "Discharging"},
+			{estimateStart.Add(time.Minute), 45, "Discharging"},
+			{estimateStart.Add(2 * time.Minute), 35, "Discharging"},
+		}},
+		{"just plugged in", append(discharge, Sample{end.Time.Add(time.Second), end.Level, "Charging"})},
+		{"turned off for a while", append(discharge, Sample{end.Time.Add(time.Hour), end.Level, "Discharging"})},
+		{"full", append(discharge, Sample{end.Time.Add(time.Second), 100, "Full"})},
+		{"rising while discharging", simulate(20, 15, 2*time.Hour, "Discharging", stepped)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := estimateStart
+			if n := len(tt.samples); n > 0 {
+				now = tt.samples[n-1].Time
+			}
+			if got, ok := NewEstimator(tt.samples).Estimate(now); ok {
+				t.Fatalf("expected no estimate, got %+v", got)
+			}
+		})
+	}
+}
+
+func TestEstimateString(t *testing.T) {
+	tests := []struct {
+		estimate Estimate
+		want     string
+	}{
+		{Estimate{Remaining: 3*time.Hour + 18*time.Minute}, "~3h 20m remaining"},
+		{Estimate{Charging: true, Remaining: 44 * time.Minute}, "full in ~45m"},
+		{Estimate{Remaining: 2*time.Hour + time.Minute}, "~2h remaining"},
+		{Estimate{Remaining: 2 * time.Minute}, "<5m remaining"},
+	}
+	for _, tt := range tests {
+		if got := tt.estimate.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
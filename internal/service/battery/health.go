@@ -0,0 +1,294 @@
+package battery
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"dualsense/internal/config"
+)
+
+const (
+	// HealthFile is the name of the file holding the battery health of every
+	// controller, in the configuration directory.
+	HealthFile = "battery-health.json"
+
+	// minRuntimeDrop is the smallest level drop of a discharge used to measure the
+	// runtime: with 10% steps, shorter discharges are too coarse.
+	minRuntimeDrop = 50
+	// baselineRuns is the number of runtimes averaged for the first seen and the
+	// recent runtime.
+	baselineRuns = 3
+	// maxRuntimes is the number of runtimes kept per controller, besides the first
+	// baselineRuns ones.
+	maxRuntimes = 100
+	// steadyTrend is the runtime change reported as steady.
+	steadyTrend = 0.05
+)
+
+// Runtime is the duration of a discharge, scaled to a full-to-empty discharge.
+type Runtime struct {
+	End      time.Time     `json:"end"`
+	Duration time.Duration `json:"duration"`
+}
+
+// ControllerHealth is the battery wear record of a controller.
+type ControllerHealth struct {
+	FirstSeen time.Time `json:"first_seen"`
+	// Charges is the number of times the controller was plugged in.
+	Charges int `json:"charges"`
+	// Charged is the sum of the levels gained while charging, in percent: every
+	// 100% is a charge cycle.
+	Charged int `json:"charged"`
+	// Peak is the highest level of the charge in progress, readings bouncing
+	// below it are not counted twice.
+	Peak     int       `json:"peak"`
+	Runtimes []Runtime `json:"runtimes,omitempty"`
+
+	// Last is the last reading, and Discharge the discharge in progress, if any.
+	Last      Sample     `json:"last"`
+	Discharge *Discharge `json:"discharge,omitempty"`
+
+	// saved is the time of the last reading written to the file.
+	saved time.Time
+}
+
+// Discharge is the discharge since the last charge. It goes on while the
+// controller is turned off or the application restarts: only the time between
+// close readings is counted as runtime, along with the level drained meanwhile.
+type Discharge struct {
+	Start  time.Time     `json:"start"`
+	OnTime time.Duration `json:"on_time"`
+	Drop   int           `json:"drop"`
+}
+
+// Cycles returns the number of full charge cycles equivalent to the charges so far.
+func (c ControllerHealth) Cycles() float64 {
+	return float64(c.Charged) / 100
+}
+
+// Health tracks the battery wear of each controller across charge cycles: the
+// charges, and the runtime of each long enough discharge. It is stored as JSON,
+// is safe for concurrent use, and a nil Health tracks nothing.
+type Health struct {
+	path string
+
+	mu          sync.Mutex
+	controllers map[string]*ControllerHealth
+}
+
+// NewHealth returns the Health stored in the file at path, empty when it does not exist.
+func NewHealth(path string) (*Health, error) {
+	h := &Health{path: path, controllers: make(map[string]*ControllerHealth)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &h.controllers); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return h, nil
+}
+
+// OpenHealth returns the Health stored in the configuration directory.
+func OpenHealth() (*Health, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+	return NewHealth(filepath.Join(dir, HealthFile))
+}
+
+// Observe updates the health of the controller with a reading. Readings must be
+// observed in chronological order; the file is written when the level or the
+// status changed, or HeartbeatInterval after the last write.
+func (h *Health) Observe(mac string, s Sample) error {
+	if h == nil || mac == "" {
+		return nil
+	}
+	mac = strings.ToUpper(mac)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	c, known := h.controllers[mac]
+	if !known {
+		c = &ControllerHealth{FirstSeen: s.Time}
+		h.controllers[mac] = c
+	}
+	last := c.Last
+	// a longer gap follows a turn-off or a restart, during which the runtime is unknown
+	contiguous := known && s.Time.Sub(last.Time) <= 2*HeartbeatInterval
+	changed := !known || s.Level != last.Level || s.Status != last.Status || s.Time.Sub(c.saved) >= HeartbeatInterval
+
+	if s.Status == "Charging" || s.Status == "Full" {
+		if !known || (last.Status != "Charging" && last.Status != "Full") {
+			c.Charges++
+			c.Peak = s.Level
+			if known {
+				c.Peak = last.Level
+			}
+		}
+		if s.Level > c.Peak {
+			c.Charged += s.Level - c.Peak
+			c.Peak = s.Level
+		}
+	}
+
+	switch {
+	case s.Status != "Discharging":
+		c.endDischarge()
+	case !contiguous && s.Level > last.Level:
+		// charged while turned off
+		c.endDischarge()
+		c.Discharge = &Discharge{Start: s.Time}
+	case c.Discharge == nil:
+		c.Discharge = &Discharge{Start: s.Time}
+	case contiguous && last.Status == "Discharging":
+		c.Discharge.OnTime += s.Time.Sub(last.Time)
+		c.Discharge.Drop += last.Level - s.Level
+	}
+	c.Last = s
+
+	if !changed {
+		return nil
+	}
+	c.saved = s.Time
+	return h.save()
+}
+
+// endDischarge records the runtime of the discharge in progress, when it drained
+// enough to be measured.
+func (c *ControllerHealth) endDischarge() {
+	d := c.Discharge
+	c.Discharge = nil
+	if d == nil || d.Drop < minRuntimeDrop {
+		return
+	}
+	duration := d.OnTime * 100 / time.Duration(d.Drop)
+	c.Runtimes = append(c.Runtimes, Runtime{End: c.Last.Time, Duration: duration})
+	if extra := len(c.Runtimes) - baselineRuns - maxRuntimes; extra > 0 {
+		c.Runtimes = append(c.Runtimes[:baselineRuns], c.Runtimes[baselineRuns+extra:]...)
+	}
+}
+
+// save writes the health of every controller. h.mu must be held.
+func (h *Health) save() error {
+	data, err := json.MarshalIndent(h.controllers, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0755); err != nil {
+		return err
+	}
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, h.path)
+}
+
+// HealthReport summarises the battery wear of a controller.
+type HealthReport struct {
+	MAC       string
+	FirstSeen time.Time
+	Charges   int
+	Cycles    float64
+	// Runs is the number of discharges measured. FirstRuntime and RecentRuntime
+	// are the average runtime of the first and the latest ones, set once two
+	// discharges were measured.
+	Runs          int
+	FirstRuntime  time.Duration
+	RecentRuntime time.Duration
+}
+
+// Report returns the health of the controller, and false when it was never seen.
+func (h *Health) Report(mac string) (HealthReport, bool) {
+	if h == nil {
+		return HealthReport{}, false
+	}
+	mac = strings.ToUpper(mac)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	c, ok := h.controllers[mac]
+	if !ok {
+		return HealthReport{}, false
+	}
+	return c.report(mac), true
+}
+
+// Reports returns the health of every controller seen, sorted by MAC.
+func (h *Health) Reports() []HealthReport {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	reports := make([]HealthReport, 0, len(h.controllers))
+	for mac, c := range h.controllers {
+		reports = append(reports, c.report(mac))
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].MAC < reports[j].MAC })
+	return reports
+}
+
+func (c *ControllerHealth) report(mac string) HealthReport {
+	r := HealthReport{MAC: mac, FirstSeen: c.FirstSeen, Charges: c.Charges, Cycles: c.Cycles(), Runs: len(c.Runtimes)}
+	if r.Runs < 2 {
+		return r
+	}
+	n := min(baselineRuns, r.Runs/2)
+	r.FirstRuntime = averageRuntime(c.Runtimes[:n])
+	r.RecentRuntime = averageRuntime(c.Runtimes[r.Runs-n:])
+	return r
+}
+
+func averageRuntime(runtimes []Runtime) time.Duration {
+	var total time.Duration
+	for _, r := range runtimes {
+		total += r.Duration
+	}
+	return total / time.Duration(len(runtimes))
+}
+
+// Trend describes how the runtime changed since the controller was first seen,
+// e.g. "runtime down 25% since first seen", or is empty until two discharges
+// were measured.
+func (r HealthReport) Trend() string {
+	if r.FirstRuntime == 0 {
+		return ""
+	}
+	change := float64(r.RecentRuntime)/float64(r.FirstRuntime) - 1
+	switch {
+	case change <= -steadyTrend:
+		return fmt.Sprintf("runtime down %.0f%% since first seen", -change*100)
+	case change >= steadyTrend:
+		return fmt.Sprintf("runtime up %.0f%% since first seen", change*100)
+	}
+	return "runtime steady since first seen"
+}
+
+// Summary describes the health in a line, e.g.
+// "12.4 cycles, 6h 40m runtime, runtime down 25% since first seen".
+func (r HealthReport) Summary() string {
+	summary := fmt.Sprintf("%.1f cycles", r.Cycles)
+	if r.Runs == 0 {
+		return summary + ", runtime not measured yet"
+	}
+	if r.RecentRuntime == 0 {
+		return summary + ", runtime measured once"
+	}
+	return fmt.Sprintf("%s, %s runtime, %s", summary, formatDuration(r.RecentRuntime), r.Trend())
+}
@@ -0,0 +1,165 @@
+package battery
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const healthMAC = "aa:bb:cc:dd:ee:ff"
+
+// observe feeds a reading every minute while the level moves linearly from one
+// level to another, and returns the time of the last reading.
+func observe(t *testing.T, h *Health, at time.Time, from, to int, status string, d time.Duration) time.Time {
+	t.Helper()
+	for elapsed := time.Duration(0); elapsed <= d; elapsed += time.Minute {
+		level := from + int(float64(to-from)*float64(elapsed)/float64(d))
+		if err := h.Observe(healthMAC, Sample{Time: at.Add(elapsed), Level: level, Status: status}); err != nil {
+			t.Fatalf("Observe error: %v", err)
+		}
+	}
+	return at.Add(d)
+}
+
+func TestHealthTracksCyclesAndRuntime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), HealthFile)
+	h, err := NewHealth(path)
+	if err != nil {
+		t.Fatalf("NewHealth error: %v", err)
+	}
+
+	// two 10h discharges, then two 7h30m ones
+	at := estimateStart
+	for _, run := range []struct {
+		empty    int
+		duration time.Duration
+	}{{20, 8 * time.Hour}, {20, 8 * time.Hour}, {40, 4*time.Hour + 30*time.Minute}, {40, 4*time.Hour + 30*time.Minute}} {
+		at = observe(t, h, at.Add(time.Minute), 100, run.empty, "Discharging", run.duration)
+		at = observe(t, h, at.Add(time.Minute), run.empty, 100, "Charging", 2*time.Hour)
+	}
+
+	report, ok := h.Report(healthMAC)
+	if !ok {
+		t.Fatalf("expected a report")
+	}
+	if report.Charges != 4 || report.Cycles != 2.8 || report.Runs != 4 {
+		t.Fatalf("expected 4 charges, 2.8 cycles and 4 runs, got %+v", report)
+	}
+	if report.FirstRuntime != 10*time.Hour || report.RecentRuntime != 7*time.Hour+30*time.Minute {
+		t.Fatalf("unexpected runtimes %v then %v", report.FirstRuntime, report.RecentRuntime)
+	}
+	if got, want := report.Summary(), "2.8 cycles, 7h 30m runtime, runtime down 25% since first seen"; got != want {
+		t.Fatalf("Summary() = %q, want %q", got, want)
+	}
+
+	reopened, err := NewHealth(path)
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	got, _ := reopened.Report(healthMAC)
+	if !got.FirstSeen.Equal(report.FirstSeen) {
+		t.Fatalf("expected first seen %v, got %v", report.FirstSeen, got.FirstSeen)
+	}
+	got.FirstSeen = report.FirstSeen
+	if got != report {
+		t.Fatalf("expected the health to persist, got %+v, want %+v", got, report)
+	}
+}
+
+func TestHealthDischarges(t *testing.T) {
+	tests := []struct {
+		name     string
+		feed     func(t *testing.T, h *Health)
+		runs     int
+		duration time.Duration
+	}{
+		{"too short to measure", func(t *testing.T, h *Health) {
+			end := observe(t, h, estimateStart, 100, 60, "Discharging", 4*time.Hour)
+			observe(t, h, end.Add(time.Minute), 60, 100, "Charging", time.Hour)
+		}, 0, 0},
+		{"turned off", func(t *testing.T, h *Health) {
+			// idle auto-off splits the discharge, the time turned off is not runtime
+			end := observe(t, h, estimateStart, 100, 70, "Discharging", 3*time.Hour)
+			end = observe(t, h, end.Add(time.Hour), 70, 40, "Discharging", 3*time.Hour)
+			end = observe(t, h, end.Add(8*time.Hour), 40, 30, "Discharging", time.Hour)
+			observe(t, h, end.Add(time.Minute), 30, 100, "Charging", time.Hour)
+		}, 1, 10 * time.Hour},
+		{"charged while turned off", func(t *testing.T, h *Health) {
+			end := observe(t, h, estimateStart, 100, 70, "Discharging", 3*time.Hour)
+			end = observe(t, h, end.Add(3*time.Hour), 100, 40, "Discharging", 6*time.Hour)
+			observe(t, h, end.Add(time.Minute), 40, 100, "Charging", time.Hour)
+		}, 1, 10 * time.Hour},
+		{"pauses while charging", func(t *testing.T, h *Health) {
+			end := observe(t, h, estimateStart, 100, 60, "Discharging", 4*time.Hour)
+			end = observe(t, h, end.Add(time.Minute), 60, 70, "Charging", 10*time.Minute)
+			observe(t, h, end.Add(time.Minute), 70, 10, "Discharging", 6*time.Hour)
+		}, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := NewHealth(filepath.Join(t.TempDir(), HealthFile))
+			if err != nil {
+				t.Fatalf("NewHealth error: %v", err)
+			}
+			tt.feed(t, h)
+			c := h.controllers["AA:BB:CC:DD:EE:FF"]
+			if len(c.Runtimes) != tt.runs {
+				t.Fatalf("expected %d runtimes, got %+v", tt.runs, c.Runtimes)
+			}
+			if tt.runs > 0 && c.Runtimes[0].Duration != tt.duration {
+				t.Fatalf("expected a %v runtime, got %v", tt.duration, c.Runtimes[0].Duration)
+			}
+		})
+	}
+}
+
+func TestHealthIgnoresBouncingCharge(t *testing.T) {
+	h, err := NewHealth(filepath.Join(t.TempDir(), HealthFile))
+	if err != nil {
+		t.Fatalf("NewHealth error: %v", err)
+	}
+	for i, level := range []int{45, 45, 55, 45, 55, 65, 55, 65} {
+		status := "Charging"
+		if i == 0 {
+			status = "Discharging"
+		}
+		if err := h.Observe(healthMAC, Sample{Time: estimateStart.Add(time.Duration(i) * time.Minute), Level: level, Status: status}); err != nil {
+			t.Fatalf("Observe error: %v", err)
+		}
+	}
+	if report, _ := h.Report(healthMAC); report.Charges != 1 || report.Cycles != 0.2 {
+		t.Fatalf("expected one charge of 20%%, got %+v", report)
+	}
+}
+
+func TestHealthDischargeSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), HealthFile)
+	h, err := NewHealth(path)
+	if err != nil {
+		t.Fatalf("NewHealth error: %v", err)
+	}
+	end := observe(t, h, estimateStart, 100, 60, "Discharging", 4*time.Hour)
+
+	// the last reading is written at least every heartbeat, a quick restart is not a turn-off
+	h, err = NewHealth(path)
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	end = observe(t, h, end.Add(time.Minute), 60, 40, "Discharging", 2*time.Hour-time.Minute)
+	// nor does a longer one end the discharge
+	h, err = NewHealth(path)
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	end = observe(t, h, end.Add(time.Hour), 40, 0, "Discharging", 4*time.Hour)
+	observe(t, h, end.Add(time.Minute), 0, 100, "Charging", 2*time.Hour)
+
+	report, _ := h.Report(healthMAC)
+	if report.Runs != 1 {
+		t.Fatalf("expected the discharge measured once, got %+v", h.controllers["AA:BB:CC:DD:EE:FF"].Runtimes)
+	}
+	if got := h.controllers["AA:BB:CC:DD:EE:FF"].Runtimes[0].Duration; got != 10*time.Hour {
+		t.Fatalf("expected a 10h runtime, got %v", got)
+	}
+}
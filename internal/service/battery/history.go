@@ -0,0 +1,222 @@
+package battery
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"dualsense/internal/config"
+)
+
+const (
+	// HeartbeatInterval is the longest time between two recorded samples of a
+	// connected controller whose battery does not change. Longer gaps in the
+	// history mean the controller was off.
+	HeartbeatInterval = 5 * time.Minute
+	// MaxHistoryAge is how long samples are kept.
+	MaxHistoryAge = 30 * 24 * time.Hour
+	// MaxHistorySamples is the number of samples kept per controller.
+	MaxHistorySamples = 20000
+
+	// compactEvery is the number of appended samples between two retention passes.
+	compactEvery = 1000
+)
+
+// Sample is a battery reading of a controller.
+type Sample struct {
+	Time   time.Time `json:"time"`
+	Level  int       `json:"level"`
+	Status string    `json:"status"`
+}
+
+// statusCodes are the single letters standing for the power supply statuses in
+// the history files.
+var statusCodes = map[string]string{
+	"Charging":     "C",
+	"Discharging":  "D",
+	"Full":         "F",
+	"Not charging": "N",
+}
+
+// History records the battery samples of each controller in a file per MAC, one
+// "<unix time> <level> <status letter>" line per sample. Only changes and a
+// heartbeat are written, so the files stay small. It is safe for concurrent use
+// and a nil History records nothing.
+type History struct {
+	dir string
+
+	mu       sync.Mutex
+	last     map[string]Sample
+	appended map[string]int
+}
+
+// NewHistory returns a History storing its files in dir.
+func NewHistory(dir string) *History {
+	return &History{dir: dir, last: make(map[string]Sample), appended: make(map[string]int)}
+}
+
+// OpenHistory returns the History stored in the application state directory.
+func OpenHistory() (*History, error) {
+	dir, err := config.StateDir()
+	if err != nil {
+		return nil, err
+	}
+	return NewHistory(filepath.Join(dir, "battery")), nil
+}
+
+// Record adds a sample to the history of the controller, unless neither its level
+// nor its status changed since the last one recorded less than HeartbeatInterval ago.
+func (h *History) Record(mac string, s Sample) error {
+	if h == nil || mac == "" {
+		return nil
+	}
+	mac = strings.ToUpper(mac)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	last, known := h.last[mac]
+	if known && last.Level == s.Level && last.Status == s.Status && s.Time.Sub(last.Time) < HeartbeatInterval {
+		return nil
+	}
+	if !known || h.appended[mac] >= compactEvery {
+		if err := h.compact(mac, s.Time); err != nil {
+			return err
+		}
+		h.appended[mac] = 0
+	}
+
+	if err := os.MkdirAll(h.dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(h.path(mac), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(formatSample(s))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	h.last[mac] = s
+	h.appended[mac]++
+	return nil
+}
+
+// Samples returns the samples of the controller recorded since the given time, oldest first.
+func (h *History) Samples(mac string, since time.Time) ([]Sample, error) {
+	if h == nil {
+		return nil, nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	samples, err := h.read(strings.ToUpper(mac))
+	if err != nil {
+		return nil, err
+	}
+	for i, s := range samples {
+		if !s.Time.Before(since) {
+			return samples[i:], nil
+		}
+	}
+	return nil, nil
+}
+
+// compact drops the samples older than MaxHistoryAge and beyond MaxHistorySamples.
+func (h *History) compact(mac string, now time.Time) error {
+	samples, err := h.read(mac)
+	if err != nil || len(samples) == 0 {
+		return err
+	}
+
+	kept := samples
+	cutoff := now.Add(-MaxHistoryAge)
+	for len(kept) > 0 && kept[0].Time.Before(cutoff) {
+		kept = kept[1:]
+	}
+	if len(kept) > MaxHistorySamples {
+		kept = kept[len(kept)-MaxHistorySamples:]
+	}
+	if len(kept) == len(samples) {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for _, s := range kept {
+		buf.WriteString(formatSample(s))
+	}
+	tmp := h.path(mac) + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, h.path(mac))
+}
+
+// read returns every sample of the controller, skipping malformed lines.
+func (h *History) read(mac string) ([]Sample, error) {
+	f, err := os.Open(h.path(mac))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var samples []Sample
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if s, err := parseSample(scanner.Text()); err == nil {
+			samples = append(samples, s)
+		}
+	}
+	return samples, scanner.Err()
+}
+
+func (h *History) path(mac string) string {
+	return filepath.Join(h.dir, strings.ReplaceAll(mac, ":", "_")+".log")
+}
+
+func formatSample(s Sample) string {
+	code, ok := statusCodes[s.Status]
+	if !ok {
+		code = "U"
+	}
+	return fmt.Sprintf("%d %d %s\n", s.Time.Unix(), s.Level, code)
+}
+
+func parseSample(line string) (Sample, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 3 {
+		return Sample{}, fmt.Errorf("malformed battery sample %q", line)
+	}
+	unix, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return Sample{}, err
+	}
+	level, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return Sample{}, err
+	}
+
+	status := "Unknown"
+	for name, code := range statusCodes {
+		if code == fields[2] {
+			status = name
+			break
+		}
+	}
+	return Sample{Time: time.Unix(unix, 0), Level: level, Status: status}, nil
+}
@@ -0,0 +1,104 @@
+package battery
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const historyMAC = "aa:bb:cc:dd:ee:ff"
+
+func TestHistoryRecordsChangesAndHeartbeat(t *testing.T) {
+	dir := t.TempDir()
+	h := NewHistory(dir)
+	t0 := time.Unix(1700000000, 0)
+
+	readings := []Sample{
+		{t0, 80, "Discharging"},
+		{t0.Add(time.Second), 80, "Discharging"},              // unchanged
+		{t0.Add(2 * time.Minute), 79, "Discharging"},          // level change
+		{t0.Add(4 * time.Minute), 79, "Discharging"},          // unchanged
+		{t0.Add(7 * time.Minute), 79, "Discharging"},          // heartbeat
+		{t0.Add(7*time.Minute + time.Second), 79, "Charging"}, // status change
+		{t0.Add(8 * time.Minute), 80, "Weird"},
+	}
+	for _, s := range readings {
+		if err := h.Record(historyMAC, s); err != nil {
+			t.Fatalf("Record error: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "AA_BB_CC_DD_EE_FF.log"))
+	if err != nil {
+		t.Fatalf("reading history file: %v", err)
+	}
+	want := "1700000000 80 D\n1700000120 79 D\n1700000420 79 D\n1700000421 79 C\n1700000480 80 U\n"
+	if string(data) != want {
+		t.Fatalf("history file = %q, want %q", data, want)
+	}
+
+	// a new History, as after a restart, reads the same samples back
+	got, err := NewHistory(dir).Samples(strings.ToUpper(historyMAC), t0.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("Samples error: %v", err)
+	}
+	wantSamples := []Sample{
+		{time.Unix(1700000120, 0), 79, "Discharging"},
+		{time.Unix(1700000420, 0), 79, "Discharging"},
+		{time.Unix(1700000421, 0), 79, "Charging"},
+		{time.Unix(1700000480, 0), 80, "Unknown"},
+	}
+	if !reflect.DeepEqual(got, wantSamples) {
+		t.Fatalf("Samples = %+v, want %+v", got, wantSamples)
+	}
+}
+
+func TestHistoryRetention(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "AA_BB_CC_DD_EE_FF.log")
+	now := time.Unix(1700000000, 0)
+
+	var lines strings.Builder
+	old := now.Add(-MaxHistoryAge - time.Hour)
+	lines.WriteString(formatSample(Sample{old, 50, "Discharging"}))
+	lines.WriteString("garbage line\n")
+	for i := MaxHistorySamples + 5; i > 0; i-- {
+		lines.WriteString(formatSample(Sample{now.Add(-time.Duration(i) * time.Second), 60, "Discharging"}))
+	}
+	if err := os.WriteFile(path, []byte(lines.String()), 0644); err != nil {
+		t.Fatalf("writing history: %v", err)
+	}
+
+	h := NewHistory(dir)
+	if err := h.Record(historyMAC, Sample{now, 61, "Charging"}); err != nil {
+		t.Fatalf("Record error: %v", err)
+	}
+
+	samples, err := h.Samples(historyMAC, time.Time{})
+	if err != nil {
+		t.Fatalf("Samples error: %v", err)
+	}
+	// the sample past MaxHistoryAge is dropped, then the oldest beyond MaxHistorySamples
+	if len(samples) != MaxHistorySamples+1 {
+		t.Fatalf("expected %d samples, got %d", MaxHistorySamples+1, len(samples))
+	}
+	if first := samples[0].Time; !first.Equal(now.Add(-MaxHistorySamples * time.Second)) {
+		t.Fatalf("unexpected oldest sample at %v", first)
+	}
+	if last := samples[len(samples)-1]; last.Level != 61 || last.Status != "Charging" {
+		t.Fatalf("unexpected last sample %+v", last)
+	}
+}
+
+func TestNilHistory(t *testing.T) {
+	var h *History
+	if err := h.Record(historyMAC, Sample{Time: time.Now(), Level: 50}); err != nil {
+		t.Fatalf("Record error: %v", err)
+	}
+	if samples, err := h.Samples(historyMAC, time.Time{}); err != nil || samples != nil {
+		t.Fatalf("expected no samples, got %v, %v", samples, err)
+	}
+}
@@ -0,0 +1,210 @@
+package battery
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"dualsense/internal/config"
+	"dualsense/internal/service/bluetooth"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	upowerService = "org.freedesktop.UPower"
+	upowerPath    = "/org/freedesktop/UPower"
+	upowerDevice  = "org.freedesktop.UPower.Device"
+)
+
+// upowerStates maps the UPower device states to the power supply class statuses.
+var upowerStates = map[uint32]string{
+	1: "Charging",
+	2: "Discharging",
+	3: "Discharging", // empty
+	4: "Full",
+	5: "Not charging", // pending charge
+	6: "Discharging",  // pending discharge
+}
+
+// UPower reads the battery from the UPower daemon over D-Bus, matching the
+// controller by the serial or the native path of the UPower device. It works in
+// sandboxes where /sys/class/power_supply is not readable.
+type UPower struct{}
+
+var _ Battery = UPower{}
+
+// fallback reads the battery from primary, and from secondary when it fails.
+type fallback struct {
+	primary, secondary Battery
+}
+
+var _ Battery = fallback{}
+
+// UseSource selects the Source named by the battery_source setting: UPower
+// falling back to sysfs for config.BatterySourceUPower, sysfs otherwise.
+func UseSource(source string) {
+	switch source {
+	case config.BatterySourceUPower:
+		Source = fallback{primary: UPower{}, secondary: Sysfs{}}
+	default:
+		Source = Sysfs{}
+	}
+}
+
+// A controller UPower has no device for is looked up again after upowerRetry,
+// doubled after each failed lookup up to upowerMaxRetry.
+const (
+	upowerRetry    = 10 * time.Second
+	upowerMaxRetry = 5 * time.Minute
+)
+
+var errNoUPowerDevice = errors.New("no UPower device")
+
+// upower holds the system bus connection shared by the UPower reads, the UPower
+// device found for each controller MAC and the lookups that found none.
+var upower struct {
+	sync.Mutex
+	conn    *dbus.Conn
+	devices map[string]dbus.ObjectPath
+	missing map[string]upowerLookup
+}
+
+// upowerLookup is a failed UPower device lookup, retried once next is reached.
+type upowerLookup struct {
+	next  time.Time
+	delay time.Duration
+}
+
+// ActualBatteryLevel returns the percentage of the UPower device of the controller.
+func (UPower) ActualBatteryLevel(jsPath string) (int, error) {
+	props, err := upowerProperties(jsPath)
+	if err != nil {
+		return 0, err
+	}
+	percentage, ok := props["Percentage"].Value().(float64)
+	if !ok {
+		return 0, fmt.Errorf("UPower device of %s has no percentage", jsPath)
+	}
+	return int(math.Round(percentage)), nil
+}
+
+// ChargingStatus returns the state of the UPower device of the controller.
+func (UPower) ChargingStatus(jsPath string) (string, error) {
+	props, err := upowerProperties(jsPath)
+	if err != nil {
+		return "", err
+	}
+	state, _ := props["State"].Value().(uint32)
+	if status, ok := upowerStates[state]; ok {
+		return status, nil
+	}
+	return "Unknown", nil
+}
+
+// upowerProperties returns the properties of the UPower device of the controller
+// behind jsPath. The connection is dropped when the bus fails rather than UPower,
+// and opened again on the next call.
+func upowerProperties(jsPath string) (map[string]dbus.Variant, error) {
+	mac := strings.ToUpper(bluetooth.ControllerMAC(jsPath))
+	if mac == "" {
+		return nil, fmt.Errorf("no MAC address for %s", jsPath)
+	}
+
+	upower.Lock()
+	defer upower.Unlock()
+
+	if upower.conn == nil {
+		conn, err := bluetooth.ConnectSystemBus()
+		if err != nil {
+			return nil, err
+		}
+		upower.conn = conn
+		upower.devices = make(map[string]dbus.ObjectPath)
+		upower.missing = make(map[string]upowerLookup)
+	}
+
+	props, err := readUPowerDevice(upower.conn, mac)
+	var dbusErr dbus.Error
+	if err != nil && !errors.Is(err, errNoUPowerDevice) && !errors.As(err, &dbusErr) {
+		_ = upower.conn.Close()
+		upower.conn = nil
+	}
+	return props, err
+}
+
+// readUPowerDevice returns the properties of the UPower device of mac, looking
+// it up among the UPower devices the first time and after the device went away.
+// Failed lookups are retried with a backoff. upower must be locked.
+func readUPowerDevice(conn *dbus.Conn, mac string) (map[string]dbus.Variant, error) {
+	if path, ok := upower.devices[mac]; ok {
+		props, err := deviceProperties(conn, path)
+		if err != nil {
+			delete(upower.devices, mac)
+		}
+		return props, err
+	}
+
+	now := Clock.Now()
+	lookup, failed := upower.missing[mac]
+	if failed && now.Before(lookup.next) {
+		return nil, fmt.Errorf("%w for %s", errNoUPowerDevice, mac)
+	}
+
+	var paths []dbus.ObjectPath
+	err := conn.Object(upowerService, upowerPath).Call(upowerService+".EnumerateDevices", 0).Store(&paths)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		props, err := deviceProperties(conn, path)
+		if err != nil {
+			continue
+		}
+		serial, _ := props["Serial"].Value().(string)
+		nativePath, _ := props["NativePath"].Value().(string)
+		if strings.EqualFold(serial, mac) || strings.HasSuffix(strings.ToUpper(nativePath), mac) {
+			upower.devices[mac] = path
+			delete(upower.missing, mac)
+			return props, nil
+		}
+	}
+	lookup.delay = min(max(2*lookup.delay, upowerRetry), upowerMaxRetry)
+	lookup.next = now.Add(lookup.delay)
+	upower.missing[mac] = lookup
+	return nil, fmt.Errorf("%w for %s", errNoUPowerDevice, mac)
+}
+
+func deviceProperties(conn *dbus.Conn, path dbus.ObjectPath) (map[string]dbus.Variant, error) {
+	var props map[string]dbus.Variant
+	err := conn.Object(upowerService, path).Call("org.freedesktop.DBus.Properties.GetAll", 0, upowerDevice).Store(&props)
+	return props, err
+}
+
+// ActualBatteryLevel reads the level from the primary source, or the secondary one.
+func (f fallback) ActualBatteryLevel(jsPath string) (int, error) {
+	level, err := f.primary.ActualBatteryLevel(jsPath)
+	if err == nil {
+		return level, nil
+	}
+	if Debug {
+		log.Default().Println("Reading battery level from the fallback source:", err)
+	}
+	return f.secondary.ActualBatteryLevel(jsPath)
+}
+
+// ChargingStatus reads the status from the primary source, or the secondary one.
+func (f fallback) ChargingStatus(jsPath string) (string, error) {
+	status, err := f.primary.ChargingStatus(jsPath)
+	if err == nil {
+		return status, nil
+	}
+	if Debug {
+		log.Default().Println("Reading battery status from the fallback source:", err)
+	}
+	return f.secondary.ChargingStatus(jsPath)
+}
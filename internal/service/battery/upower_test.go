@@ -0,0 +1,183 @@
+package battery
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"dualsense/internal/clock"
+	"dualsense/internal/config"
+	"dualsense/internal/dbustest"
+	"dualsense/internal/service/bluetooth"
+	"dualsense/internal/sysfs"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	upowerBAT0       = dbus.ObjectPath("/org/freedesktop/UPower/devices/battery_BAT0")
+	upowerController = dbus.ObjectPath("/org/freedesktop/UPower/devices/gaming_input_ps_controller_battery_aao_bbo_cco_ddo_eeo_ff")
+)
+
+// fakeUPower serves a laptop battery and the aa:bb:cc:dd:ee:ff controller at 72%, charging.
+func fakeUPower(t *testing.T) *dbustest.Bus {
+	t.Helper()
+	devices := map[dbus.ObjectPath]map[string]dbus.Variant{
+		upowerBAT0: {
+			"NativePath": dbus.MakeVariant("BAT0"),
+			"Serial":     dbus.MakeVariant("12345"),
+			"Percentage": dbus.MakeVariant(float64(35)),
+			"State":      dbus.MakeVariant(uint32(2)),
+		},
+		upowerController: {
+			"NativePath": dbus.MakeVariant("ps-controller-battery-aa:bb:cc:dd:ee:ff"),
+			"Serial":     dbus.MakeVariant("aa:bb:cc:dd:ee:ff"),
+			"Percentage": dbus.MakeVariant(72.4),
+			"State":      dbus.MakeVariant(uint32(1)),
+		},
+	}
+
+	bus := dbustest.New()
+	bus.Handle("org.freedesktop.UPower.EnumerateDevices", func(_ *dbus.Message) ([]interface{}, *dbus.Error) {
+		return []interface{}{[]dbus.ObjectPath{upowerBAT0, upowerController}}, nil
+	})
+	bus.Handle("org.freedesktop.DBus.Properties.GetAll", func(msg *dbus.Message) ([]interface{}, *dbus.Error) {
+		path, _ := msg.Headers[dbus.FieldPath].Value().(dbus.ObjectPath)
+		props, ok := devices[path]
+		if !ok {
+			return nil, dbus.NewError("org.freedesktop.DBus.Error.UnknownObject", []interface{}{string(path)})
+		}
+		return []interface{}{props}, nil
+	})
+	useUPower(t, bus.Connect)
+	return bus
+}
+
+// useUPower selects the UPower source connecting with connect, and restores sysfs
+// and a fresh connection when the test ends.
+func useUPower(t *testing.T, connect func(...dbus.ConnOption) (*dbus.Conn, error)) {
+	t.Helper()
+	oldConnect := bluetooth.ConnectSystemBus
+	bluetooth.ConnectSystemBus = connect
+	UseSource(config.BatterySourceUPower)
+	t.Cleanup(func() {
+		upower.Lock()
+		if upower.conn != nil {
+			_ = upower.conn.Close()
+			upower.conn = nil
+		}
+		upower.Unlock()
+		bluetooth.ConnectSystemBus = oldConnect
+		UseSource(config.BatterySourceSysfs)
+	})
+}
+
+// controllerSysfs exposes js0 with the given MAC and a sysfs battery at 40%, discharging.
+func controllerSysfs(t *testing.T, mac string) {
+	t.Helper()
+	old := sysfs.FS
+	sysfs.FS = fakeFS{
+		files: map[string][]byte{
+			"/sys/class/input/js0/device/uniq":                         []byte(mac + "\n"),
+			"/sys/class/power_supply/ps-controller-battery-0/capacity": []byte("40\n"),
+			"/sys/class/power_supply/ps-controller-battery-0/status":   []byte("Discharging\n"),
+		},
+		globs: map[string][]string{
+			"/sys/class/input/js0/device/device/power_supply/ps-controller-battery-*": {"/sys/class/power_supply/ps-controller-battery-0"},
+		},
+	}
+	t.Cleanup(func() { sysfs.FS = old })
+}
+
+func readBattery(t *testing.T) (int, string) {
+	t.Helper()
+	level, err := ActualBatteryLevel("/dev/input/js0")
+	if err != nil {
+		t.Fatalf("ActualBatteryLevel error: %v", err)
+	}
+	status, err := ChargingStatus("/dev/input/js0")
+	if err != nil {
+		t.Fatalf("ChargingStatus error: %v", err)
+	}
+	return level, status
+}
+
+func TestUPowerReadsControllerDevice(t *testing.T) {
+	controllerSysfs(t, "aa:bb:cc:dd:ee:ff")
+	bus := fakeUPower(t)
+
+	for range 3 {
+		if level, status := readBattery(t); level != 72 || status != "Charging" {
+			t.Fatalf("expected 72%% charging from UPower, got %d%% %s", level, status)
+		}
+	}
+	if n := len(bus.CallsTo("org.freedesktop.UPower.EnumerateDevices")); n != 1 {
+		t.Fatalf("expected the UPower device to be looked up once, got %d lookups", n)
+	}
+}
+
+func TestUPowerFallsBackToSysfs(t *testing.T) {
+	tests := []struct {
+		name    string
+		connect func(t *testing.T)
+	}{
+		{"unknown controller", func(t *testing.T) { fakeUPower(t) }},
+		{"no system bus", func(t *testing.T) {
+			useUPower(t, func(...dbus.ConnOption) (*dbus.Conn, error) { return nil, errors.New("no bus") })
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controllerSysfs(t, "11:22:33:44:55:66")
+			tt.connect(t)
+			if level, status := readBattery(t); level != 40 || status != "Discharging" {
+				t.Fatalf("expected 40%% discharging from sysfs, got %d%% %s", level, status)
+			}
+		})
+	}
+}
+
+func TestUPowerRetriesUnknownControllerWithBackoff(t *testing.T) {
+	controllerSysfs(t, "11:22:33:44:55:66")
+	bus := fakeUPower(t)
+	var connects atomic.Int32
+	useUPower(t, func(opts ...dbus.ConnOption) (*dbus.Conn, error) {
+		connects.Add(1)
+		return bus.Connect(opts...)
+	})
+	oldClock := Clock
+	fake := clock.NewFake(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	Clock = fake
+	t.Cleanup(func() { Clock = oldClock })
+
+	lookups := func() int { return len(bus.CallsTo("org.freedesktop.UPower.EnumerateDevices")) }
+	for range 5 {
+		readBattery(t)
+		fake.Advance(time.Second)
+	}
+	if n := lookups(); n != 1 {
+		t.Fatalf("expected one lookup before the retry delay, got %d", n)
+	}
+	if n := connects.Load(); n != 1 {
+		t.Fatalf("expected the connection kept after a failed lookup, got %d connections", n)
+	}
+
+	fake.Advance(5 * time.Second)
+	readBattery(t)
+	if n := lookups(); n != 2 {
+		t.Fatalf("expected a retry after %v, got %d lookups", upowerRetry, n)
+	}
+
+	// the delay doubles after each failed lookup
+	fake.Advance(upowerRetry)
+	readBattery(t)
+	if n := lookups(); n != 2 {
+		t.Fatalf("expected no retry before %v, got %d lookups", 2*upowerRetry, n)
+	}
+	fake.Advance(upowerRetry)
+	readBattery(t)
+	if n := lookups(); n != 3 {
+		t.Fatalf("expected a retry after %v, got %d lookups", 2*upowerRetry, n)
+	}
+}
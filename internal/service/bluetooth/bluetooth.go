@@ -2,44 +2,84 @@
 package bluetooth
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/godbus/dbus/v5"
 
 	"dualsense/internal/sysfs"
 )
 
+const (
+	bluezService           = "org.bluez"
+	deviceInterface        = "org.bluez.Device1"
+	adapterInterface       = "org.bluez.Adapter1"
+	objectManagerInterface = "org.freedesktop.DBus.ObjectManager"
+	unknownObjectError     = "org.freedesktop.DBus.Error.UnknownObject"
+)
+
+var (
+	devicePathsMu sync.Mutex
+	devicePaths   = map[string]dbus.ObjectPath{}
+)
+
 // Bluetooth interface defines methods for Bluetooth operations.
 type Bluetooth interface {
 	ControllerMAC(path string) string
 	DisconnectDualSenseNative(mac string) error
+	Watch(ctx context.Context) (<-chan DeviceEvent, error)
+	StartDiscovery() error
+	StopDiscovery() error
+	Devices() ([]Device, error)
+	Pair(mac string) error
+	Trust(mac string) error
+	Connect(mac string) error
 }
 
 // ControllerMAC reads the controller MAC from sysfs for the given device path.
+// The driver sets uniq to the MAC over both USB and Bluetooth, but it is left
+// empty over USB when the pairing info could not be read, so the address is
+// tried next. The same controller has the same MAC on either transport.
 func ControllerMAC(path string) string {
 	devName := filepath.Base(path)
 
-	macPath := fmt.Sprintf("/sys/class/input/%s/device/uniq", devName)
-
-	data, err := sysfs.FS.ReadFile(macPath)
-	if err != nil {
-		macPath = fmt.Sprintf("/sys/class/input/%s/device/address", devName)
-		data, err = sysfs.FS.ReadFile(macPath)
+	for _, attribute := range []string{"uniq", "address"} {
+		macPath := fmt.Sprintf("/sys/class/input/%s/device/%s", devName, attribute)
+		data, err := sysfs.FS.ReadFile(macPath)
+		if err != nil {
+			continue
+		}
+		if mac := strings.TrimSpace(string(data)); mac != "" {
+			return strings.ToUpper(mac)
+		}
 	}
+	return ""
+}
 
+// DisconnectDualSenseNative requests BlueZ to disconnect the device with the given MAC.
+func DisconnectDualSenseNative(mac string) error {
+	conn, err := ConnectSystemBus()
 	if err != nil {
-		return ""
+		return err
 	}
+	defer func() {
+		err = conn.Close()
+		if err != nil {
+			log.Default().Println("Error closing D-Bus connection:", err)
+		}
 
-	mac := strings.TrimSpace(string(data))
-	return strings.ToUpper(mac)
+	}()
+	return callDevice(conn, mac, deviceInterface+".Disconnect")
 }
 
-// DisconnectDualSenseNative requests BlueZ to disconnect the device with the given MAC.
-func DisconnectDualSenseNative(mac string) error {
+// Connect requests BlueZ to connect the paired device with the given MAC, waking it up
+// when it is in range.
+func Connect(mac string) error {
 	conn, err := ConnectSystemBus()
 	if err != nil {
 		return err
@@ -51,11 +91,64 @@ func DisconnectDualSenseNative(mac string) error {
 		}
 
 	}()
-	dbusPath := "/org/bluez/hci0/dev_" + strings.ReplaceAll(mac, ":", "_")
-	obj := conn.Object("org.bluez", dbus.ObjectPath(dbusPath))
-	call := obj.Call("org.bluez.Device1.Disconnect", 0)
-	return call.Err
+	return callDevice(conn, mac, deviceInterface+".Connect")
+}
+
+// DevicePath resolves the BlueZ object path of the device with the given MAC,
+// whichever adapter it is paired with. Results are cached per MAC.
+func DevicePath(conn *dbus.Conn, mac string) (dbus.ObjectPath, error) {
+	mac = strings.ToUpper(mac)
+
+	devicePathsMu.Lock()
+	defer devicePathsMu.Unlock()
+	if path, ok := devicePaths[mac]; ok {
+		return path, nil
+	}
+
+	var objects map[dbus.ObjectPath]map[string]map[string]dbus.Variant
+	err := conn.Object(bluezService, "/").Call(objectManagerInterface+".GetManagedObjects", 0).Store(&objects)
+	if err != nil {
+		return "", err
+	}
+
+	for path, interfaces := range objects {
+		props, ok := interfaces[deviceInterface]
+		if !ok {
+			continue
+		}
+		address, _ := props["Address"].Value().(string)
+		if strings.EqualFold(address, mac) {
+			devicePaths[mac] = path
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("device %s not found in BlueZ", mac)
+}
+
+// callDevice calls a method on the BlueZ object of the device with the given MAC,
+// resolving its object path again if the cached one has gone stale.
+func callDevice(conn *dbus.Conn, mac, method string, args ...interface{}) error {
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		var path dbus.ObjectPath
+		path, err = DevicePath(conn, mac)
+		if err != nil {
+			return err
+		}
+		err = conn.Object(bluezService, path).Call(method, 0, args...).Err
+		var dbusErr dbus.Error
+		if !errors.As(err, &dbusErr) || dbusErr.Name != unknownObjectError {
+			return err
+		}
+		forgetDevicePath(mac)
+	}
+	return err
+}
 
+func forgetDevicePath(mac string) {
+	devicePathsMu.Lock()
+	defer devicePathsMu.Unlock()
+	delete(devicePaths, strings.ToUpper(mac))
 }
 
 // ConnectSystemBus is a hook for tests to override D-Bus connection behavior.
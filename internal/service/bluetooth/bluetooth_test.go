@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"dualsense/internal/dbustest"
 	"dualsense/internal/sysfs"
 
 	"github.com/godbus/dbus/v5"
@@ -49,6 +50,13 @@ func TestControllerMAC_UniqAndAddress(t *testing.T) {
 	if mac2 != "11:22:33:44:55:66" {
 		t.Fatalf("unexpected mac2: %s", mac2)
 	}
+
+	// fallback to address when uniq is empty, as over USB without pairing info
+	fake2.files[filepath.Join("/sys/class/input", "js1", "device", "uniq")] = []byte("\n")
+	mac3 := ControllerMAC("/dev/input/js1")
+	if mac3 != "11:22:33:44:55:66" {
+		t.Fatalf("unexpected mac3: %s", mac3)
+	}
 }
 
 func TestDisconnectDualSenseNative_Error(t *testing.T) {
@@ -62,3 +70,129 @@ func TestDisconnectDualSenseNative_Error(t *testing.T) {
 		t.Fatalf("expected error from DisconnectDualSenseNative when bus unavailable")
 	}
 }
+
+// fakeBlueZ serves GetManagedObjects with the given device paths keyed by address.
+func fakeBlueZ(devices map[string]dbus.ObjectPath) *dbustest.Bus {
+	bus := dbustest.New()
+	bus.Handle("org.freedesktop.DBus.ObjectManager.GetManagedObjects", func(_ *dbus.Message) ([]interface{}, *dbus.Error) {
+		objects := map[dbus.ObjectPath]map[string]map[string]dbus.Variant{
+			"/org/bluez/hci0": {
+				"org.bluez.Adapter1": {"Address": dbus.MakeVariant("00:1A:7D:DA:71:13")},
+			},
+		}
+		for address, path := range devices {
+			objects[path] = map[string]map[string]dbus.Variant{
+				"org.bluez.Device1": {
+					"Address":  dbus.MakeVariant(address),
+					"Name":     dbus.MakeVariant("DualSense Wireless Controller"),
+					"Modalias": dbus.MakeVariant("usb:v054Cp0CE6d0100"),
+				},
+			}
+		}
+		return []interface{}{objects}, nil
+	})
+	bus.Handle("org.bluez.Device1.Disconnect", func(msg *dbus.Message) ([]interface{}, *dbus.Error) {
+		path := msg.Headers[dbus.FieldPath].Value().(dbus.ObjectPath)
+		for _, p := range devices {
+			if p == path {
+				return nil, nil
+			}
+		}
+		return nil, dbus.NewError("org.freedesktop.DBus.Error.UnknownObject", []interface{}{"Method \"Disconnect\" doesn't exist"})
+	})
+	return bus
+}
+
+func useBus(t *testing.T, bus *dbustest.Bus) {
+	t.Helper()
+	old := ConnectSystemBus
+	ConnectSystemBus = bus.Connect
+	devicePaths = map[string]dbus.ObjectPath{}
+	t.Cleanup(func() {
+		ConnectSystemBus = old
+		devicePaths = map[string]dbus.ObjectPath{}
+	})
+}
+
+func TestDisconnectDualSenseNative_ResolvesAdapter(t *testing.T) {
+	bus := fakeBlueZ(map[string]dbus.ObjectPath{
+		"AA:BB:CC:DD:EE:FF": "/org/bluez/hci1/dev_AA_BB_CC_DD_EE_FF",
+		"11:22:33:44:55:66": "/org/bluez/hci0/dev_11_22_33_44_55_66",
+	})
+	useBus(t, bus)
+
+	if err := DisconnectDualSenseNative("aa:bb:cc:dd:ee:ff"); err != nil {
+		t.Fatalf("DisconnectDualSenseNative error: %v", err)
+	}
+	if err := DisconnectDualSenseNative("AA:BB:CC:DD:EE:FF"); err != nil {
+		t.Fatalf("DisconnectDualSenseNative error: %v", err)
+	}
+
+	calls := bus.CallsTo("org.bluez.Device1.Disconnect")
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 Disconnect calls, got %d", len(calls))
+	}
+	for _, c := range calls {
+		if c.Path != "/org/bluez/hci1/dev_AA_BB_CC_DD_EE_FF" {
+			t.Fatalf("Disconnect called on %s", c.Path)
+		}
+	}
+	// the object path is cached per MAC
+	if n := len(bus.CallsTo("org.freedesktop.DBus.ObjectManager.GetManagedObjects")); n != 1 {
+		t.Fatalf("expected 1 GetManagedObjects call, got %d", n)
+	}
+}
+
+func TestDisconnectDualSenseNative_UnknownDevice(t *testing.T) {
+	bus := fakeBlueZ(map[string]dbus.ObjectPath{
+		"11:22:33:44:55:66": "/org/bluez/hci0/dev_11_22_33_44_55_66",
+	})
+	useBus(t, bus)
+
+	if err := DisconnectDualSenseNative("AA:BB:CC:DD:EE:FF"); err == nil {
+		t.Fatalf("expected error for a device unknown to BlueZ")
+	}
+	if n := len(bus.CallsTo("org.bluez.Device1.Disconnect")); n != 0 {
+		t.Fatalf("expected no Disconnect call, got %d", n)
+	}
+}
+
+func TestDisconnectDualSenseNative_StaleCache(t *testing.T) {
+	bus := fakeBlueZ(map[string]dbus.ObjectPath{
+		"AA:BB:CC:DD:EE:FF": "/org/bluez/hci1/dev_AA_BB_CC_DD_EE_FF",
+	})
+	useBus(t, bus)
+	// controller was re-paired on another adapter since the path was cached
+	devicePaths["AA:BB:CC:DD:EE:FF"] = "/org/bluez/hci0/dev_AA_BB_CC_DD_EE_FF"
+
+	if err := DisconnectDualSenseNative("AA:BB:CC:DD:EE:FF"); err != nil {
+		t.Fatalf("DisconnectDualSenseNative error: %v", err)
+	}
+
+	calls := bus.CallsTo("org.bluez.Device1.Disconnect")
+	if len(calls) != 2 || calls[1].Path != "/org/bluez/hci1/dev_AA_BB_CC_DD_EE_FF" {
+		t.Fatalf("expected retry on the resolved path, got %+v", calls)
+	}
+	if devicePaths["AA:BB:CC:DD:EE:FF"] != "/org/bluez/hci1/dev_AA_BB_CC_DD_EE_FF" {
+		t.Fatalf("cache not refreshed: %v", devicePaths)
+	}
+}
+
+func TestConnect(t *testing.T) {
+	bus := fakeBlueZ(map[string]dbus.ObjectPath{
+		"AA:BB:CC:DD:EE:FF": "/org/bluez/hci0/dev_AA_BB_CC_DD_EE_FF",
+	})
+	bus.Handle("org.bluez.Device1.Connect", func(_ *dbus.Message) ([]interface{}, *dbus.Error) { return nil, nil })
+	useBus(t, bus)
+
+	if err := Connect("AA:BB:CC:DD:EE:FF"); err != nil {
+		t.Fatalf("Connect error: %v", err)
+	}
+	calls := bus.CallsTo("org.bluez.Device1.Connect")
+	if len(calls) != 1 || calls[0].Path != "/org/bluez/hci0/dev_AA_BB_CC_DD_EE_FF" {
+		t.Fatalf("unexpected Connect calls: %+v", calls)
+	}
+	if err := Connect("00:00:00:00:00:01"); err == nil {
+		t.Fatalf("expected error for unknown device")
+	}
+}
@@ -0,0 +1,264 @@
+package bluetooth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"dualsense/internal/service/discovery"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// ControllerName is the Bluetooth name advertised by DualSense controllers.
+const ControllerName = "DualSense Wireless Controller"
+
+// controllerNames maps the Bluetooth names of the supported controllers to their
+// model. The DualShock 4 advertises the generic "Wireless Controller" and is
+// only recognised from its Modalias.
+var controllerNames = map[string]discovery.Model{
+	ControllerName:                       discovery.ModelDualSense,
+	"DualSense Edge Wireless Controller": discovery.ModelDualSenseEdge,
+}
+
+const alreadyExistsError = "org.bluez.Error.AlreadyExists"
+
+// PairInterval is how often PairNew looks for newly discovered controllers.
+var PairInterval = time.Second
+
+// Device is a Bluetooth device known to BlueZ.
+type Device struct {
+	MAC       string
+	Name      string
+	Modalias  string
+	Paired    bool
+	Trusted   bool
+	Connected bool
+}
+
+// BlueZ implements Bluetooth over a single system bus connection. Discovery
+// started through it lasts until StopDiscovery or Close, as BlueZ stops the
+// discovery sessions of clients leaving the bus.
+type BlueZ struct {
+	conn *dbus.Conn
+}
+
+// NewBlueZ connects to the system bus.
+func NewBlueZ() (*BlueZ, error) {
+	conn, err := ConnectSystemBus()
+	if err != nil {
+		return nil, err
+	}
+	return &BlueZ{conn: conn}, nil
+}
+
+// Close closes the system bus connection.
+func (b *BlueZ) Close() error {
+	return b.conn.Close()
+}
+
+// ControllerMAC reads the controller MAC from sysfs for the given device path.
+func (b *BlueZ) ControllerMAC(path string) string {
+	return ControllerMAC(path)
+}
+
+// DisconnectDualSenseNative requests BlueZ to disconnect the device with the given MAC.
+func (b *BlueZ) DisconnectDualSenseNative(mac string) error {
+	return callDevice(b.conn, mac, deviceInterface+".Disconnect")
+}
+
+// Watch publishes BlueZ device property transitions until ctx is cancelled.
+func (b *BlueZ) Watch(ctx context.Context) (<-chan DeviceEvent, error) {
+	return Watch(ctx)
+}
+
+// StartDiscovery starts device discovery on the Bluetooth adapter.
+func (b *BlueZ) StartDiscovery() error {
+	adapter, err := b.adapterPath()
+	if err != nil {
+		return err
+	}
+	return b.conn.Object(bluezService, adapter).Call(adapterInterface+".StartDiscovery", 0).Err
+}
+
+// StopDiscovery stops device discovery on the Bluetooth adapter.
+func (b *BlueZ) StopDiscovery() error {
+	adapter, err := b.adapterPath()
+	if err != nil {
+		return err
+	}
+	return b.conn.Object(bluezService, adapter).Call(adapterInterface+".StopDiscovery", 0).Err
+}
+
+// Devices lists the devices known to BlueZ, discovered or paired.
+func (b *BlueZ) Devices() ([]Device, error) {
+	objects, err := b.managedObjects()
+	if err != nil {
+		return nil, err
+	}
+
+	var devices []Device
+	for _, interfaces := range objects {
+		props, ok := interfaces[deviceInterface]
+		if !ok {
+			continue
+		}
+		var d Device
+		address, _ := props["Address"].Value().(string)
+		d.MAC = strings.ToUpper(address)
+		d.Name, _ = props["Name"].Value().(string)
+		d.Modalias, _ = props["Modalias"].Value().(string)
+		d.Paired, _ = props["Paired"].Value().(bool)
+		d.Trusted, _ = props["Trusted"].Value().(bool)
+		d.Connected, _ = props["Connected"].Value().(bool)
+		devices = append(devices, d)
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].MAC < devices[j].MAC })
+	return devices, nil
+}
+
+// Model returns the controller model of the device from the vendor and product
+// IDs in its Modalias, e.g. "usb:v054Cp0CE6d0100", falling back to its name.
+// Devices that are not a supported controller are ModelUnknown.
+func (d Device) Model() discovery.Model {
+	if model := modaliasModel(d.Modalias); model != discovery.ModelUnknown {
+		return model
+	}
+	return controllerNames[d.Name]
+}
+
+func modaliasModel(modalias string) discovery.Model {
+	_, ids, ok := strings.Cut(modalias, ":")
+	if !ok || len(ids) < 10 || ids[0] != 'v' || ids[5] != 'p' {
+		return discovery.ModelUnknown
+	}
+	vendor, err := strconv.ParseUint(ids[1:5], 16, 16)
+	if err != nil {
+		return discovery.ModelUnknown
+	}
+	product, err := strconv.ParseUint(ids[6:10], 16, 16)
+	if err != nil {
+		return discovery.ModelUnknown
+	}
+	return discovery.ModelForIDs(uint16(vendor), uint16(product))
+}
+
+// Pair pairs the device with the given MAC. A device already paired is not an error.
+func (b *BlueZ) Pair(mac string) error {
+	err := callDevice(b.conn, mac, deviceInterface+".Pair")
+	var dbusErr dbus.Error
+	if errors.As(err, &dbusErr) && dbusErr.Name == alreadyExistsError {
+		return nil
+	}
+	return err
+}
+
+// Trust marks the device with the given MAC as trusted so that it may reconnect on its own.
+func (b *BlueZ) Trust(mac string) error {
+	return callDevice(b.conn, mac, propertiesInterface+".Set", deviceInterface, "Trusted", dbus.MakeVariant(true))
+}
+
+// Connect connects the device with the given MAC.
+func (b *BlueZ) Connect(mac string) error {
+	return callDevice(b.conn, mac, deviceInterface+".Connect")
+}
+
+func (b *BlueZ) managedObjects() (map[dbus.ObjectPath]map[string]map[string]dbus.Variant, error) {
+	var objects map[dbus.ObjectPath]map[string]map[string]dbus.Variant
+	err := b.conn.Object(bluezService, "/").Call(objectManagerInterface+".GetManagedObjects", 0).Store(&objects)
+	return objects, err
+}
+
+// adapterPath returns the object path of the first Bluetooth adapter.
+func (b *BlueZ) adapterPath() (dbus.ObjectPath, error) {
+	objects, err := b.managedObjects()
+	if err != nil {
+		return "", err
+	}
+
+	var adapters []string
+	for path, interfaces := range objects {
+		if _, ok := interfaces[adapterInterface]; ok {
+			adapters = append(adapters, string(path))
+		}
+	}
+	if len(adapters) == 0 {
+		return "", errors.New("no Bluetooth adapter found")
+	}
+	sort.Strings(adapters)
+	return dbus.ObjectPath(adapters[0]), nil
+}
+
+// PairNew searches for a supported controller in pairing mode until ctx is done, then pairs,
+// trusts and connects it. It returns the MAC of the new controller. Progress
+// messages meant for the user are passed to progress.
+func PairNew(ctx context.Context, bt Bluetooth, progress func(string)) (string, error) {
+	if err := bt.StartDiscovery(); err != nil {
+		return "", fmt.Errorf("starting discovery: %w", err)
+	}
+	defer func() {
+		if err := bt.StopDiscovery(); err != nil {
+			log.Default().Println("Error stopping discovery:", err)
+		}
+	}()
+
+	progress("Searching… hold PS and Create (Share on a DualShock 4) until the lightbar flashes")
+
+	ticker := time.NewTicker(PairInterval)
+	defer ticker.Stop()
+
+	for {
+		devices, err := bt.Devices()
+		if err != nil {
+			return "", fmt.Errorf("listing devices: %w", err)
+		}
+		for _, d := range devices {
+			if d.Paired || d.Model() == discovery.ModelUnknown {
+				continue
+			}
+			return d.MAC, pairDevice(bt, d.MAC, progress)
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("no controller in pairing mode found: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+func pairDevice(bt Bluetooth, mac string, progress func(string)) error {
+	progress("Pairing " + mac + "…")
+	if err := bt.Pair(mac); err != nil {
+		return fmt.Errorf("pairing %s: %w", mac, err)
+	}
+	progress("Trusting " + mac + "…")
+	if err := bt.Trust(mac); err != nil {
+		return fmt.Errorf("trusting %s: %w", mac, err)
+	}
+	progress("Connecting " + mac + "…")
+	if err := bt.Connect(mac); err != nil {
+		return fmt.Errorf("connecting %s: %w", mac, err)
+	}
+	progress("Controller " + mac + " paired")
+	return nil
+}
+
+// PairNewController runs PairNew over the system bus.
+func PairNewController(ctx context.Context, progress func(string)) (string, error) {
+	bt, err := NewBlueZ()
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err := bt.Close(); err != nil {
+			log.Default().Println("Error closing D-Bus connection:", err)
+		}
+	}()
+	return PairNew(ctx, bt, progress)
+}
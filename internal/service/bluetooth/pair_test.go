@@ -0,0 +1,227 @@
+package bluetooth
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"dualsense/internal/service/discovery"
+
+	"github.com/godbus/dbus/v5"
+)
+
+var _ Bluetooth = (*BlueZ)(nil)
+
+// fakeBluetooth is an in-memory BlueZ whose controller shows up after a few Devices calls.
+type fakeBluetooth struct {
+	mu       sync.Mutex
+	devices  []Device
+	appearAt int
+	listed   int
+	calls    []string
+	pairErr  error
+}
+
+func (f *fakeBluetooth) record(call string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, call)
+}
+
+func (f *fakeBluetooth) ControllerMAC(_ string) string { return "" }
+func (f *fakeBluetooth) DisconnectDualSenseNative(mac string) error {
+	f.record("Disconnect " + mac)
+	return nil
+}
+func (f *fakeBluetooth) Watch(_ context.Context) (<-chan DeviceEvent, error) {
+	return nil, errors.New("not implemented")
+}
+func (f *fakeBluetooth) StartDiscovery() error { f.record("StartDiscovery"); return nil }
+func (f *fakeBluetooth) StopDiscovery() error  { f.record("StopDiscovery"); return nil }
+func (f *fakeBluetooth) Devices() ([]Device, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.listed++
+	if f.listed < f.appearAt {
+		return nil, nil
+	}
+	return f.devices, nil
+}
+func (f *fakeBluetooth) Pair(mac string) error    { f.record("Pair " + mac); return f.pairErr }
+func (f *fakeBluetooth) Trust(mac string) error   { f.record("Trust " + mac); return nil }
+func (f *fakeBluetooth) Connect(mac string) error { f.record("Connect " + mac); return nil }
+
+func usePairInterval(t *testing.T) {
+	t.Helper()
+	old := PairInterval
+	PairInterval = time.Millisecond
+	t.Cleanup(func() { PairInterval = old })
+}
+
+func TestPairNew(t *testing.T) {
+	usePairInterval(t)
+	bt := &fakeBluetooth{
+		appearAt: 3,
+		devices: []Device{
+			{MAC: "11:11:11:11:11:11", Name: "Headphones"},
+			{MAC: "22:22:22:22:22:22", Name: "DualSense Wireless Controller", Paired: true},
+			{MAC: "33:33:33:33:33:33", Name: "DualSense Wireless Controller"},
+		},
+	}
+
+	var messages []string
+	mac, err := PairNew(context.Background(), bt, func(msg string) { messages = append(messages, msg) })
+	if err != nil {
+		t.Fatalf("PairNew error: %v", err)
+	}
+	if mac != "33:33:33:33:33:33" {
+		t.Fatalf("expected unpaired controller to be paired, got %s", mac)
+	}
+
+	want := []string{
+		"StartDiscovery",
+		"Pair 33:33:33:33:33:33",
+		"Trust 33:33:33:33:33:33",
+		"Connect 33:33:33:33:33:33",
+		"StopDiscovery",
+	}
+	if !reflect.DeepEqual(bt.calls, want) {
+		t.Fatalf("unexpected calls:\n got %v\nwant %v", bt.calls, want)
+	}
+	if len(messages) == 0 || messages[len(messages)-1] != "Controller 33:33:33:33:33:33 paired" {
+		t.Fatalf("unexpected progress messages: %v", messages)
+	}
+}
+
+func TestDeviceModel(t *testing.T) {
+	tests := []struct {
+		device Device
+		want   discovery.Model
+	}{
+		{Device{Name: "DualSense Wireless Controller"}, discovery.ModelDualSense},
+		{Device{Name: "DualSense Edge Wireless Controller"}, discovery.ModelDualSenseEdge},
+		{Device{Name: "Wireless Controller", Modalias: "usb:v054Cp05C4d0100"}, discovery.ModelDualShock4},
+		{Device{Name: "Wireless Controller", Modalias: "bluetooth:v054Cp09CCd0100"}, discovery.ModelDualShock4V2},
+		{Device{Name: "Wireless Controller", Modalias: "usb:v045Ep02E0d0903"}, discovery.ModelUnknown},
+		{Device{Name: "Wireless Controller"}, discovery.ModelUnknown},
+		{Device{Name: "Headphones", Modalias: "bluetooth:v004Cp"}, discovery.ModelUnknown},
+	}
+	for _, tt := range tests {
+		if got := tt.device.Model(); got != tt.want {
+			t.Errorf("%+v: expected %v, got %v", tt.device, tt.want, got)
+		}
+	}
+}
+
+func TestPairNew_DualShock4(t *testing.T) {
+	usePairInterval(t)
+	bt := &fakeBluetooth{devices: []Device{
+		{MAC: "11:11:11:11:11:11", Name: "Wireless Controller", Modalias: "usb:v045Ep02E0d0903"},
+		{MAC: "44:44:44:44:44:44", Name: "Wireless Controller", Modalias: "usb:v054Cp09CCd0100"},
+	}}
+
+	mac, err := PairNew(context.Background(), bt, func(string) {})
+	if err != nil {
+		t.Fatalf("PairNew error: %v", err)
+	}
+	if mac != "44:44:44:44:44:44" {
+		t.Fatalf("expected the DualShock 4 to be paired, got %s", mac)
+	}
+}
+
+func TestPairNew_Timeout(t *testing.T) {
+	usePairInterval(t)
+	bt := &fakeBluetooth{devices: []Device{{MAC: "11:11:11:11:11:11", Name: "Keyboard"}}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := PairNew(ctx, bt, func(string) {})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+	if bt.calls[len(bt.calls)-1] != "StopDiscovery" {
+		t.Fatalf("expected discovery to be stopped, got calls %v", bt.calls)
+	}
+}
+
+func TestPairNew_PairError(t *testing.T) {
+	usePairInterval(t)
+	bt := &fakeBluetooth{
+		devices: []Device{{MAC: "33:33:33:33:33:33", Name: "DualSense Wireless Controller"}},
+		pairErr: errors.New("authentication failed"),
+	}
+
+	_, err := PairNew(context.Background(), bt, func(string) {})
+	if err == nil {
+		t.Fatalf("expected pairing error")
+	}
+	for _, call := range bt.calls {
+		if call == "Trust 33:33:33:33:33:33" || call == "Connect 33:33:33:33:33:33" {
+			t.Fatalf("unexpected %q after pairing failure", call)
+		}
+	}
+}
+
+func TestBlueZ(t *testing.T) {
+	mac := "AA:BB:CC:DD:EE:FF"
+	dev := dbus.ObjectPath("/org/bluez/hci0/dev_AA_BB_CC_DD_EE_FF")
+	bus := fakeBlueZ(map[string]dbus.ObjectPath{mac: dev})
+	for _, method := range []string{
+		"org.bluez.Adapter1.StartDiscovery",
+		"org.bluez.Adapter1.StopDiscovery",
+		"org.bluez.Device1.Connect",
+		"org.freedesktop.DBus.Properties.Set",
+	} {
+		bus.Handle(method, func(_ *dbus.Message) ([]interface{}, *dbus.Error) { return nil, nil })
+	}
+	bus.Handle("org.bluez.Device1.Pair", func(_ *dbus.Message) ([]interface{}, *dbus.Error) {
+		return nil, dbus.NewError("org.bluez.Error.AlreadyExists", []interface{}{"Already Exists"})
+	})
+	useBus(t, bus)
+
+	bt, err := NewBlueZ()
+	if err != nil {
+		t.Fatalf("NewBlueZ error: %v", err)
+	}
+	defer func() { _ = bt.Close() }()
+
+	if err := bt.StartDiscovery(); err != nil {
+		t.Fatalf("StartDiscovery error: %v", err)
+	}
+	calls := bus.CallsTo("org.bluez.Adapter1.StartDiscovery")
+	if len(calls) != 1 || calls[0].Path != "/org/bluez/hci0" {
+		t.Fatalf("expected StartDiscovery on hci0, got %+v", calls)
+	}
+
+	devices, err := bt.Devices()
+	if err != nil {
+		t.Fatalf("Devices error: %v", err)
+	}
+	if len(devices) != 1 || devices[0].MAC != mac || devices[0].Name != ControllerName ||
+		devices[0].Model() != discovery.ModelDualSense {
+		t.Fatalf("unexpected devices: %+v", devices)
+	}
+
+	if err := bt.Pair(mac); err != nil {
+		t.Fatalf("expected already paired device to be accepted, got %v", err)
+	}
+	if err := bt.Trust(mac); err != nil {
+		t.Fatalf("Trust error: %v", err)
+	}
+	set := bus.CallsTo("org.freedesktop.DBus.Properties.Set")
+	if len(set) != 1 || set[0].Path != dev || set[0].Body[0] != "org.bluez.Device1" || set[0].Body[1] != "Trusted" {
+		t.Fatalf("unexpected Trusted property call: %+v", set)
+	}
+	if v, ok := set[0].Body[2].(dbus.Variant); !ok || v.Value() != true {
+		t.Fatalf("expected Trusted to be set to true, got %+v", set[0].Body[2])
+	}
+	if err := bt.Connect(mac); err != nil {
+		t.Fatalf("Connect error: %v", err)
+	}
+	if err := bt.StopDiscovery(); err != nil {
+		t.Fatalf("StopDiscovery error: %v", err)
+	}
+}
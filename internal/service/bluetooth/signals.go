@@ -0,0 +1,127 @@
+package bluetooth
+
+import (
+	"context"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const propertiesInterface = "org.freedesktop.DBus.Properties"
+
+// EventKind identifies the device property transition reported by a DeviceEvent.
+type EventKind int
+
+// Device property transitions published by Watch.
+const (
+	EventConnected EventKind = iota
+	EventDisconnected
+	EventServicesResolved
+	EventRSSI
+)
+
+// DeviceEvent reports a property change of a BlueZ device.
+type DeviceEvent struct {
+	MAC      string
+	Kind     EventKind
+	Resolved bool
+	RSSI     int16
+}
+
+// Watch subscribes to PropertiesChanged signals of org.bluez.Device1 objects and
+// publishes Connected, ServicesResolved and RSSI transitions until ctx is cancelled.
+func Watch(ctx context.Context) (<-chan DeviceEvent, error) {
+	conn, err := ConnectSystemBus()
+	if err != nil {
+		return nil, err
+	}
+
+	err = conn.AddMatchSignal(
+		dbus.WithMatchInterface(propertiesInterface),
+		dbus.WithMatchMember("PropertiesChanged"),
+		dbus.WithMatchArg(0, deviceInterface),
+	)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+
+	events := make(chan DeviceEvent)
+	go func() {
+		defer close(events)
+		defer func() {
+			if err := conn.Close(); err != nil {
+				log.Default().Println("Error closing D-Bus connection:", err)
+			}
+		}()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig, ok := <-signals:
+				if !ok {
+					return
+				}
+				for _, ev := range parsePropertiesChanged(sig) {
+					select {
+					case events <- ev:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// parsePropertiesChanged converts a Device1 PropertiesChanged signal into device events.
+func parsePropertiesChanged(sig *dbus.Signal) []DeviceEvent {
+	if sig.Name != propertiesInterface+".PropertiesChanged" || len(sig.Body) < 2 {
+		return nil
+	}
+	if iface, _ := sig.Body[0].(string); iface != deviceInterface {
+		return nil
+	}
+	changed, ok := sig.Body[1].(map[string]dbus.Variant)
+	if !ok {
+		return nil
+	}
+	mac := macFromPath(sig.Path)
+	if mac == "" {
+		return nil
+	}
+
+	var events []DeviceEvent
+	if v, ok := changed["Connected"].Value().(bool); ok {
+		kind := EventDisconnected
+		if v {
+			kind = EventConnected
+		}
+		events = append(events, DeviceEvent{MAC: mac, Kind: kind})
+	}
+	if v, ok := changed["ServicesResolved"].Value().(bool); ok {
+		events = append(events, DeviceEvent{MAC: mac, Kind: EventServicesResolved, Resolved: v})
+	}
+	if v, ok := changed["RSSI"].Value().(int16); ok {
+		events = append(events, DeviceEvent{MAC: mac, Kind: EventRSSI, RSSI: v})
+	}
+	return events
+}
+
+// macFromPath extracts the MAC address from a BlueZ device path such as
+// /org/bluez/hci0/dev_AA_BB_CC_DD_EE_FF.
+func macFromPath(objectPath dbus.ObjectPath) string {
+	base := path.Base(string(objectPath))
+	if !strings.HasPrefix(base, "dev_") {
+		return ""
+	}
+	return strings.ReplaceAll(strings.TrimPrefix(base, "dev_"), "_", ":")
+}
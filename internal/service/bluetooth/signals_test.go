@@ -0,0 +1,108 @@
+package bluetooth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"dualsense/internal/dbustest"
+
+	"github.com/godbus/dbus/v5"
+)
+
+func propertiesChanged(bus *dbustest.Bus, path dbus.ObjectPath, iface string, changed map[string]dbus.Variant) {
+	bus.Emit(path, "org.freedesktop.DBus.Properties.PropertiesChanged", iface, changed, []string{})
+}
+
+func expectDeviceEvent(t *testing.T, events <-chan DeviceEvent, want DeviceEvent) {
+	t.Helper()
+	select {
+	case got := <-events:
+		if got != want {
+			t.Fatalf("expected event %+v, got %+v", want, got)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for event %+v", want)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	bus := dbustest.New()
+	useBus(t, bus)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := Watch(ctx)
+	if err != nil {
+		t.Fatalf("Watch error: %v", err)
+	}
+
+	dev := dbus.ObjectPath("/org/bluez/hci1/dev_AA_BB_CC_DD_EE_FF")
+
+	propertiesChanged(bus, dev, "org.bluez.Device1", map[string]dbus.Variant{
+		"Connected": dbus.MakeVariant(false),
+	})
+	expectDeviceEvent(t, events, DeviceEvent{MAC: "AA:BB:CC:DD:EE:FF", Kind: EventDisconnected})
+
+	// changes of other interfaces and unrelated properties are ignored
+	propertiesChanged(bus, "/org/bluez/hci1", "org.bluez.Adapter1", map[string]dbus.Variant{
+		"Discovering": dbus.MakeVariant(true),
+	})
+	propertiesChanged(bus, dev, "org.bluez.Device1", map[string]dbus.Variant{
+		"Trusted": dbus.MakeVariant(true),
+	})
+
+	propertiesChanged(bus, dev, "org.bluez.Device1", map[string]dbus.Variant{
+		"Connected": dbus.MakeVariant(true),
+	})
+	expectDeviceEvent(t, events, DeviceEvent{MAC: "AA:BB:CC:DD:EE:FF", Kind: EventConnected})
+
+	propertiesChanged(bus, dev, "org.bluez.Device1", map[string]dbus.Variant{
+		"ServicesResolved": dbus.MakeVariant(true),
+	})
+	expectDeviceEvent(t, events, DeviceEvent{MAC: "AA:BB:CC:DD:EE:FF", Kind: EventServicesResolved, Resolved: true})
+
+	propertiesChanged(bus, dev, "org.bluez.Device1", map[string]dbus.Variant{
+		"RSSI": dbus.MakeVariant(int16(-61)),
+	})
+	expectDeviceEvent(t, events, DeviceEvent{MAC: "AA:BB:CC:DD:EE:FF", Kind: EventRSSI, RSSI: -61})
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Fatalf("expected events channel to be closed after cancel")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Watch did not stop after cancel")
+	}
+}
+
+func TestParsePropertiesChanged_MultipleProperties(t *testing.T) {
+	sig := &dbus.Signal{
+		Path: "/org/bluez/hci0/dev_11_22_33_44_55_66",
+		Name: "org.freedesktop.DBus.Properties.PropertiesChanged",
+		Body: []interface{}{
+			"org.bluez.Device1",
+			map[string]dbus.Variant{
+				"Connected":        dbus.MakeVariant(false),
+				"ServicesResolved": dbus.MakeVariant(false),
+			},
+			[]string{},
+		},
+	}
+
+	events := parsePropertiesChanged(sig)
+	want := []DeviceEvent{
+		{MAC: "11:22:33:44:55:66", Kind: EventDisconnected},
+		{MAC: "11:22:33:44:55:66", Kind: EventServicesResolved, Resolved: false},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %+v", len(want), events)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Fatalf("event %d: want %+v got %+v", i, want[i], events[i])
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package service
+
+import (
+	"context"
+	"dualsense/internal/config"
+	"dualsense/internal/hidraw"
+	"dualsense/internal/service/battery"
+	"dualsense/internal/service/bluetooth"
+	"dualsense/internal/service/discovery"
+	"dualsense/internal/service/drift"
+	"dualsense/internal/service/rumble"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FindControllers returns the device paths of the connected controllers matching mac,
+// or of every connected controller when mac is empty.
+func FindControllers(mac string) ([]string, error) {
+	paths, err := Discovery.FindAllDualSense()
+	if err != nil {
+		return nil, err
+	}
+
+	var found []string
+	for _, path := range paths {
+		if mac == "" || strings.EqualFold(bluetooth.ControllerMAC(path), mac) {
+			found = append(found, path)
+		}
+	}
+	if len(found) == 0 {
+		if mac == "" {
+			return nil, fmt.Errorf("no controller connected")
+		}
+		return nil, fmt.Errorf("controller %s not connected", mac)
+	}
+	return found, nil
+}
+
+// TestRumble plays the rumble test pattern on the matching controllers, after
+// applying each controller rumble scale as its power limit.
+func TestRumble(ctx context.Context, conf *config.Config, mac string) error {
+	paths, err := FindControllers(mac)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if model := discovery.ControllerModel(path); model.IsDualShock4() {
+			log.Default().Printf("Skipping %s at %s: rumble is not supported\n", model, path)
+			continue
+		}
+		ctrlConf := conf.ControllerConfig(bluetooth.ControllerMAC(path))
+		if err := rumble.SetPowerLimit(path, ctrlConf.RumbleScale); err != nil {
+			return err
+		}
+		if err := rumble.Play(ctx, path, rumble.TestPattern); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CalibrationResult is the drift analysis of the resting sticks of a controller.
+type CalibrationResult struct {
+	MAC string
+	// Deadzones are the deadzones currently configured for the controller.
+	Deadzones config.Deadzones
+	Report    drift.Report
+}
+
+// Calibrate records the resting sticks of the matching controllers for duration and
+// returns their drift analysis. The sticks must not be touched during the capture.
+func Calibrate(ctx context.Context, conf *config.Config, mac string, duration time.Duration) ([]CalibrationResult, error) {
+	paths, err := FindControllers(mac)
+	if err != nil {
+		return nil, err
+	}
+
+	captureCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]CalibrationResult, len(paths))
+	analyzers := make([]*drift.Analyzer, len(paths))
+	var wg sync.WaitGroup
+	for i, path := range paths {
+		controllerMAC := bluetooth.ControllerMAC(path)
+		ctrlConf := conf.ControllerConfig(controllerMAC)
+		results[i] = CalibrationResult{MAC: controllerMAC, Deadzones: ctrlConf.Deadzones}
+		analyzers[i] = drift.NewAnalyzer()
+
+		// Activity is irrelevant here, it is drained so the monitor keeps reading.
+		activityChan := make(chan time.Time)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			MonitorInput(captureCtx, path, activityChan, ctrlConf, analyzers[i], nil)
+		}()
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-activityChan:
+				case <-captureCtx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-Clock.After(duration):
+	}
+	cancel()
+	wg.Wait()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	for i := range results {
+		results[i].Report = analyzers[i].Report()
+	}
+	return results, nil
+}
+
+// BatteryReport is the battery state of a connected controller.
+type BatteryReport struct {
+	MAC       string
+	Transport discovery.Transport
+	Level     int
+	Status    string
+	// Estimate is the time remaining, valid when Estimated is set.
+	Estimate  battery.Estimate
+	Estimated bool
+}
+
+// BatteryStatus reads the battery of the matching controllers. The time remaining
+// is estimated from the readings recorded in history, which may be nil.
+func BatteryStatus(mac string, history *battery.History) ([]BatteryReport, error) {
+	paths, err := FindControllers(mac)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make([]BatteryReport, 0, len(paths))
+	for _, path := range paths {
+		report := BatteryReport{MAC: strings.ToUpper(bluetooth.ControllerMAC(path)), Transport: discovery.DetectTransport(path)}
+		if report.Level, err = battery.ActualBatteryLevel(path); err != nil {
+			return nil, fmt.Errorf("reading battery of %s: %w", report.MAC, err)
+		}
+		if report.Status, err = battery.ChargingStatus(path); err != nil {
+			return nil, fmt.Errorf("reading battery of %s: %w", report.MAC, err)
+		}
+		report.Status = poweredStatus(report.Status, report.Transport)
+
+		now := Clock.Now()
+		past, err := history.Samples(report.MAC, now.Add(-battery.EstimateWindow))
+		if err != nil {
+			return nil, err
+		}
+		estimator := battery.NewEstimator(past)
+		estimator.Add(battery.Sample{Time: now, Level: report.Level, Status: report.Status})
+		report.Estimate, report.Estimated = estimator.Estimate(now)
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// ControllerInfo is the firmware, hardware and calibration information of a controller,
+// read from its feature reports.
+type ControllerInfo struct {
+	MAC       string `json:"mac"`
+	Model     string `json:"model"`
+	Transport string `json:"transport"`
+	// Unsupported is set for the models whose feature reports are not decoded,
+	// the information is then missing.
+	Unsupported bool `json:"unsupported,omitempty"`
+	*hidraw.Info
+}
+
+// ReadControllerInfo reads the feature reports of the controller behind path.
+func ReadControllerInfo(path string) (ControllerInfo, error) {
+	info := ControllerInfo{
+		MAC:       strings.ToUpper(bluetooth.ControllerMAC(path)),
+		Model:     discovery.ControllerModel(path).String(),
+		Transport: discovery.DetectTransport(path).String(),
+	}
+	hid, err := readHidInfo(path)
+	if errors.Is(err, hidraw.ErrUnsupported) {
+		info.Unsupported = true
+		return info, nil
+	}
+	if err != nil {
+		return info, err
+	}
+	info.Info = &hid
+	return info, nil
+}
+
+// readHidInfo reads the feature reports of a DualSense. The DualShock 4 has
+// different ones, which are not decoded.
+func readHidInfo(path string) (hidraw.Info, error) {
+	if model := discovery.DetectModel(path); model.IsDualShock4() {
+		return hidraw.Info{}, fmt.Errorf("%s: %w", model, hidraw.ErrUnsupported)
+	}
+	return hidraw.ReadInfo(path)
+}
+
+// Info reads the information of the matching controllers.
+func Info(mac string) ([]ControllerInfo, error) {
+	paths, err := FindControllers(mac)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]ControllerInfo, 0, len(paths))
+	for _, path := range paths {
+		info, err := ReadControllerInfo(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading information of %s: %w", info.MAC, err)
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// ControllerEntry is a known controller as listed in the tray menu.
+type ControllerEntry struct {
+	MAC string
+	// Model is the detected model of a connected controller, unknown otherwise.
+	Model     discovery.Model
+	Connected bool
+	// loop runs the connected controller, nil otherwise.
+	loop *ControllerLoop
+}
+
+// Label returns the name of the entry in the tray menu, e.g. "DualSense Edge AA:BB:…".
+func (e ControllerEntry) Label() string {
+	if e.Model == discovery.ModelUnknown {
+		return "Controller " + e.MAC
+	}
+	return fmt.Sprintf("%s %s", e.Model, e.MAC)
+}
+
+// ControllerEntries lists the configured controllers along with the connected ones, sorted by MAC.
+func ControllerEntries(conf *config.Config, connected []*ControllerLoop) []ControllerEntry {
+	loops := make(map[string]*ControllerLoop, len(connected))
+	for _, loop := range connected {
+		loops[strings.ToUpper(loop.MAC)] = loop
+	}
+
+	known := make(map[string]bool)
+	for mac := range conf.Controllers {
+		known[strings.ToUpper(mac)] = true
+	}
+	for mac := range loops {
+		known[mac] = true
+	}
+
+	entries := make([]ControllerEntry, 0, len(known))
+	for mac := range known {
+		if mac == "" {
+			continue
+		}
+		entry := ControllerEntry{MAC: mac}
+		if loop, ok := loops[mac]; ok {
+			entry.Model, entry.Connected, entry.loop = loop.Model, true, loop
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].MAC < entries[j].MAC })
+	return entries
+}
+
+// ToggleConnection turns the controller off through the power backend of its transport
+// when it is connected, and wakes it up through BlueZ otherwise.
+func ToggleConnection(entry ControllerEntry) error {
+	if entry.Connected {
+		return entry.loop.PowerOff()
+	}
+	return bluetooth.Connect(entry.MAC)
+}
+
+// DisconnectAll turns every given controller off, attempting all of them even when one fails.
+func DisconnectAll(connected []*ControllerLoop) error {
+	var errs []error
+	for _, loop := range connected {
+		if err := loop.PowerOff(); err != nil {
+			errs = append(errs, fmt.Errorf("disconnecting %s: %w", loop.MAC, err))
+		}
+	}
+	return errors.Join(errs...)
+}
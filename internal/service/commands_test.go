@@ -0,0 +1,490 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"dualsense/internal/clock"
+	"dualsense/internal/config"
+	"dualsense/internal/dbustest"
+	"dualsense/internal/hidraw"
+	"dualsense/internal/service/battery"
+	"dualsense/internal/service/bluetooth"
+	"dualsense/internal/service/discovery"
+	"dualsense/internal/service/drift"
+	"dualsense/internal/service/input"
+	"dualsense/internal/sysfs"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// fakeDiscovery lists a fixed set of controllers.
+type fakeDiscovery struct{ paths []string }
+
+func (d fakeDiscovery) FindAllDualSense() ([]string, error) { return d.paths, nil }
+func (d fakeDiscovery) Watch(_ context.Context) <-chan discovery.Event {
+	return make(chan discovery.Event)
+}
+
+func TestFindControllers(t *testing.T) {
+	oldFS, oldDiscovery := sysfs.FS, Discovery
+	defer func() { sysfs.FS, Discovery = oldFS, oldDiscovery }()
+	sysfs.FS = calibrateFS{}
+	Discovery = fakeDiscovery{paths: []string{"/dev/input/js0", "/dev/input/js1"}}
+
+	if paths, err := FindControllers(""); err != nil || !reflect.DeepEqual(paths, []string{"/dev/input/js0", "/dev/input/js1"}) {
+		t.Fatalf("FindControllers() = %v, %v; want every controller", paths, err)
+	}
+	if paths, err := FindControllers("AA:BB:CC:DD:EE:FF"); err != nil || !reflect.DeepEqual(paths, []string{"/dev/input/js0"}) {
+		t.Fatalf("FindControllers(mac) = %v, %v; want js0", paths, err)
+	}
+	if _, err := FindControllers("11:22:33:44:55:66"); err == nil {
+		t.Fatalf("expected an error for a controller not connected")
+	}
+}
+
+func TestControllerEntries(t *testing.T) {
+	conf := &config.Config{Controllers: map[string]config.ControllerConfig{
+		"BB:BB:BB:BB:BB:BB": {},
+		"AA:AA:AA:AA:AA:AA": {},
+	}}
+
+	edge := &ControllerLoop{MAC: "cc:cc:cc:cc:cc:cc", Model: discovery.ModelDualSenseEdge}
+	ds4 := &ControllerLoop{MAC: "AA:AA:AA:AA:AA:AA", Model: discovery.ModelDualShock4}
+	got := ControllerEntries(conf, []*ControllerLoop{edge, ds4})
+	want := []ControllerEntry{
+		{MAC: "AA:AA:AA:AA:AA:AA", Model: discovery.ModelDualShock4, Connected: true, loop: ds4},
+		{MAC: "BB:BB:BB:BB:BB:BB", Connected: false},
+		{MAC: "CC:CC:CC:CC:CC:CC", Model: discovery.ModelDualSenseEdge, Connected: true, loop: edge},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ControllerEntries = %+v, want %+v", got, want)
+	}
+
+	labels := []string{"DualShock 4 AA:AA:AA:AA:AA:AA", "Controller BB:BB:BB:BB:BB:BB", "DualSense Edge CC:CC:CC:CC:CC:CC"}
+	for i, entry := range got {
+		if entry.Label() != labels[i] {
+			t.Errorf("Label() = %q, want %q", entry.Label(), labels[i])
+		}
+	}
+
+	if got := ControllerEntries(&config.Config{}, nil); len(got) != 0 {
+		t.Fatalf("expected no entries, got %+v", got)
+	}
+}
+
+// fakeBlueZ answers device lookups for the given MACs and accepts Connect/Disconnect on known devices.
+func fakeBlueZ(t *testing.T, macs ...string) *dbustest.Bus {
+	t.Helper()
+	bus := dbustest.New()
+	bus.Handle("org.freedesktop.DBus.ObjectManager.GetManagedObjects", func(_ *dbus.Message) ([]interface{}, *dbus.Error) {
+		objects := map[dbus.ObjectPath]map[string]map[string]dbus.Variant{}
+		for _, mac := range macs {
+			path := dbus.ObjectPath("/org/bluez/hci0/dev_" + strings.ReplaceAll(mac, ":", "_"))
+			objects[path] = map[string]map[string]dbus.Variant{
+				"org.bluez.Device1": {"Address": dbus.MakeVariant(mac)},
+			}
+		}
+		return []interface{}{objects}, nil
+	})
+	accept := func(_ *dbus.Message) ([]interface{}, *dbus.Error) { return nil, nil }
+	bus.Handle("org.bluez.Device1.Connect", accept)
+	bus.Handle("org.bluez.Device1.Disconnect", accept)
+
+	old := bluetooth.ConnectSystemBus
+	bluetooth.ConnectSystemBus = bus.Connect
+	t.Cleanup(func() { bluetooth.ConnectSystemBus = old })
+	return bus
+}
+
+func TestToggleConnection(t *testing.T) {
+	oldFS := sysfs.FS
+	defer func() { sysfs.FS = oldFS }()
+	sysfs.FS = inputFS{}
+	bus := fakeBlueZ(t, "AA:AA:AA:AA:AA:AA")
+
+	if err := ToggleConnection(ControllerEntry{MAC: "AA:AA:AA:AA:AA:AA", Connected: false}); err != nil {
+		t.Fatalf("ToggleConnection error: %v", err)
+	}
+	loop := &ControllerLoop{Path: "/dev/input/js0", MAC: "AA:AA:AA:AA:AA:AA"}
+	if err := ToggleConnection(ControllerEntry{MAC: "AA:AA:AA:AA:AA:AA", Connected: true, loop: loop}); err != nil {
+		t.Fatalf("ToggleConnection error: %v", err)
+	}
+
+	if n := len(bus.CallsTo("org.bluez.Device1.Connect")); n != 1 {
+		t.Fatalf("expected 1 Connect call, got %d", n)
+	}
+	if n := len(bus.CallsTo("org.bluez.Device1.Disconnect")); n != 1 {
+		t.Fatalf("expected 1 Disconnect call, got %d", n)
+	}
+}
+
+func TestDisconnectAll(t *testing.T) {
+	oldFS := sysfs.FS
+	defer func() { sysfs.FS = oldFS }()
+	sysfs.FS = inputFS{}
+	bus := fakeBlueZ(t, "AA:AA:AA:AA:AA:AA", "BB:BB:BB:BB:BB:BB")
+
+	// an unknown controller must not stop the others from being disconnected
+	err := DisconnectAll([]*ControllerLoop{
+		{Path: "/dev/input/js7", MAC: "AA:AA:AA:AA:AA:AA"},
+		{Path: "/dev/input/js8", MAC: "DD:DD:DD:DD:DD:DD"},
+		{Path: "/dev/input/js9", MAC: "BB:BB:BB:BB:BB:BB"},
+	})
+	if err == nil {
+		t.Fatalf("expected an error for the unknown controller")
+	}
+
+	calls := bus.CallsTo("org.bluez.Device1.Disconnect")
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 Disconnect calls, got %+v", calls)
+	}
+}
+
+func TestDisconnectAllOverUSB(t *testing.T) {
+	oldFS, oldOpen := sysfs.FS, hidraw.Open
+	defer func() { sysfs.FS, hidraw.Open = oldFS, oldOpen }()
+	sysfs.FS = usbFS{}
+	var reports []*hidraw.OutputReport
+	hidraw.Open = func(_ string) (io.ReadWriteCloser, error) {
+		return reportWriter(func(p []byte) {
+			r, err := hidraw.ParseOutputReport(p)
+			if err != nil {
+				t.Errorf("invalid report % x: %v", p, err)
+				return
+			}
+			reports = append(reports, r)
+		}), nil
+	}
+	bus := fakeBlueZ(t, "AA:BB:CC:DD:EE:FF")
+
+	// a controller plugged in cannot be turned off, it is put to sleep
+	loop := &ControllerLoop{Path: "/dev/input/js0", MAC: "AA:BB:CC:DD:EE:FF", Asleep: new(atomic.Bool)}
+	if err := DisconnectAll([]*ControllerLoop{loop}); err != nil {
+		t.Fatalf("DisconnectAll error: %v", err)
+	}
+	if n := len(bus.CallsTo("org.bluez.Device1.Disconnect")); n != 0 {
+		t.Fatalf("unexpected %d Disconnect calls over USB", n)
+	}
+	if len(reports) != 1 || reports[0].Flags1&hidraw.Flag1PowerSave == 0 {
+		t.Fatalf("expected a sleep report, got %+v", reports)
+	}
+	if !loop.Asleep.Load() {
+		t.Fatalf("expected the controller marked asleep for the activity loop to wake it up")
+	}
+}
+
+// calibrateFS is inputFS with js0 listed as a connected DualSense.
+type calibrateFS struct{ inputFS }
+
+func (fs calibrateFS) ReadFile(path string) ([]byte, error) {
+	switch path {
+	case "/sys/class/input/js0/device/name":
+		return []byte("DualSense Wireless Controller\n"), nil
+	case "/sys/class/input/js0/device/uniq":
+		return []byte("aa:bb:cc:dd:ee:ff\n"), nil
+	}
+	return fs.inputFS.ReadFile(path)
+}
+
+func (fs calibrateFS) Glob(pattern string) ([]string, error) {
+	if pattern == "/dev/input/js*" {
+		return []string{"/dev/input/js0"}, nil
+	}
+	return fs.inputFS.Glob(pattern)
+}
+
+// batteryFS is calibrateFS with the js0 battery at 55% and discharging.
+type batteryFS struct{ calibrateFS }
+
+const batteryStatusPath = "/sys/class/input/js0/device/device/power_supply/ps-controller-battery-aa:bb:cc:dd:ee:ff"
+
+func (fs batteryFS) ReadFile(path string) ([]byte, error) {
+	switch path {
+	case batteryStatusPath + "/capacity":
+		return []byte("55\n"), nil
+	case batteryStatusPath + "/status":
+		return []byte("Discharging\n"), nil
+	}
+	return fs.calibrateFS.ReadFile(path)
+}
+
+func (fs batteryFS) Glob(pattern string) ([]string, error) {
+	if strings.HasPrefix(pattern, "/sys/class/input/js0/device/device/power_supply/") {
+		return []string{batteryStatusPath}, nil
+	}
+	return fs.calibrateFS.Glob(pattern)
+}
+
+func TestBatteryStatus(t *testing.T) {
+	oldFS, oldClock := sysfs.FS, Clock
+	defer func() { sysfs.FS, Clock = oldFS, oldClock }()
+	sysfs.FS = batteryFS{}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	Clock = clock.NewFake(now)
+
+	// no history yet: the level is known, not the time remaining
+	history := battery.NewHistory(t.TempDir())
+	reports, err := BatteryStatus("aa:bb:cc:dd:ee:ff", history)
+	if err != nil {
+		t.Fatalf("BatteryStatus error: %v", err)
+	}
+	want := BatteryReport{MAC: "AA:BB:CC:DD:EE:FF", Level: 55, Status: "Discharging"}
+	if len(reports) != 1 || reports[0] != want {
+		t.Fatalf("BatteryStatus = %+v, want %+v", reports, want)
+	}
+
+	// a step every 40 minutes: 15% per hour, 55% left
+	for i, level := range []int{95, 85, 75, 65, 55} {
+		at := now.Add(time.Duration(i-4)*40*time.Minute - 10*time.Minute)
+		for t0 := at; t0.Before(at.Add(40*time.Minute)) && !t0.After(now); t0 = t0.Add(battery.HeartbeatInterval) {
+			if err := history.Record(want.MAC, battery.Sample{Time: t0, Level: level, Status: "Discharging"}); err != nil {
+				t.Fatalf("recording history: %v", err)
+			}
+		}
+	}
+	reports, err = BatteryStatus("", history)
+	if err != nil {
+		t.Fatalf("BatteryStatus error: %v", err)
+	}
+	if len(reports) != 1 || !reports[0].Estimated {
+		t.Fatalf("expected an estimate, got %+v", reports)
+	}
+	if got := reports[0].Estimate.String(); got != "~3h 30m remaining" {
+		t.Fatalf("unexpected estimate %q (%+v)", got, reports[0].Estimate)
+	}
+}
+
+// usbFS is batteryFS with js0 plugged in over USB, with a hidraw node.
+type usbFS struct{ batteryFS }
+
+func (fs usbFS) ReadFile(path string) ([]byte, error) {
+	if path == "/sys/class/input/js0/device/id/bustype" {
+		return []byte("0003\n"), nil
+	}
+	return fs.batteryFS.ReadFile(path)
+}
+
+func (fs usbFS) Glob(pattern string) ([]string, error) {
+	if pattern == "/sys/class/input/js0/device/device/hidraw/hidraw*" {
+		return []string{"/sys/class/input/js0/device/device/hidraw/hidraw3"}, nil
+	}
+	return fs.batteryFS.Glob(pattern)
+}
+
+func TestBatteryStatusOverUSB(t *testing.T) {
+	oldFS := sysfs.FS
+	defer func() { sysfs.FS = oldFS }()
+	sysfs.FS = usbFS{}
+
+	// the port powers the controller, a stale discharging reading is charging
+	reports, err := BatteryStatus("", nil)
+	if err != nil {
+		t.Fatalf("BatteryStatus error: %v", err)
+	}
+	want := BatteryReport{MAC: "AA:BB:CC:DD:EE:FF", Transport: discovery.TransportUSB, Level: 55, Status: "Charging"}
+	if len(reports) != 1 || reports[0] != want {
+		t.Fatalf("BatteryStatus = %+v, want %+v", reports, want)
+	}
+}
+
+// eofReader closes done once the recording has been read entirely.
+type eofReader struct {
+	fakeReadCloser
+	done chan struct{}
+}
+
+func (r eofReader) Read(p []byte) (int, error) {
+	n, err := r.fakeReadCloser.Read(p)
+	if err == io.EOF {
+		close(r.done)
+	}
+	return n, err
+}
+
+func TestCalibrate(t *testing.T) {
+	oldFS, oldOpen, oldClock := sysfs.FS, input.OpenEventDevice, Clock
+	defer func() { sysfs.FS, input.OpenEventDevice, Clock = oldFS, oldOpen, oldClock }()
+	sysfs.FS = calibrateFS{}
+	fake := clock.NewFake(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	Clock = fake
+
+	gamepadRead := make(chan struct{})
+	recording := readRecording(t, "gamepad_drift.evdev")
+	input.OpenEventDevice = func(path string) (io.ReadCloser, error) {
+		switch path {
+		case "/dev/input/event7":
+			return eofReader{fakeReadCloser{bytes.NewReader(recording)}, gamepadRead}, nil
+		case "/dev/input/event8", "/dev/input/event9":
+			return fakeReadCloser{bytes.NewReader(nil)}, nil
+		}
+		return nil, fmt.Errorf("unexpected open of %s", path)
+	}
+
+	type result struct {
+		results []CalibrationResult
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		results, err := Calibrate(context.Background(), &config.Config{}, "", 10*time.Second)
+		done <- result{results, err}
+	}()
+
+	<-gamepadRead
+	fake.BlockUntil(1)
+	fake.Advance(10 * time.Second)
+	res := <-done
+	if res.err != nil {
+		t.Fatalf("Calibrate error: %v", res.err)
+	}
+	if len(res.results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(res.results))
+	}
+
+	got := res.results[0]
+	if got.MAC != "AA:BB:CC:DD:EE:FF" || got.Deadzones.LeftStick.Size != 1500 {
+		t.Fatalf("unexpected controller %s with deadzones %+v", got.MAC, got.Deadzones)
+	}
+	leftX := got.Report.Axes[drift.LeftX]
+	if leftX.Samples != 48 || leftX.Offset != 3072 || !leftX.Drifting {
+		t.Fatalf("expected left X drifting at 3072, got %+v", leftX)
+	}
+	if got.Report.Axes[drift.LeftY].Drifting {
+		t.Fatalf("expected left Y steady, got %+v", got.Report.Axes[drift.LeftY])
+	}
+	// 3072 offset plus three times the 181 noise
+	if got.Report.SuggestedLeft != 3750 || got.Report.SuggestedRight != 0 {
+		t.Fatalf("unexpected suggested deadzones %d/%d", got.Report.SuggestedLeft, got.Report.SuggestedRight)
+	}
+}
+
+// restingNode is an evdev node sending no event until it is closed, like the
+// gamepad node of a controller lying still.
+type restingNode struct{ closed chan struct{} }
+
+func (n restingNode) Read(_ []byte) (int, error) {
+	<-n.closed
+	return 0, io.EOF
+}
+func (n restingNode) Close() error {
+	close(n.closed)
+	return nil
+}
+
+func TestCalibratePollsRestingSticks(t *testing.T) {
+	oldFS, oldOpen, oldReadAbs, oldClock := sysfs.FS, input.OpenEventDevice, input.ReadAbs, Clock
+	defer func() { sysfs.FS, input.OpenEventDevice, input.ReadAbs, Clock = oldFS, oldOpen, oldReadAbs, oldClock }()
+	sysfs.FS = calibrateFS{}
+	fake := clock.NewFake(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	Clock = fake
+
+	gamepad := restingNode{make(chan struct{})}
+	input.OpenEventDevice = func(path string) (io.ReadCloser, error) {
+		if path == "/dev/input/event7" {
+			return gamepad, nil
+		}
+		return fakeReadCloser{bytes.NewReader(nil)}, nil
+	}
+	// the left stick rests off-center, one read per axis and poll
+	reads := make(chan uint16, 8)
+	input.ReadAbs = func(f io.Reader, code uint16) (int32, error) {
+		if f != io.Reader(gamepad) {
+			t.Errorf("unexpected axis read on %v", f)
+		}
+		reads <- code
+		if code == input.AbsX {
+			return 140, nil
+		}
+		return 128, nil
+	}
+	waitPoll := func() {
+		for range 4 {
+			<-reads
+		}
+	}
+
+	type result struct {
+		results []CalibrationResult
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		results, err := Calibrate(context.Background(), &config.Config{}, "", 3*time.Second-driftPollInterval/2)
+		done <- result{results, err}
+	}()
+
+	// the axes are read once when the capture starts, then on each poll
+	waitPoll()
+	fake.BlockUntil(2)
+	for range 29 {
+		fake.Advance(driftPollInterval)
+		waitPoll()
+	}
+	fake.Advance(driftPollInterval / 2)
+	res := <-done
+	if res.err != nil {
+		t.Fatalf("Calibrate error: %v", res.err)
+	}
+
+	report := res.results[0].Report
+	leftX := report.Axes[drift.LeftX]
+	if leftX.Samples != 30 || leftX.Offset != 3072 || !leftX.Drifting {
+		t.Fatalf("expected 30 samples of left X drifting at 3072, got %+v", leftX)
+	}
+	for _, axis := range []drift.Axis{drift.LeftY, drift.RightX, drift.RightY} {
+		if r := report.Axes[axis]; r.Samples != 30 || r.Drifting {
+			t.Fatalf("expected 30 samples of %v at rest, got %+v", axis, r)
+		}
+	}
+	if report.SuggestedLeft != 3250 {
+		t.Fatalf("expected a 3250 left deadzone suggested, got %d", report.SuggestedLeft)
+	}
+}
+
+// dualShock4FS is usbFS with js0 a DualShock 4 v2.
+type dualShock4FS struct{ usbFS }
+
+func (fs dualShock4FS) ReadFile(path string) ([]byte, error) {
+	switch path {
+	case "/sys/class/input/js0/device/id/vendor":
+		return []byte("054c\n"), nil
+	case "/sys/class/input/js0/device/id/product":
+		return []byte("09cc\n"), nil
+	}
+	return fs.usbFS.ReadFile(path)
+}
+
+func TestInfoDualShock4(t *testing.T) {
+	oldFS, oldGet := sysfs.FS, hidraw.GetFeature
+	defer func() { sysfs.FS, hidraw.GetFeature = oldFS, oldGet }()
+	sysfs.FS = dualShock4FS{}
+	hidraw.GetFeature = func(_ string, buf []byte) (int, error) {
+		t.Fatalf("unexpected read of the DualSense feature report 0x%02x", buf[0])
+		return 0, nil
+	}
+
+	infos, err := Info("aa:bb:cc:dd:ee:ff")
+	if err != nil {
+		t.Fatalf("Info error: %v", err)
+	}
+	if len(infos) != 1 || !infos[0].Unsupported || infos[0].Info != nil || infos[0].Model != "DualShock 4 v2" {
+		t.Fatalf("expected the DualShock 4 information reported unsupported, got %+v", infos)
+	}
+	got, err := json.Marshal(infos[0])
+	if err != nil {
+		t.Fatalf("encoding info: %v", err)
+	}
+	want := `{"mac":"AA:BB:CC:DD:EE:FF","model":"DualShock 4 v2","transport":"USB","unsupported":true}`
+	if string(got) != want {
+		t.Fatalf("info = %s, want %s", got, want)
+	}
+}
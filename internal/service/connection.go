@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"dualsense/internal/service/bluetooth"
+	"fmt"
+	"log"
+)
+
+// Connection states shown for a Bluetooth controller.
+const (
+	connectionConnected     = "Connected"
+	connectionConnecting    = "Connecting…"
+	connectionDisconnecting = "Disconnecting…"
+	connectionReconnected   = "Reconnected"
+)
+
+// connectionTracker turns BlueZ device events into the connection state of each controller.
+// It outlives controller tabs so that a controller coming back is reported as reconnected.
+type connectionTracker struct {
+	states  map[string]string
+	dropped map[string]bool
+}
+
+func newConnectionTracker() *connectionTracker {
+	return &connectionTracker{
+		states:  make(map[string]string),
+		dropped: make(map[string]bool),
+	}
+}
+
+// update records ev and returns the new connection state of its controller.
+// It returns false when ev does not change the connection state.
+func (t *connectionTracker) update(ev bluetooth.DeviceEvent) (string, bool) {
+	var state string
+	switch ev.Kind {
+	case bluetooth.EventDisconnected:
+		t.dropped[ev.MAC] = true
+		state = connectionDisconnecting
+	case bluetooth.EventConnected:
+		state = connectionConnecting
+	case bluetooth.EventServicesResolved:
+		if !ev.Resolved {
+			return "", false
+		}
+		state = connectionConnected
+		if t.dropped[ev.MAC] {
+			state = connectionReconnected
+			delete(t.dropped, ev.MAC)
+		}
+	default:
+		return "", false
+	}
+
+	t.states[ev.MAC] = state
+	return state, true
+}
+
+// state returns the last known connection state of the controller with the given MAC.
+func (t *connectionTracker) state(mac string) string {
+	if state, ok := t.states[mac]; ok {
+		return state
+	}
+	return connectionConnected
+}
+
+// watchBluetooth subscribes to BlueZ device events. When the system bus is unavailable it
+// returns a nil channel so that the controller manager keeps relying on device nodes only.
+func watchBluetooth(ctx context.Context) <-chan bluetooth.DeviceEvent {
+	events, err := bluetooth.Watch(ctx)
+	if err != nil {
+		log.Default().Println("BlueZ signals unavailable:", err)
+		return nil
+	}
+	return events
+}
+
+// formatRSSI returns the signal strength label for an RSSI reading.
+func formatRSSI(rssi int16) string {
+	return fmt.Sprintf("%d dBm", rssi)
+}
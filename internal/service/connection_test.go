@@ -0,0 +1,48 @@
+package service
+
+import (
+	"testing"
+
+	"dualsense/internal/service/bluetooth"
+)
+
+func TestConnectionTracker(t *testing.T) {
+	const mac = "AA:BB:CC:DD:EE:FF"
+	tracker := newConnectionTracker()
+
+	if got := tracker.state(mac); got != connectionConnected {
+		t.Fatalf("expected unknown controller to be %q, got %q", connectionConnected, got)
+	}
+
+	steps := []struct {
+		ev      bluetooth.DeviceEvent
+		want    string
+		changed bool
+	}{
+		{bluetooth.DeviceEvent{MAC: mac, Kind: bluetooth.EventServicesResolved, Resolved: true}, connectionConnected, true},
+		{bluetooth.DeviceEvent{MAC: mac, Kind: bluetooth.EventRSSI, RSSI: -60}, "", false},
+		{bluetooth.DeviceEvent{MAC: mac, Kind: bluetooth.EventDisconnected}, connectionDisconnecting, true},
+		{bluetooth.DeviceEvent{MAC: mac, Kind: bluetooth.EventServicesResolved, Resolved: false}, "", false},
+		{bluetooth.DeviceEvent{MAC: mac, Kind: bluetooth.EventConnected}, connectionConnecting, true},
+		{bluetooth.DeviceEvent{MAC: mac, Kind: bluetooth.EventServicesResolved, Resolved: true}, connectionReconnected, true},
+		{bluetooth.DeviceEvent{MAC: mac, Kind: bluetooth.EventConnected}, connectionConnecting, true},
+		{bluetooth.DeviceEvent{MAC: mac, Kind: bluetooth.EventServicesResolved, Resolved: true}, connectionConnected, true},
+	}
+
+	for i, step := range steps {
+		got, changed := tracker.update(step.ev)
+		if got != step.want || changed != step.changed {
+			t.Fatalf("step %d: update(%+v) = %q, %v; want %q, %v", i, step.ev, got, changed, step.want, step.changed)
+		}
+	}
+
+	if got := tracker.state(mac); got != connectionConnected {
+		t.Fatalf("expected final state %q, got %q", connectionConnected, got)
+	}
+}
+
+func TestFormatRSSI(t *testing.T) {
+	if got := formatRSSI(-55); got != "-55 dBm" {
+		t.Fatalf("formatRSSI(-55) = %q", got)
+	}
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"dualsense/internal/config"
+	"dualsense/internal/service/input"
+	"math"
+)
+
+// axisState remembers the last position of the analog axes, indexed by evdev code,
+// so a radial deadzone can combine both axes of a stick. Stick values are offsets
+// from the center and trigger values range from 0 (released) to 32767, in the
+// joystick interface scale.
+type axisState [input.AbsRZ + 1]int
+
+// outsideDeadzone records the new value of axis and reports whether the stick or
+// trigger it belongs to is now outside its deadzone.
+func (s *axisState) outsideDeadzone(dz config.Deadzones, axis uint16, value int) bool {
+	if int(axis) >= len(s) {
+		return false
+	}
+	s[axis] = value
+
+	switch axis {
+	case input.AbsX, input.AbsY:
+		return outsideStickDeadzone(dz.LeftStick, s[input.AbsX], s[input.AbsY], axis == input.AbsX)
+	case input.AbsRX, input.AbsRY:
+		return outsideStickDeadzone(dz.RightStick, s[input.AbsRX], s[input.AbsRY], axis == input.AbsRX)
+	case input.AbsZ:
+		return value > dz.LeftTrigger
+	case input.AbsRZ:
+		return value > dz.RightTrigger
+	}
+	return false
+}
+
+// outsideStickDeadzone reports whether a stick at x, y is outside its deadzone. An
+// axial deadzone only looks at the axis that moved, xMoved telling which one.
+func outsideStickDeadzone(stick config.StickDeadzone, x, y int, xMoved bool) bool {
+	if stick.Shape == config.DeadzoneAxial {
+		v := y
+		if xMoved {
+			v = x
+		}
+		return v > stick.Size || v < -stick.Size
+	}
+	return math.Hypot(float64(x), float64(y)) > float64(stick.Size)
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"testing"
+
+	"dualsense/internal/config"
+	"dualsense/internal/service/input"
+)
+
+func TestAxisStateOutsideDeadzone(t *testing.T) {
+	type move struct {
+		axis  uint16
+		value int
+	}
+	dz := config.Deadzones{
+		LeftStick:    config.StickDeadzone{Size: 4000, Shape: config.DeadzoneRadial},
+		RightStick:   config.StickDeadzone{Size: 4000, Shape: config.DeadzoneAxial},
+		LeftTrigger:  1000,
+		RightTrigger: 8000,
+	}
+
+	tests := []struct {
+		name  string
+		moves []move
+		want  bool
+	}{
+		{"radial inside on both axes", []move{{input.AbsX, 2500}, {input.AbsY, 2500}}, false},
+		{"radial outside diagonally", []move{{input.AbsX, 3000}, {input.AbsY, 3000}}, true},
+		{"radial back to center", []move{{input.AbsX, 3000}, {input.AbsY, 3000}, {input.AbsX, 0}}, false},
+		{"axial diagonal inside each axis", []move{{input.AbsRX, 3000}, {input.AbsRY, 3000}}, false},
+		{"axial outside on one axis", []move{{input.AbsRX, 3000}, {input.AbsRY, -4500}}, true},
+		{"axial other axis ignored", []move{{input.AbsRY, -4500}, {input.AbsRX, 3000}}, false},
+		{"sticks are independent", []move{{input.AbsX, 5000}, {input.AbsRX, 100}}, false},
+		{"light left trigger press", []move{{input.AbsZ, 2000}}, true},
+		{"light right trigger press", []move{{input.AbsRZ, 2000}}, false},
+		{"unknown axis", []move{{input.AbsHat0X, 32767}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var axes axisState
+			var got bool
+			for _, m := range tt.moves {
+				got = axes.outsideDeadzone(dz, m.axis, m.value)
+			}
+			if got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestJoystickAxisActivity(t *testing.T) {
+	dz := axialDeadzones(2000)
+
+	tests := []struct {
+		name  string
+		index byte
+		value int
+		want  bool
+	}{
+		{"stick inside deadzone", 0, -1500, false},
+		{"stick outside deadzone", 4, 2500, true},
+		{"trigger released", 2, -32767, false},
+		{"trigger slightly pressed", 5, -25000, true},
+		{"d-pad pressed", 6, -32767, true},
+		{"d-pad released", 7, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var axes axisState
+			if got := joystickAxisActivity(&axes, dz, tt.index, tt.value); got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@
 package discovery
 
 import (
+	"context"
 	"fmt"
 	"path/filepath"
 	"strings"
@@ -12,9 +13,27 @@ import (
 // Discovery interface defines methods for discovering DualSense controllers.
 type Discovery interface {
 	FindAllDualSense() ([]string, error)
+	Watch(ctx context.Context) <-chan Event
 }
 
-// FindAllDualSense discovers DualSense joystick device nodes under /dev/input.
+// Sysfs discovers controllers from /dev/input and the kernel uevents.
+type Sysfs struct{}
+
+var _ Discovery = Sysfs{}
+
+// FindAllDualSense lists the controllers currently connected, see FindAllDualSense.
+func (Sysfs) FindAllDualSense() ([]string, error) {
+	return FindAllDualSense()
+}
+
+// Watch reports controllers as they are plugged in or removed, see Watch.
+func (Sysfs) Watch(ctx context.Context) <-chan Event {
+	return Watch(ctx)
+}
+
+// FindAllDualSense discovers the joystick device nodes of the supported controllers
+// under /dev/input: the models known by their vendor and product IDs, or the
+// controllers named after Sony or the DualSense when the IDs are not readable.
 func FindAllDualSense() ([]string, error) {
 	var found []string
 	matches, err := sysfs.FS.Glob("/dev/input/js*")
@@ -29,10 +48,11 @@ func FindAllDualSense() ([]string, error) {
 		}
 		name := strings.ToLower(string(nameBytes))
 
-		if strings.Contains(name, "sony") || strings.Contains(name, "dualsense") {
-			if strings.Contains(name, "motion sensors") {
-				continue
-			}
+		// the motion sensors and the touchpad share the IDs of the gamepad
+		if strings.Contains(name, "motion sensors") || strings.Contains(name, "touchpad") {
+			continue
+		}
+		if DetectModel(path) != ModelUnknown || strings.Contains(name, "sony") || strings.Contains(name, "dualsense") {
 			found = append(found, path)
 		}
 	}
@@ -53,3 +53,90 @@ func TestFindAllDualSense(t *testing.T) {
 		t.Fatalf("unexpected path: %v", found)
 	}
 }
+
+// fakeController adds the sysfs tree of a joystick device to fake.
+func fakeController(fake fakeFS, js, name, vendor, product string) {
+	dir := filepath.Join("/sys/class/input", js, "device")
+	fake.globs["/dev/input/js*"] = append(fake.globs["/dev/input/js*"], "/dev/input/"+js)
+	fake.files[filepath.Join(dir, "name")] = []byte(name + "\n")
+	fake.files[filepath.Join(dir, "id", "vendor")] = []byte(vendor + "\n")
+	fake.files[filepath.Join(dir, "id", "product")] = []byte(product + "\n")
+}
+
+func TestDetectModel(t *testing.T) {
+	old := sysfs.FS
+	fake := fakeFS{files: map[string][]byte{}, globs: map[string][]string{}}
+	fakeController(fake, "js0", "DualSense Wireless Controller", "054c", "0ce6")
+	fakeController(fake, "js1", "DualSense Edge Wireless Controller", "054c", "0df2")
+	fakeController(fake, "js2", "Sony Computer Entertainment Wireless Controller", "054c", "05c4")
+	fakeController(fake, "js3", "Wireless Controller", "054c", "09cc")
+	fakeController(fake, "js4", "Wireless Controller Motion Sensors", "054c", "09cc")
+	fakeController(fake, "js5", "Xbox Wireless Controller", "045e", "0b13")
+	sysfs.FS = fake
+	defer func() { sysfs.FS = old }()
+
+	tests := []struct {
+		path  string
+		model Model
+	}{
+		{"/dev/input/js0", ModelDualSense},
+		{"/dev/input/js1", ModelDualSenseEdge},
+		{"/dev/input/js2", ModelDualShock4},
+		{"/dev/input/js3", ModelDualShock4V2},
+		{"/dev/input/js5", ModelUnknown},
+		{"/dev/input/js9", ModelUnknown},
+	}
+	for _, tt := range tests {
+		if got := DetectModel(tt.path); got != tt.model {
+			t.Errorf("DetectModel(%s) = %v, want %v", tt.path, got, tt.model)
+		}
+	}
+
+	found, err := FindAllDualSense()
+	if err != nil {
+		t.Fatalf("FindAllDualSense error: %v", err)
+	}
+	want := []string{"/dev/input/js0", "/dev/input/js1", "/dev/input/js2", "/dev/input/js3"}
+	if fmt.Sprint(found) != fmt.Sprint(want) {
+		t.Fatalf("FindAllDualSense() = %v, want %v", found, want)
+	}
+}
+
+func TestModelFeatures(t *testing.T) {
+	if !ModelDualSenseEdge.HasPlayerLeds() || !ModelDualSenseEdge.HasAdaptiveTriggers() {
+		t.Error("DualSense Edge should have player LEDs and adaptive triggers")
+	}
+	for _, m := range []Model{ModelDualShock4, ModelDualShock4V2} {
+		if m.HasPlayerLeds() || m.HasAdaptiveTriggers() || !m.IsDualShock4() {
+			t.Errorf("%v should have neither player LEDs nor adaptive triggers", m)
+		}
+	}
+	if ControllerModel("/dev/input/js9") != ModelDualSense {
+		t.Error("a controller matched by name should be taken for a DualSense")
+	}
+}
+
+func TestDetectTransport(t *testing.T) {
+	old := sysfs.FS
+	fake := fakeFS{files: map[string][]byte{}, globs: map[string][]string{}}
+	fake.files["/sys/class/input/js0/device/id/bustype"] = []byte("0003\n")
+	fake.files["/sys/class/input/js1/device/id/bustype"] = []byte("0005\n")
+	fake.files["/sys/class/input/js2/device/id/bustype"] = []byte("0019\n")
+	sysfs.FS = fake
+	defer func() { sysfs.FS = old }()
+
+	tests := []struct {
+		path      string
+		transport Transport
+	}{
+		{"/dev/input/js0", TransportUSB},
+		{"/dev/input/js1", TransportBluetooth},
+		{"/dev/input/js2", TransportUnknown},
+		{"/dev/input/js9", TransportUnknown},
+	}
+	for _, tt := range tests {
+		if got := DetectTransport(tt.path); got != tt.transport {
+			t.Errorf("DetectTransport(%s) = %v, want %v", tt.path, got, tt.transport)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package discovery
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"dualsense/internal/sysfs"
+)
+
+// VendorSony is the USB and Bluetooth vendor ID of Sony controllers.
+const VendorSony = 0x054c
+
+// Model is a supported controller model.
+type Model int
+
+// Supported controller models, detected from their vendor and product IDs.
+const (
+	ModelUnknown Model = iota
+	ModelDualSense
+	ModelDualSenseEdge
+	ModelDualShock4
+	ModelDualShock4V2
+)
+
+// productModels maps the Sony product IDs to their model.
+var productModels = map[uint16]Model{
+	0x0ce6: ModelDualSense,
+	0x0df2: ModelDualSenseEdge,
+	0x05c4: ModelDualShock4,
+	0x09cc: ModelDualShock4V2,
+	0x0ba0: ModelDualShock4V2, // USB wireless adapter
+}
+
+// String returns the model name, e.g. "DualSense Edge".
+func (m Model) String() string {
+	switch m {
+	case ModelDualSense:
+		return "DualSense"
+	case ModelDualSenseEdge:
+		return "DualSense Edge"
+	case ModelDualShock4:
+		return "DualShock 4"
+	case ModelDualShock4V2:
+		return "DualShock 4 v2"
+	}
+	return "Unknown controller"
+}
+
+// IsDualShock4 reports whether the model is driven by hid-sony rather than
+// hid-playstation, and understands none of the DualSense output reports.
+func (m Model) IsDualShock4() bool {
+	return m == ModelDualShock4 || m == ModelDualShock4V2
+}
+
+// HasPlayerLeds reports whether the model has the white player LEDs below the touchpad.
+func (m Model) HasPlayerLeds() bool {
+	return !m.IsDualShock4()
+}
+
+// HasAdaptiveTriggers reports whether the model has adaptive triggers.
+func (m Model) HasAdaptiveTriggers() bool {
+	return !m.IsDualShock4()
+}
+
+// HasMuteButton reports whether the model has the microphone mute button and its LED.
+func (m Model) HasMuteButton() bool {
+	return !m.IsDualShock4()
+}
+
+// DetectModel returns the model of the controller behind jsPath from the vendor
+// and product IDs of its input device, or ModelUnknown.
+func DetectModel(jsPath string) Model {
+	vendor, err := readID(jsPath, "vendor")
+	if err != nil {
+		return ModelUnknown
+	}
+	product, err := readID(jsPath, "product")
+	if err != nil {
+		return ModelUnknown
+	}
+	return ModelForIDs(vendor, product)
+}
+
+// ModelForIDs returns the model with the given vendor and product IDs, or ModelUnknown.
+func ModelForIDs(vendor, product uint16) Model {
+	if vendor != VendorSony {
+		return ModelUnknown
+	}
+	return productModels[product]
+}
+
+// ControllerModel returns the model of a controller found by FindAllDualSense.
+// Controllers matched by name only are taken for a DualSense.
+func ControllerModel(jsPath string) Model {
+	if model := DetectModel(jsPath); model != ModelUnknown {
+		return model
+	}
+	return ModelDualSense
+}
+
+func readID(jsPath, attribute string) (uint16, error) {
+	path := fmt.Sprintf("/sys/class/input/%s/device/id/%s", filepath.Base(jsPath), attribute)
+	data, err := sysfs.FS.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.ParseUint(strings.TrimSpace(string(data)), 16, 16)
+	if err != nil {
+		return 0, err
+	}
+	return uint16(id), nil
+}
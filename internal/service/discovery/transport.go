@@ -0,0 +1,40 @@
+package discovery
+
+import "dualsense/internal/hidraw"
+
+// Transport is the link a controller is connected through.
+type Transport int
+
+// Controller transports, detected from the HID bus type of the input device.
+const (
+	TransportUnknown Transport = iota
+	TransportUSB
+	TransportBluetooth
+)
+
+// String returns the transport name, e.g. "USB".
+func (t Transport) String() string {
+	switch t {
+	case TransportUSB:
+		return "USB"
+	case TransportBluetooth:
+		return "Bluetooth"
+	}
+	return "Unknown"
+}
+
+// DetectTransport returns the transport of the controller behind jsPath, or
+// TransportUnknown when its bus type cannot be read.
+func DetectTransport(jsPath string) Transport {
+	bus, err := hidraw.Bus(jsPath)
+	if err != nil {
+		return TransportUnknown
+	}
+	switch bus {
+	case hidraw.BusUSB:
+		return TransportUSB
+	case hidraw.BusBluetooth:
+		return TransportBluetooth
+	}
+	return TransportUnknown
+}
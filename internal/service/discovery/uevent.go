@@ -0,0 +1,241 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"time"
+)
+
+// Action tells whether a controller appeared or disappeared.
+type Action int
+
+// Actions reported by Watch.
+const (
+	Added Action = iota
+	Removed
+)
+
+// Event is emitted by Watch when a controller is plugged in or removed.
+type Event struct {
+	Action Action
+	Path   string
+}
+
+// Uevent is a kernel uevent as broadcast on NETLINK_KOBJECT_UEVENT.
+type Uevent struct {
+	Action    string
+	DevPath   string
+	Subsystem string
+	DevName   string
+	Env       map[string]string
+}
+
+var (
+	// Debug enables debug logging within the discovery package.
+	Debug bool
+
+	// PollInterval is the rescan period used when kernel uevents are unavailable.
+	PollInterval = 2 * time.Second
+
+	// OpenUeventSocket opens the kernel uevent source. Each Read must return a single message.
+	// It is a package-level variable so tests can feed synthetic uevent payloads.
+	OpenUeventSocket = openUeventSocket
+)
+
+// watchedSubsystems lists the subsystems whose uevents may add or remove a controller.
+var watchedSubsystems = map[string]bool{
+	"input":        true,
+	"hidraw":       true,
+	"leds":         true,
+	"power_supply": true,
+}
+
+// ParseUevent decodes a raw kernel uevent payload ("action@devpath\0KEY=VALUE\0...").
+// Messages re-broadcast by udev (prefixed with "libudev") are rejected.
+func ParseUevent(msg []byte) (Uevent, error) {
+	var ev Uevent
+	if bytes.HasPrefix(msg, []byte("libudev")) {
+		return ev, errors.New("udev message ignored")
+	}
+
+	fields := bytes.Split(msg, []byte{0})
+	header := string(fields[0])
+	at := strings.Index(header, "@")
+	if at == -1 {
+		return ev, errors.New("malformed uevent header")
+	}
+
+	ev.Action = header[:at]
+	ev.DevPath = header[at+1:]
+	ev.Env = make(map[string]string)
+	for _, field := range fields[1:] {
+		key, value, ok := strings.Cut(string(field), "=")
+		if !ok {
+			continue
+		}
+		ev.Env[key] = value
+	}
+
+	if action, ok := ev.Env["ACTION"]; ok {
+		ev.Action = action
+	}
+	if devPath, ok := ev.Env["DEVPATH"]; ok {
+		ev.DevPath = devPath
+	}
+	ev.Subsystem = ev.Env["SUBSYSTEM"]
+	ev.DevName = ev.Env["DEVNAME"]
+
+	return ev, nil
+}
+
+// Relevant reports whether the uevent may change the set of connected controllers.
+func (ev Uevent) Relevant() bool {
+	if ev.Action != "add" && ev.Action != "remove" && ev.Action != "change" {
+		return false
+	}
+	return watchedSubsystems[ev.Subsystem]
+}
+
+// Watch reports controllers as they are plugged in or removed until ctx is cancelled.
+// Controllers already present are reported as Added first. Kernel uevents trigger a
+// rescan; when the uevent socket cannot be opened, Watch falls back to polling.
+func Watch(ctx context.Context) <-chan Event {
+	events := make(chan Event)
+
+	go func() {
+		defer close(events)
+
+		known := make(map[string]bool)
+		if !rescan(ctx, known, events) {
+			return
+		}
+
+		sock, err := OpenUeventSocket()
+		if err != nil {
+			log.Default().Println("Uevent socket unavailable, polling for controllers:", err)
+			poll(ctx, known, events)
+			return
+		}
+
+		triggers := make(chan struct{}, 1)
+		go readUevents(sock, triggers)
+
+		go func() {
+			<-ctx.Done()
+			_ = sock.Close()
+		}()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-triggers:
+				if !ok {
+					if ctx.Err() != nil {
+						return
+					}
+					log.Default().Println("Uevent socket closed, polling for controllers")
+					poll(ctx, known, events)
+					return
+				}
+				if !rescan(ctx, known, events) {
+					return
+				}
+			}
+		}
+	}()
+
+	return events
+}
+
+// readUevents signals triggers for every relevant uevent and closes it when the socket fails.
+func readUevents(sock io.Reader, triggers chan<- struct{}) {
+	defer close(triggers)
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := sock.Read(buf)
+		if err != nil {
+			if Debug {
+				log.Default().Println("Stopping uevent reader:", err)
+			}
+			return
+		}
+
+		ev, err := ParseUevent(buf[:n])
+		if err != nil || !ev.Relevant() {
+			continue
+		}
+		if Debug {
+			log.Default().Printf("Uevent %s %s (%s)\n", ev.Action, ev.DevPath, ev.Subsystem)
+		}
+
+		// A single hotplug emits a burst of uevents; coalesce them into one pending rescan.
+		select {
+		case triggers <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// poll rescans for controllers every PollInterval until ctx is cancelled.
+func poll(ctx context.Context, known map[string]bool, events chan<- Event) {
+	ticker := time.NewTicker(PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !rescan(ctx, known, events) {
+				return
+			}
+		}
+	}
+}
+
+// rescan diffs the current controllers against known and emits the differences.
+// It returns false when ctx was cancelled while emitting.
+func rescan(ctx context.Context, known map[string]bool, events chan<- Event) bool {
+	found, err := FindAllDualSense()
+	if err != nil {
+		log.Default().Println("Error finding DualSense controllers:", err)
+		return true
+	}
+
+	present := make(map[string]bool, len(found))
+	for _, path := range found {
+		present[path] = true
+		if !known[path] {
+			if !emit(ctx, events, Event{Action: Added, Path: path}) {
+				return false
+			}
+			known[path] = true
+		}
+	}
+
+	for path := range known {
+		if !present[path] {
+			if !emit(ctx, events, Event{Action: Removed, Path: path}) {
+				return false
+			}
+			delete(known, path)
+		}
+	}
+
+	return true
+}
+
+func emit(ctx context.Context, events chan<- Event, ev Event) bool {
+	select {
+	case events <- ev:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
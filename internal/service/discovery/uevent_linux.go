@@ -0,0 +1,29 @@
+package discovery
+
+import (
+	"io"
+	"os"
+	"syscall"
+)
+
+// openUeventSocket subscribes to the kernel uevent multicast group.
+func openUeventSocket() (io.ReadCloser, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, syscall.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		return nil, err
+	}
+
+	addr := &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: 1}
+	if err := syscall.Bind(fd, addr); err != nil {
+		_ = syscall.Close(fd)
+		return nil, err
+	}
+
+	// Non-blocking so that closing the file from another goroutine unblocks Read.
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		_ = syscall.Close(fd)
+		return nil, err
+	}
+
+	return os.NewFile(uintptr(fd), "uevent"), nil
+}
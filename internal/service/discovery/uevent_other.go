@@ -0,0 +1,12 @@
+//go:build !linux
+
+package discovery
+
+import (
+	"errors"
+	"io"
+)
+
+func openUeventSocket() (io.ReadCloser, error) {
+	return nil, errors.New("kernel uevents are only available on Linux")
+}
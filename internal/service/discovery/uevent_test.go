@@ -0,0 +1,193 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"dualsense/internal/sysfs"
+)
+
+// hotplugFS is a fake sysfs whose set of js nodes can change while Watch runs.
+type hotplugFS struct {
+	mu    sync.Mutex
+	nodes []string
+}
+
+func (f *hotplugFS) set(nodes ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nodes = nodes
+}
+
+func (f *hotplugFS) ReadFile(_ string) ([]byte, error) {
+	return []byte("DualSense Wireless Controller\n"), nil
+}
+func (f *hotplugFS) WriteFile(_ string, _ []byte, _ os.FileMode) error {
+	return fmt.Errorf("not implemented")
+}
+func (f *hotplugFS) Glob(_ string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.nodes...), nil
+}
+func (f *hotplugFS) Stat(_ string) (os.FileInfo, error) { return nil, fmt.Errorf("not implemented") }
+
+// fakeUeventSocket returns one queued payload per Read, like a netlink datagram socket.
+type fakeUeventSocket struct {
+	msgs   chan []byte
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newFakeUeventSocket() *fakeUeventSocket {
+	return &fakeUeventSocket{msgs: make(chan []byte, 16), closed: make(chan struct{})}
+}
+
+func (s *fakeUeventSocket) Read(p []byte) (int, error) {
+	select {
+	case msg := <-s.msgs:
+		return copy(p, msg), nil
+	case <-s.closed:
+		return 0, io.EOF
+	}
+}
+
+func (s *fakeUeventSocket) Close() error {
+	s.once.Do(func() { close(s.closed) })
+	return nil
+}
+
+func uevent(action, devPath, subsystem, devName string) []byte {
+	msg := fmt.Sprintf("%s@%s\x00ACTION=%s\x00DEVPATH=%s\x00SUBSYSTEM=%s\x00", action, devPath, action, devPath, subsystem)
+	if devName != "" {
+		msg += "DEVNAME=" + devName + "\x00"
+	}
+	return []byte(msg + "SEQNUM=4242\x00")
+}
+
+func TestParseUevent(t *testing.T) {
+	ev, err := ParseUevent(uevent("add", "/devices/virtual/input/input42/js0", "input", "input/js0"))
+	if err != nil {
+		t.Fatalf("ParseUevent error: %v", err)
+	}
+	if ev.Action != "add" || ev.Subsystem != "input" || ev.DevName != "input/js0" {
+		t.Fatalf("unexpected uevent: %+v", ev)
+	}
+	if ev.DevPath != "/devices/virtual/input/input42/js0" {
+		t.Fatalf("unexpected devpath: %s", ev.DevPath)
+	}
+	if !ev.Relevant() {
+		t.Fatalf("expected input uevent to be relevant")
+	}
+
+	tests := []struct {
+		name     string
+		payload  []byte
+		relevant bool
+		wantErr  bool
+	}{
+		{"hidraw remove", uevent("remove", "/devices/x/hidraw/hidraw3", "hidraw", "hidraw3"), true, false},
+		{"leds add", uevent("add", "/devices/x/leds/input42:rgb:indicator", "leds", ""), true, false},
+		{"power supply change", uevent("change", "/devices/x/power_supply/ps-controller-battery-aa", "power_supply", ""), true, false},
+		{"usb bind", uevent("bind", "/devices/pci0000:00/usb1/1-1", "usb", ""), false, false},
+		{"block add", uevent("add", "/devices/virtual/block/loop0", "block", "loop0"), false, false},
+		{"udev rebroadcast", []byte("libudev\x00\xfe\xed\xca\xfe"), false, true},
+		{"garbage", []byte("garbage"), false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev, err := ParseUevent(tt.payload)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseUevent error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && ev.Relevant() != tt.relevant {
+				t.Fatalf("Relevant() = %v, want %v", ev.Relevant(), tt.relevant)
+			}
+		})
+	}
+}
+
+func expectEvent(t *testing.T, events <-chan Event, want Event) {
+	t.Helper()
+	select {
+	case got := <-events:
+		if got != want {
+			t.Fatalf("expected event %+v, got %+v", want, got)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for event %+v", want)
+	}
+}
+
+func expectNoEvent(t *testing.T, events <-chan Event) {
+	t.Helper()
+	select {
+	case got := <-events:
+		t.Fatalf("unexpected event %+v", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWatchUevents(t *testing.T) {
+	oldFS, oldOpen := sysfs.FS, OpenUeventSocket
+	fake := &hotplugFS{}
+	fake.set("/dev/input/js0")
+	sock := newFakeUeventSocket()
+	sysfs.FS = fake
+	OpenUeventSocket = func() (io.ReadCloser, error) { return sock, nil }
+	defer func() { sysfs.FS, OpenUeventSocket = oldFS, oldOpen }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := Watch(ctx)
+
+	// controllers already present are reported first
+	expectEvent(t, events, Event{Action: Added, Path: "/dev/input/js0"})
+
+	fake.set("/dev/input/js0", "/dev/input/js1")
+	sock.msgs <- uevent("add", "/devices/virtual/misc/uhid/0005:054C:0CE6.0002/input/input43/js1", "input", "input/js1")
+	expectEvent(t, events, Event{Action: Added, Path: "/dev/input/js1"})
+
+	// irrelevant uevents must not trigger a rescan
+	fake.set("/dev/input/js1")
+	sock.msgs <- uevent("add", "/devices/virtual/block/loop0", "block", "loop0")
+	expectNoEvent(t, events)
+
+	sock.msgs <- uevent("remove", "/devices/virtual/misc/uhid/0005:054C:0CE6.0001/hidraw/hidraw2", "hidraw", "hidraw2")
+	expectEvent(t, events, Event{Action: Removed, Path: "/dev/input/js0"})
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Fatalf("expected events channel to be closed after cancel")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Watch did not stop after cancel")
+	}
+}
+
+func TestWatchFallsBackToPolling(t *testing.T) {
+	oldFS, oldOpen, oldInterval := sysfs.FS, OpenUeventSocket, PollInterval
+	fake := &hotplugFS{}
+	sysfs.FS = fake
+	OpenUeventSocket = func() (io.ReadCloser, error) { return nil, fmt.Errorf("netlink not permitted") }
+	PollInterval = 10 * time.Millisecond
+	defer func() { sysfs.FS, OpenUeventSocket, PollInterval = oldFS, oldOpen, oldInterval }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := Watch(ctx)
+
+	fake.set("/dev/input/js2")
+	expectEvent(t, events, Event{Action: Added, Path: "/dev/input/js2"})
+
+	fake.set()
+	expectEvent(t, events, Event{Action: Removed, Path: "/dev/input/js2"})
+}
@@ -0,0 +1,210 @@
+// Package drift analyses the resting position of the sticks to detect stick drift.
+package drift
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Window is the number of resting samples kept per axis.
+	Window = 1000
+	// MinSamples is the number of resting samples needed to analyse an axis.
+	MinSamples = 20
+	// RestLimit is the offset beyond which the stick is being moved by the player.
+	RestLimit = 8000
+	// SettleTime is how long samples are ignored after the stick was moved or a
+	// button was released, while the stick returns to rest.
+	SettleTime = 500 * time.Millisecond
+	// DriftOffset is the resting offset beyond which an axis is suspected to drift.
+	DriftOffset = 1200
+
+	// deadzoneStep matches the step of the deadzone sliders.
+	deadzoneStep = 250
+)
+
+// Axis is a stick axis analysed for drift.
+type Axis int
+
+// Stick axes, values are offsets from the center in the joystick interface scale.
+const (
+	LeftX Axis = iota
+	LeftY
+	RightX
+	RightY
+	axisCount
+)
+
+var axisNames = [axisCount]string{"Left X", "Left Y", "Right X", "Right Y"}
+
+func (a Axis) String() string {
+	if a < 0 || a >= axisCount {
+		return fmt.Sprintf("Axis(%d)", int(a))
+	}
+	return axisNames[a]
+}
+
+// Analyzer records the resting values of the stick axes. Samples are only kept
+// while no button is held, so a player using the controller is not mistaken for
+// drift. It is safe for concurrent use and a nil Analyzer ignores every sample.
+type Analyzer struct {
+	mu      sync.Mutex
+	pressed map[int]bool
+	settled time.Time
+	samples [axisCount][]int
+	next    [axisCount]int
+}
+
+// NewAnalyzer returns an empty Analyzer.
+func NewAnalyzer() *Analyzer {
+	return &Analyzer{pressed: make(map[int]bool)}
+}
+
+// Button records a button state change at the given time.
+func (a *Analyzer) Button(button int, pressed bool, at time.Time) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if pressed {
+		a.pressed[button] = true
+		return
+	}
+	delete(a.pressed, button)
+	a.settleUntil(at.Add(SettleTime))
+}
+
+// Axis records a stick axis value at the given time.
+func (a *Analyzer) Axis(axis Axis, value int, at time.Time) {
+	if a == nil || axis < 0 || axis >= axisCount {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if value > RestLimit || value < -RestLimit {
+		a.settleUntil(at.Add(SettleTime))
+		return
+	}
+	if len(a.pressed) > 0 || at.Before(a.settled) {
+		return
+	}
+
+	if len(a.samples[axis]) < Window {
+		a.samples[axis] = append(a.samples[axis], value)
+		return
+	}
+	a.samples[axis][a.next[axis]] = value
+	a.next[axis] = (a.next[axis] + 1) % Window
+}
+
+func (a *Analyzer) settleUntil(t time.Time) {
+	if t.After(a.settled) {
+		a.settled = t
+	}
+}
+
+// AxisReport is the analysis of the resting values of an axis.
+type AxisReport struct {
+	Axis    Axis
+	Samples int
+	// Offset is the mean resting value and Noise its standard deviation.
+	Offset float64
+	Noise  float64
+	// Drifting is set when the axis rests too far from the center.
+	Drifting bool
+}
+
+// Report is the drift analysis of both sticks.
+type Report struct {
+	Axes [axisCount]AxisReport
+	// SuggestedLeft and SuggestedRight are the stick deadzones suppressing the
+	// recorded resting values, 0 when the stick lacks samples.
+	SuggestedLeft  int
+	SuggestedRight int
+}
+
+// Report analyses the samples recorded so far.
+func (a *Analyzer) Report() Report {
+	var r Report
+	if a == nil {
+		return r
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for axis := range r.Axes {
+		r.Axes[axis] = analyse(Axis(axis), a.samples[axis])
+	}
+	r.SuggestedLeft = suggestDeadzone(r.Axes[LeftX], r.Axes[LeftY])
+	r.SuggestedRight = suggestDeadzone(r.Axes[RightX], r.Axes[RightY])
+	return r
+}
+
+func analyse(axis Axis, samples []int) AxisReport {
+	r := AxisReport{Axis: axis, Samples: len(samples)}
+	if len(samples) < MinSamples {
+		return r
+	}
+
+	var sum float64
+	for _, v := range samples {
+		sum += float64(v)
+	}
+	r.Offset = sum / float64(len(samples))
+
+	var variance float64
+	for _, v := range samples {
+		d := float64(v) - r.Offset
+		variance += d * d
+	}
+	r.Noise = math.Sqrt(variance / float64(len(samples)))
+	r.Drifting = math.Abs(r.Offset) > DriftOffset
+	return r
+}
+
+// suggestDeadzone returns the stick deadzone covering the offset plus three
+// standard deviations of both axes, rounded up to the slider step.
+func suggestDeadzone(x, y AxisReport) int {
+	extent := 0.0
+	for _, r := range []AxisReport{x, y} {
+		if r.Samples < MinSamples {
+			continue
+		}
+		extent = math.Max(extent, math.Abs(r.Offset)+3*r.Noise)
+	}
+	if extent == 0 {
+		return 0
+	}
+	return int(math.Ceil(extent/deadzoneStep)) * deadzoneStep
+}
+
+// Drifting reports whether any axis is suspected to drift.
+func (r Report) Drifting() bool {
+	for _, axis := range r.Axes {
+		if axis.Drifting {
+			return true
+		}
+	}
+	return false
+}
+
+// Summary describes the report in a single line.
+func (r Report) Summary() string {
+	var sticks []string
+	if r.Axes[LeftX].Drifting || r.Axes[LeftY].Drifting {
+		sticks = append(sticks, fmt.Sprintf("left stick (deadzone %d suggested)", r.SuggestedLeft))
+	}
+	if r.Axes[RightX].Drifting || r.Axes[RightY].Drifting {
+		sticks = append(sticks, fmt.Sprintf("right stick (deadzone %d suggested)", r.SuggestedRight))
+	}
+	if len(sticks) == 0 {
+		return "No drift detected"
+	}
+	return "Drift suspected on " + strings.Join(sticks, " and ")
+}
@@ -0,0 +1,106 @@
+package drift
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+var t0 = time.Unix(1700000000, 0)
+
+func feed(a *Analyzer, axis Axis, start time.Time, values ...int) time.Time {
+	at := start
+	for _, v := range values {
+		a.Axis(axis, v, at)
+		at = at.Add(10 * time.Millisecond)
+	}
+	return at
+}
+
+func repeat(n int, values ...int) []int {
+	var out []int
+	for i := 0; i < n; i++ {
+		out = append(out, values...)
+	}
+	return out
+}
+
+func TestReportDrift(t *testing.T) {
+	a := NewAnalyzer()
+	// left stick resting off-center on X with some noise, right stick healthy
+	feed(a, LeftX, t0, repeat(20, 2000, 2200, 1800)...)
+	feed(a, LeftY, t0, repeat(20, -100, 100)...)
+	feed(a, RightX, t0, repeat(20, 50, -50)...)
+
+	r := a.Report()
+	left := r.Axes[LeftX]
+	if left.Samples != 60 || math.Abs(left.Offset-2000) > 0.01 {
+		t.Fatalf("unexpected left X analysis %+v", left)
+	}
+	if math.Abs(left.Noise-163.3) > 0.1 {
+		t.Fatalf("expected noise 163.3, got %.1f", left.Noise)
+	}
+	if !left.Drifting || r.Axes[LeftY].Drifting || r.Axes[RightX].Drifting {
+		t.Fatalf("expected only left X to drift, got %+v", r.Axes)
+	}
+	// 2000 + 3*163.3 rounded up to the slider step
+	if r.SuggestedLeft != 2500 {
+		t.Fatalf("expected left deadzone 2500 suggested, got %d", r.SuggestedLeft)
+	}
+	// right Y lacks samples, right X needs 50 + 3*50
+	if r.SuggestedRight != 250 {
+		t.Fatalf("expected right deadzone 250 suggested, got %d", r.SuggestedRight)
+	}
+	if !r.Drifting() {
+		t.Fatalf("expected drift reported")
+	}
+	if got, want := r.Summary(), "Drift suspected on left stick (deadzone 2500 suggested)"; got != want {
+		t.Fatalf("expected summary %q, got %q", want, got)
+	}
+}
+
+func TestAnalyzerIgnoresPlayerInput(t *testing.T) {
+	a := NewAnalyzer()
+
+	// stick held while a button is pressed
+	a.Button(304, true, t0)
+	at := feed(a, LeftX, t0, repeat(30, 3000)...)
+	a.Button(304, false, at)
+	// still returning to rest right after the release
+	at = feed(a, LeftX, at, 2500, 2000)
+	// the player moving the stick, then letting it go
+	at = feed(a, LeftX, at.Add(time.Second), 20000, 32767, 4000)
+
+	if samples := a.Report().Axes[LeftX].Samples; samples != 0 {
+		t.Fatalf("expected player input ignored, got %d samples", samples)
+	}
+
+	feed(a, LeftX, at.Add(SettleTime), repeat(MinSamples, 10)...)
+	r := a.Report()
+	if r.Axes[LeftX].Samples != MinSamples || r.Drifting() {
+		t.Fatalf("expected healthy resting samples, got %+v", r.Axes[LeftX])
+	}
+	if got := r.Summary(); got != "No drift detected" {
+		t.Fatalf("unexpected summary %q", got)
+	}
+}
+
+func TestAnalyzerWindow(t *testing.T) {
+	a := NewAnalyzer()
+	at := feed(a, RightY, t0, repeat(Window, 3000)...)
+	feed(a, RightY, at, repeat(Window, 0)...)
+
+	r := a.Report()
+	if r.Axes[RightY].Samples != Window || r.Axes[RightY].Offset != 0 {
+		t.Fatalf("expected old samples replaced, got %+v", r.Axes[RightY])
+	}
+}
+
+func TestNilAnalyzer(t *testing.T) {
+	var a *Analyzer
+	a.Button(1, true, t0)
+	a.Axis(LeftX, 5000, t0)
+	if a.Report().Drifting() {
+		t.Fatalf("expected empty report")
+	}
+}
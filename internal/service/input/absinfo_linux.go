@@ -0,0 +1,32 @@
+package input
+
+import (
+	"errors"
+	"io"
+	"syscall"
+	"unsafe"
+)
+
+// absInfoSize is the size of a struct input_absinfo: value, minimum, maximum, fuzz,
+// flat and resolution.
+const absInfoSize = 24
+
+// eviocgabs returns the EVIOCGABS ioctl request for an absolute axis.
+func eviocgabs(code uint16) uintptr {
+	const iocRead = 2
+	return uintptr(iocRead<<30 | absInfoSize<<16 | 'E'<<8 | (0x40 + int(code)))
+}
+
+// readAbs reads the current value of an absolute axis with the EVIOCGABS ioctl.
+func readAbs(f io.Reader, code uint16) (int32, error) {
+	file, ok := f.(interface{ Fd() uintptr })
+	if !ok {
+		return 0, errors.New("not an evdev device file")
+	}
+	var info [absInfoSize / 4]int32
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), eviocgabs(code), uintptr(unsafe.Pointer(&info[0])))
+	if errno != 0 {
+		return 0, errno
+	}
+	return info[0], nil
+}
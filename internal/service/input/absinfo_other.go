@@ -0,0 +1,12 @@
+//go:build !linux
+
+package input
+
+import (
+	"errors"
+	"io"
+)
+
+func readAbs(_ io.Reader, _ uint16) (int32, error) {
+	return 0, errors.New("absolute axes can only be read on Linux")
+}
@@ -0,0 +1,151 @@
+// Package input decodes Linux evdev events read from the /dev/input/event* nodes of a DualSense controller.
+package input
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"dualsense/internal/sysfs"
+)
+
+// EventSize is the size of a struct input_event on 64-bit Linux:
+// timeval (16) | type (2) | code (2) | value (4).
+const EventSize = 24
+
+// Event types.
+const (
+	EvSyn = 0x00
+	EvKey = 0x01
+	EvAbs = 0x03
+	EvMsc = 0x04
+)
+
+// Absolute axis codes reported by hid-playstation.
+const (
+	AbsX     = 0x00 // left stick, or accelerometer X on the motion node
+	AbsY     = 0x01
+	AbsZ     = 0x02 // L2, or accelerometer Z on the motion node
+	AbsRX    = 0x03 // right stick, or gyroscope X on the motion node
+	AbsRY    = 0x04
+	AbsRZ    = 0x05 // R2, or gyroscope Z on the motion node
+	AbsHat0X = 0x10 // D-pad
+	AbsHat0Y = 0x11
+)
+
+// MscTimestamp is the sensor timestamp reported by the motion node, in microseconds.
+const MscTimestamp = 0x05
+
+// Kind tells which of the controller input nodes a node is.
+type Kind int
+
+// Input nodes created by hid-playstation for a controller.
+const (
+	KindGamepad Kind = iota
+	KindTouchpad
+	KindMotion
+)
+
+// Event is a decoded struct input_event.
+type Event struct {
+	Time  time.Time
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+// Node is an evdev node of a controller.
+type Node struct {
+	Path string
+	Name string
+	Kind Kind
+}
+
+// OpenEventDevice is the function used to open evdev device files.
+// It is a package-level variable so tests can replay recorded event streams.
+var OpenEventDevice = func(path string) (io.ReadCloser, error) {
+	return os.Open(path)
+}
+
+// ReadAbs returns the current value of the absolute axis code of an evdev device
+// file opened by OpenEventDevice. Unlike events, it reports an axis that has not
+// moved since the file was opened.
+// It is a package-level variable so tests can override it with a fake implementation.
+var ReadAbs = readAbs
+
+// Decode decodes a single input_event.
+func Decode(b []byte) (Event, error) {
+	if len(b) < EventSize {
+		return Event{}, fmt.Errorf("short input event: %d bytes", len(b))
+	}
+	sec := int64(binary.LittleEndian.Uint64(b[0:8]))
+	usec := int64(binary.LittleEndian.Uint64(b[8:16]))
+	return Event{
+		Time:  time.Unix(sec, usec*int64(time.Microsecond)),
+		Type:  binary.LittleEndian.Uint16(b[16:18]),
+		Code:  binary.LittleEndian.Uint16(b[18:20]),
+		Value: int32(binary.LittleEndian.Uint32(b[20:24])),
+	}, nil
+}
+
+// Encode encodes the event as a struct input_event.
+func (e Event) Encode() []byte {
+	b := make([]byte, EventSize)
+	binary.LittleEndian.PutUint64(b[0:8], uint64(e.Time.Unix()))
+	binary.LittleEndian.PutUint64(b[8:16], uint64(e.Time.Nanosecond()/int(time.Microsecond)))
+	binary.LittleEndian.PutUint16(b[16:18], e.Type)
+	binary.LittleEndian.PutUint16(b[18:20], e.Code)
+	binary.LittleEndian.PutUint32(b[20:24], uint32(e.Value))
+	return b
+}
+
+// ReadEvent reads and decodes the next event from r.
+func ReadEvent(r io.Reader) (Event, error) {
+	buf := make([]byte, EventSize)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return Event{}, err
+	}
+	return Decode(buf)
+}
+
+// Nodes returns the evdev nodes belonging to the same HID device as the joystick
+// node jsPath: the gamepad itself, its touchpad and its motion sensors.
+func Nodes(jsPath string) ([]Node, error) {
+	pattern := fmt.Sprintf("/sys/class/input/%s/device/device/input/input*/event*", filepath.Base(jsPath))
+	matches, err := sysfs.FS.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, errors.New("no event node found for " + jsPath)
+	}
+	sort.Strings(matches)
+
+	nodes := make([]Node, 0, len(matches))
+	for _, match := range matches {
+		name, err := sysfs.FS.ReadFile(filepath.Join(filepath.Dir(match), "name"))
+		if err != nil {
+			continue
+		}
+		node := Node{
+			Path: "/dev/input/" + filepath.Base(match),
+			Name: strings.TrimSpace(string(name)),
+		}
+		switch {
+		case strings.HasSuffix(node.Name, "Motion Sensors"):
+			node.Kind = KindMotion
+		case strings.HasSuffix(node.Name, "Touchpad"):
+			node.Kind = KindTouchpad
+		default:
+			node.Kind = KindGamepad
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
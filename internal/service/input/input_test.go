@@ -0,0 +1,126 @@
+package input
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"dualsense/internal/sysfs"
+)
+
+type fakeFS struct {
+	files map[string][]byte
+	globs map[string][]string
+}
+
+func (f fakeFS) ReadFile(path string) ([]byte, error) {
+	if b, ok := f.files[path]; ok {
+		return b, nil
+	}
+	return nil, fmt.Errorf("not found: %s", path)
+}
+func (f fakeFS) WriteFile(_ string, _ []byte, _ os.FileMode) error {
+	return fmt.Errorf("not implemented")
+}
+func (f fakeFS) Glob(pattern string) ([]string, error) { return f.globs[pattern], nil }
+func (f fakeFS) Stat(_ string) (os.FileInfo, error)    { return nil, fmt.Errorf("not implemented") }
+
+func TestDecode(t *testing.T) {
+	// ABS_RX = 200 at 1700000000.250000, as written by the kernel
+	raw := []byte{
+		0x00, 0xf1, 0x53, 0x65, 0x00, 0x00, 0x00, 0x00,
+		0x90, 0xd0, 0x03, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x03, 0x00, 0x03, 0x00,
+		0xc8, 0x00, 0x00, 0x00,
+	}
+
+	ev, err := Decode(raw)
+	if err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+	want := Event{Time: time.Unix(1700000000, 250000000), Type: EvAbs, Code: AbsRX, Value: 200}
+	if !ev.Time.Equal(want.Time) || ev.Type != want.Type || ev.Code != want.Code || ev.Value != want.Value {
+		t.Fatalf("Decode = %+v, want %+v", ev, want)
+	}
+	if !bytes.Equal(ev.Encode(), raw) {
+		t.Fatalf("Encode = % x, want % x", ev.Encode(), raw)
+	}
+
+	if _, err := Decode(raw[:16]); err == nil {
+		t.Fatalf("expected error for short event")
+	}
+}
+
+func TestReadEvent(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	events := []Event{
+		{Time: at, Type: EvKey, Code: 0x130, Value: 1},
+		{Time: at, Type: EvAbs, Code: AbsZ, Value: -1},
+		{Time: at, Type: EvSyn},
+	}
+	var stream bytes.Buffer
+	for _, ev := range events {
+		stream.Write(ev.Encode())
+	}
+	stream.Write([]byte{0x01, 0x02}) // truncated trailing event
+
+	for i, want := range events {
+		got, err := ReadEvent(&stream)
+		if err != nil {
+			t.Fatalf("event %d: ReadEvent error: %v", i, err)
+		}
+		if got.Type != want.Type || got.Code != want.Code || got.Value != want.Value {
+			t.Fatalf("event %d: got %+v, want %+v", i, got, want)
+		}
+	}
+	if _, err := ReadEvent(&stream); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected unexpected EOF, got %v", err)
+	}
+}
+
+func TestNodes(t *testing.T) {
+	old := sysfs.FS
+	defer func() { sysfs.FS = old }()
+
+	hid := "/sys/class/input/js0/device/device/input"
+	sysfs.FS = fakeFS{
+		globs: map[string][]string{
+			"/sys/class/input/js0/device/device/input/input*/event*": {
+				hid + "/input14/event9",
+				hid + "/input12/event7",
+				hid + "/input13/event8",
+			},
+		},
+		files: map[string][]byte{
+			hid + "/input12/name": []byte("DualSense Wireless Controller\n"),
+			hid + "/input13/name": []byte("DualSense Wireless Controller Motion Sensors\n"),
+			hid + "/input14/name": []byte("DualSense Wireless Controller Touchpad\n"),
+		},
+	}
+
+	nodes, err := Nodes("/dev/input/js0")
+	if err != nil {
+		t.Fatalf("Nodes error: %v", err)
+	}
+	want := []Node{
+		{Path: "/dev/input/event7", Name: "DualSense Wireless Controller", Kind: KindGamepad},
+		{Path: "/dev/input/event8", Name: "DualSense Wireless Controller Motion Sensors", Kind: KindMotion},
+		{Path: "/dev/input/event9", Name: "DualSense Wireless Controller Touchpad", Kind: KindTouchpad},
+	}
+	if len(nodes) != len(want) {
+		t.Fatalf("Nodes = %+v, want %+v", nodes, want)
+	}
+	for i := range want {
+		if nodes[i] != want[i] {
+			t.Fatalf("node %d = %+v, want %+v", i, nodes[i], want[i])
+		}
+	}
+
+	if _, err := Nodes("/dev/input/js1"); err == nil {
+		t.Fatalf("expected error when the joystick has no event node")
+	}
+}
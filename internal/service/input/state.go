@@ -0,0 +1,223 @@
+package input
+
+import "sync"
+
+// Button codes reported by hid-playstation.
+const (
+	BtnLeft   = 0x110 // touchpad click, on the touchpad node
+	BtnSouth  = 0x130 // Cross
+	BtnEast   = 0x131 // Circle
+	BtnNorth  = 0x133 // Triangle
+	BtnWest   = 0x134 // Square
+	BtnTL     = 0x136 // L1
+	BtnTR     = 0x137 // R1
+	BtnTL2    = 0x138 // L2 fully pressed
+	BtnTR2    = 0x139 // R2 fully pressed
+	BtnSelect = 0x13a // Create
+	BtnStart  = 0x13b // Options
+	BtnMode   = 0x13c // PS
+	BtnThumbL = 0x13d // L3
+	BtnThumbR = 0x13e // R3
+)
+
+// Multi-touch axis codes reported by the touchpad node.
+const (
+	AbsMTSlot       = 0x2f
+	AbsMTPositionX  = 0x35
+	AbsMTPositionY  = 0x36
+	AbsMTTrackingID = 0x39
+)
+
+// Touchpad resolution of the DualSense.
+const (
+	TouchpadWidth  = 1920
+	TouchpadHeight = 1080
+)
+
+// joystickButtons maps the joystick interface button numbers to their evdev codes:
+// hid-playstation registers the buttons in this order.
+var joystickButtons = []uint16{
+	BtnSouth, BtnEast, BtnNorth, BtnWest, BtnTL, BtnTR, BtnTL2, BtnTR2,
+	BtnSelect, BtnStart, BtnMode, BtnThumbL, BtnThumbR,
+}
+
+// JoystickButton returns the evdev code of a joystick interface button number.
+func JoystickButton(index byte) (uint16, bool) {
+	if int(index) >= len(joystickButtons) {
+		return 0, false
+	}
+	return joystickButtons[index], true
+}
+
+// TouchPoint is a finger on the touchpad, in touchpad coordinates.
+type TouchPoint struct {
+	Active bool
+	X, Y   int
+}
+
+// Motion is the state of the motion sensors, in the units of the motion node:
+// the accelerometer and gyroscope X, Y and Z axes, and the sensor timestamp in
+// microseconds, which wraps around.
+type Motion struct {
+	Accel     [3]int
+	Gyro      [3]int
+	Timestamp uint32
+}
+
+// Snapshot is the state of the controller inputs at a point in time. Sticks are
+// offsets from the center and triggers range from 0 to 32767, in the joystick
+// interface scale; the d-pad is -1, 0 or 1 on each axis.
+type Snapshot struct {
+	LeftX, LeftY   int
+	RightX, RightY int
+	L2, R2         int
+	HatX, HatY     int
+	Buttons        map[uint16]bool
+	Touch          [2]TouchPoint
+	Motion         Motion
+}
+
+// State holds the live state of the controller inputs. The monitor updates it on
+// every event while the UI reads snapshots of it, so it is safe for concurrent use.
+// A nil State ignores every update.
+type State struct {
+	mu   sync.Mutex
+	snap Snapshot
+	slot int
+}
+
+// NewState returns the state of a controller at rest.
+func NewState() *State {
+	return &State{snap: Snapshot{Buttons: make(map[uint16]bool)}}
+}
+
+// Apply updates the state from an evdev event of a gamepad, touchpad or motion
+// sensors node.
+func (s *State) Apply(ev Event, kind Kind) {
+	if s == nil {
+		return
+	}
+	switch {
+	case ev.Type == EvKey:
+		s.SetButton(ev.Code, ev.Value != 0)
+	case ev.Type == EvAbs && kind == KindGamepad:
+		switch ev.Code {
+		case AbsX, AbsY, AbsRX, AbsRY:
+			s.SetAxis(ev.Code, (int(ev.Value)-128)*256)
+		case AbsZ, AbsRZ:
+			s.SetAxis(ev.Code, int(ev.Value)*128)
+		default:
+			s.SetAxis(ev.Code, int(ev.Value))
+		}
+	case ev.Type == EvAbs && kind == KindTouchpad:
+		s.applyTouch(ev.Code, int(ev.Value))
+	case kind == KindMotion:
+		s.applyMotion(ev)
+	}
+}
+
+// SetAxis updates a stick, trigger or d-pad axis, identified by its evdev code.
+func (s *State) SetAxis(code uint16, value int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch code {
+	case AbsX:
+		s.snap.LeftX = value
+	case AbsY:
+		s.snap.LeftY = value
+	case AbsRX:
+		s.snap.RightX = value
+	case AbsRY:
+		s.snap.RightY = value
+	case AbsZ:
+		s.snap.L2 = value
+	case AbsRZ:
+		s.snap.R2 = value
+	case AbsHat0X:
+		s.snap.HatX = sign(value)
+	case AbsHat0Y:
+		s.snap.HatY = sign(value)
+	}
+}
+
+// SetButton updates a button, identified by its evdev code.
+func (s *State) SetButton(code uint16, pressed bool) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if pressed {
+		s.snap.Buttons[code] = true
+	} else {
+		delete(s.snap.Buttons, code)
+	}
+}
+
+func (s *State) applyTouch(code uint16, value int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if code == AbsMTSlot {
+		s.slot = value
+		return
+	}
+	if s.slot < 0 || s.slot >= len(s.snap.Touch) {
+		return
+	}
+	point := &s.snap.Touch[s.slot]
+	switch code {
+	case AbsMTTrackingID:
+		point.Active = value >= 0
+	case AbsMTPositionX:
+		point.X = value
+	case AbsMTPositionY:
+		point.Y = value
+	}
+}
+
+func (s *State) applyMotion(ev Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	motion := &s.snap.Motion
+	switch {
+	case ev.Type == EvMsc && ev.Code == MscTimestamp:
+		motion.Timestamp = uint32(ev.Value)
+	case ev.Type == EvAbs && ev.Code >= AbsX && ev.Code <= AbsZ:
+		motion.Accel[ev.Code-AbsX] = int(ev.Value)
+	case ev.Type == EvAbs && ev.Code >= AbsRX && ev.Code <= AbsRZ:
+		motion.Gyro[ev.Code-AbsRX] = int(ev.Value)
+	}
+}
+
+// Snapshot returns a copy of the current state.
+func (s *State) Snapshot() Snapshot {
+	if s == nil {
+		return Snapshot{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snap := s.snap
+	snap.Buttons = make(map[uint16]bool, len(s.snap.Buttons))
+	for code, pressed := range s.snap.Buttons {
+		snap.Buttons[code] = pressed
+	}
+	return snap
+}
+
+func sign(v int) int {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	}
+	return 0
+}
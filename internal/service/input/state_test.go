@@ -0,0 +1,87 @@
+package input
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStateApply(t *testing.T) {
+	s := NewState()
+	events := []struct {
+		kind Kind
+		ev   Event
+	}{
+		{KindGamepad, Event{Type: EvAbs, Code: AbsX, Value: 255}},
+		{KindGamepad, Event{Type: EvAbs, Code: AbsRY, Value: 0}},
+		{KindGamepad, Event{Type: EvAbs, Code: AbsZ, Value: 128}},
+		{KindGamepad, Event{Type: EvAbs, Code: AbsHat0Y, Value: -1}},
+		{KindGamepad, Event{Type: EvKey, Code: BtnSouth, Value: 1}},
+		{KindGamepad, Event{Type: EvKey, Code: BtnEast, Value: 1}},
+		{KindGamepad, Event{Type: EvKey, Code: BtnEast, Value: 0}},
+		{KindTouchpad, Event{Type: EvAbs, Code: AbsMTSlot, Value: 1}},
+		{KindTouchpad, Event{Type: EvAbs, Code: AbsMTTrackingID, Value: 7}},
+		{KindTouchpad, Event{Type: EvAbs, Code: AbsMTPositionX, Value: 820}},
+		{KindTouchpad, Event{Type: EvAbs, Code: AbsMTPositionY, Value: 400}},
+		{KindTouchpad, Event{Type: EvKey, Code: BtnLeft, Value: 1}},
+		// the touchpad X axis is not the left stick
+		{KindTouchpad, Event{Type: EvAbs, Code: AbsX, Value: 820}},
+		// nor are the motion sensor axes
+		{KindMotion, Event{Type: EvAbs, Code: AbsX, Value: -200}},
+		{KindMotion, Event{Type: EvAbs, Code: AbsZ, Value: 8192}},
+		{KindMotion, Event{Type: EvAbs, Code: AbsRX, Value: 15}},
+		{KindMotion, Event{Type: EvAbs, Code: AbsRZ, Value: -3}},
+		{KindMotion, Event{Type: EvMsc, Code: MscTimestamp, Value: -4000}},
+	}
+	for _, e := range events {
+		s.Apply(e.ev, e.kind)
+	}
+
+	want := Snapshot{
+		LeftX:   32512,
+		RightY:  -32768,
+		L2:      16384,
+		HatY:    -1,
+		Buttons: map[uint16]bool{BtnSouth: true, BtnLeft: true},
+		Touch:   [2]TouchPoint{{}, {Active: true, X: 820, Y: 400}},
+		Motion:  Motion{Accel: [3]int{-200, 0, 8192}, Gyro: [3]int{15, 0, -3}, Timestamp: 1<<32 - 4000},
+	}
+	got := s.Snapshot()
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Snapshot = %+v, want %+v", got, want)
+	}
+
+	// lifting the finger and releasing the click
+	s.Apply(Event{Type: EvAbs, Code: AbsMTTrackingID, Value: -1}, KindTouchpad)
+	s.Apply(Event{Type: EvKey, Code: BtnLeft, Value: 0}, KindTouchpad)
+	got = s.Snapshot()
+	if got.Touch[1].Active || got.Buttons[BtnLeft] {
+		t.Fatalf("expected finger lifted, got %+v", got)
+	}
+
+	// snapshots are copies
+	got.Buttons[BtnNorth] = true
+	if s.Snapshot().Buttons[BtnNorth] {
+		t.Fatalf("expected snapshot buttons to be a copy")
+	}
+}
+
+func TestJoystickButton(t *testing.T) {
+	if code, ok := JoystickButton(0); !ok || code != BtnSouth {
+		t.Fatalf("expected button 0 to be Cross, got %#x", code)
+	}
+	if code, ok := JoystickButton(12); !ok || code != BtnThumbR {
+		t.Fatalf("expected button 12 to be R3, got %#x", code)
+	}
+	if _, ok := JoystickButton(13); ok {
+		t.Fatalf("expected no button 13")
+	}
+}
+
+func TestNilState(t *testing.T) {
+	var s *State
+	s.Apply(Event{Type: EvKey, Code: BtnSouth, Value: 1}, KindGamepad)
+	s.SetAxis(AbsX, 100)
+	if snap := s.Snapshot(); snap.Buttons != nil {
+		t.Fatalf("expected empty snapshot, got %+v", snap)
+	}
+}
@@ -0,0 +1,326 @@
+package leds
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"dualsense/internal/config"
+)
+
+// DefaultFrameRate is the frame rate of the animations when none is configured.
+const DefaultFrameRate = 40
+
+// FrameRate is the number of frames per second the animations are played at.
+var FrameRate = DefaultFrameRate
+
+// Easing shapes the blend from a keyframe into the next one.
+type Easing string
+
+// Easings: a constant speed, a slow start and end, or holding the keyframe until
+// the next one.
+const (
+	EasingLinear Easing = "linear"
+	EasingEase   Easing = "ease"
+	EasingStep   Easing = "step"
+)
+
+// Keyframe is a lightbar color and player LED mask, blended into the next keyframe
+// over Duration. Players uses the bit order of output reports: bit 0 is the
+// leftmost LED.
+type Keyframe struct {
+	R, G, B  int
+	Players  byte
+	Duration time.Duration
+	Easing   Easing
+}
+
+// Animation is a looping sequence of keyframes. Lightbar and Players tell which
+// LEDs it drives.
+type Animation struct {
+	Keyframes []Keyframe
+	Lightbar  bool
+	Players   bool
+}
+
+// Frame is what the LEDs show at a point of an animation.
+type Frame struct {
+	R, G, B int
+	Players byte
+}
+
+// Length returns the duration of one loop of the animation.
+func (a Animation) Length() time.Duration {
+	var length time.Duration
+	for _, k := range a.Keyframes {
+		length += k.Duration
+	}
+	return length
+}
+
+// Frame returns what the LEDs show t into the animation. The color is blended
+// between keyframes, the player LEDs switch at each keyframe.
+func (a Animation) Frame(t time.Duration) Frame {
+	if len(a.Keyframes) == 0 {
+		return Frame{}
+	}
+	length := a.Length()
+	if length <= 0 || t < 0 {
+		t = 0
+	} else {
+		t %= length
+	}
+
+	for i, from := range a.Keyframes {
+		if t >= from.Duration {
+			t -= from.Duration
+			continue
+		}
+		to := a.Keyframes[(i+1)%len(a.Keyframes)]
+		x := ease(from.Easing, float64(t)/float64(from.Duration))
+		return Frame{
+			R:       blend(from.R, to.R, x),
+			G:       blend(from.G, to.G, x),
+			B:       blend(from.B, to.B, x),
+			Players: from.Players,
+		}
+	}
+	first := a.Keyframes[0]
+	return Frame{R: first.R, G: first.G, B: first.B, Players: first.Players}
+}
+
+// ease maps the progress x, from 0 to 1, through an easing.
+func ease(easing Easing, x float64) float64 {
+	switch easing {
+	case EasingStep:
+		return 0
+	case EasingEase:
+		return (1 - math.Cos(math.Pi*x)) / 2
+	}
+	return x
+}
+
+func blend(from, to int, x float64) int {
+	return int(math.Round(float64(from) + float64(to-from)*x))
+}
+
+// frameInterval returns the time between two frames at FrameRate.
+func frameInterval() time.Duration {
+	fps := FrameRate
+	if fps <= 0 {
+		fps = DefaultFrameRate
+	}
+	return time.Second / time.Duration(fps)
+}
+
+// player shows the frames of an animation started at start, skipping the frames
+// identical to the last one shown.
+type player struct {
+	anim  Animation
+	start time.Time
+	show  func(Frame)
+	last  Frame
+	shown bool
+}
+
+func (p *player) draw(now time.Time) {
+	f := p.anim.Frame(now.Sub(p.start))
+	if p.shown && f == p.last {
+		return
+	}
+	p.last, p.shown = f, true
+	p.show(f)
+}
+
+// runAnimation shows the frames of anim at FrameRate until ctx is cancelled.
+func runAnimation(ctx context.Context, anim Animation, show func(Frame)) {
+	p := &player{anim: anim, start: Clock.Now(), show: show}
+	p.draw(p.start)
+
+	ticker := Clock.NewTicker(frameInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C():
+			p.draw(now)
+		}
+	}
+}
+
+// Built-in animations, played with the color of the controller.
+const (
+	AnimationBreathe = "breathe"
+	AnimationRainbow = "rainbow"
+	AnimationStrobe  = "strobe"
+	AnimationFade    = "fade"
+)
+
+// DefaultAnimation is played when no animation is selected.
+const DefaultAnimation = AnimationBreathe
+
+var builtinAnimations = []string{AnimationBreathe, AnimationRainbow, AnimationStrobe, AnimationFade}
+
+// Breathe slowly dims the color to a tenth and brightens it back.
+func Breathe(r, g, b int) Animation {
+	return pulse(r, g, b, 0.1, 3*time.Second)
+}
+
+// Rainbow cycles through the hues.
+func Rainbow() Animation {
+	hues := [][3]int{{255, 0, 0}, {255, 255, 0}, {0, 255, 0}, {0, 255, 255}, {0, 0, 255}, {255, 0, 255}}
+	anim := Animation{Lightbar: true}
+	for _, c := range hues {
+		anim.Keyframes = append(anim.Keyframes, Keyframe{R: c[0], G: c[1], B: c[2], Duration: time.Second, Easing: EasingLinear})
+	}
+	return anim
+}
+
+// Strobe flashes the color.
+func Strobe(r, g, b int) Animation {
+	return Animation{Lightbar: true, Keyframes: []Keyframe{
+		{R: r, G: g, B: b, Duration: 100 * time.Millisecond, Easing: EasingStep},
+		{Duration: 400 * time.Millisecond, Easing: EasingStep},
+	}}
+}
+
+// Fade fades the color out and in at a constant speed.
+func Fade(r, g, b int) Animation {
+	return Animation{Lightbar: true, Keyframes: []Keyframe{
+		{R: r, G: g, B: b, Duration: 2 * time.Second, Easing: EasingLinear},
+		{Duration: 2 * time.Second, Easing: EasingLinear},
+	}}
+}
+
+// pulse blends the color between low times its brightness and full brightness,
+// starting dimmed.
+func pulse(r, g, b int, low float64, period time.Duration) Animation {
+	dim := func(v int) int { return int(math.Round(float64(v) * low)) }
+	return Animation{Lightbar: true, Keyframes: []Keyframe{
+		{R: dim(r), G: dim(g), B: dim(b), Duration: period / 2, Easing: EasingEase},
+		{R: r, G: g, B: b, Duration: period / 2, Easing: EasingEase},
+	}}
+}
+
+// chargingPulse is the lightbar animation while charging, a pulse of the battery color.
+func chargingPulse(percent float64) Animation {
+	r, g := batteryColor(percent)
+	return pulse(r, g, 0, 0.6, 1600*time.Millisecond)
+}
+
+// chargingPlayers is the player LED animation while charging, filling from the center.
+var chargingPlayers = Animation{Players: true, Keyframes: []Keyframe{
+	{Players: playerMask("0", "0", "1"), Duration: 800 * time.Millisecond, Easing: EasingStep},
+	{Players: playerMask("0", "1", "1"), Duration: 800 * time.Millisecond, Easing: EasingStep},
+	{Players: playerMask("1", "1", "1"), Duration: 800 * time.Millisecond, Easing: EasingStep},
+}}
+
+// AnimationNames returns the names of the built-in animations, then of the
+// user-defined ones not replacing a built-in one, in alphabetical order.
+func AnimationNames(user map[string]config.Animation) []string {
+	names := append([]string(nil), builtinAnimations...)
+	var extra []string
+	for name := range user {
+		if !slices.Contains(builtinAnimations, name) {
+			extra = append(extra, name)
+		}
+	}
+	slices.Sort(extra)
+	return append(names, extra...)
+}
+
+// LoadAnimation returns the animation called name: the user-defined one, or the
+// built-in one played with the color r, g, b. An empty name is the default animation.
+func LoadAnimation(name string, user map[string]config.Animation, r, g, b int) (Animation, error) {
+	if name == "" {
+		name = DefaultAnimation
+	}
+	if anim, ok := user[name]; ok {
+		parsed, err := ParseAnimation(anim)
+		if err != nil {
+			return Animation{}, fmt.Errorf("animation %q: %w", name, err)
+		}
+		return parsed, nil
+	}
+	switch name {
+	case AnimationBreathe:
+		return Breathe(r, g, b), nil
+	case AnimationRainbow:
+		return Rainbow(), nil
+	case AnimationStrobe:
+		return Strobe(r, g, b), nil
+	case AnimationFade:
+		return Fade(r, g, b), nil
+	}
+	return Animation{}, fmt.Errorf("unknown animation %q", name)
+}
+
+// ParseAnimation converts a user-defined animation. It drives the lightbar when a
+// keyframe has a color, and the player LEDs when one has players.
+func ParseAnimation(c config.Animation) (Animation, error) {
+	if len(c.Keyframes) == 0 {
+		return Animation{}, fmt.Errorf("no keyframes")
+	}
+	var anim Animation
+	for i, kc := range c.Keyframes {
+		var k Keyframe
+		var err error
+		if k.Duration, err = time.ParseDuration(kc.Duration); err != nil || k.Duration < 0 {
+			return Animation{}, fmt.Errorf("keyframe %d: invalid duration %q", i+1, kc.Duration)
+		}
+		switch easing := Easing(kc.Easing); easing {
+		case "":
+			k.Easing = EasingLinear
+		case EasingLinear, EasingEase, EasingStep:
+			k.Easing = easing
+		default:
+			return Animation{}, fmt.Errorf("keyframe %d: unknown easing %q", i+1, kc.Easing)
+		}
+		if kc.Color != "" {
+			if k.R, k.G, k.B, err = parseColor(kc.Color); err != nil {
+				return Animation{}, fmt.Errorf("keyframe %d: %w", i+1, err)
+			}
+			anim.Lightbar = true
+		}
+		if kc.Players != "" {
+			if k.Players, err = parsePlayers(kc.Players); err != nil {
+				return Animation{}, fmt.Errorf("keyframe %d: %w", i+1, err)
+			}
+			anim.Players = true
+		}
+		anim.Keyframes = append(anim.Keyframes, k)
+	}
+	if anim.Length() <= 0 {
+		return Animation{}, fmt.Errorf("zero length")
+	}
+	return anim, nil
+}
+
+// parseColor decodes a "#RRGGBB" color.
+func parseColor(s string) (int, int, int, error) {
+	hex := strings.TrimPrefix(s, "#")
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 6 || err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid color %q", s)
+	}
+	return int(v >> 16), int(v >> 8 & 0xFF), int(v & 0xFF), nil
+}
+
+// parsePlayers decodes a player LED pattern such as "10101", leftmost LED first.
+func parsePlayers(s string) (byte, error) {
+	if len(s) != 5 || strings.Trim(s, "01") != "" {
+		return 0, fmt.Errorf("invalid players %q", s)
+	}
+	var mask byte
+	for i, c := range s {
+		if c == '1' {
+			mask |= 1 << i
+		}
+	}
+	return mask, nil
+}
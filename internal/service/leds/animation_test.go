@@ -0,0 +1,219 @@
+package leds
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"dualsense/internal/config"
+	"dualsense/internal/hidraw"
+	"dualsense/internal/hidraw/hidrawtest"
+)
+
+func TestAnimationFrame(t *testing.T) {
+	anim := Animation{Keyframes: []Keyframe{
+		{R: 0, G: 0, B: 0, Players: 0x04, Duration: time.Second, Easing: EasingLinear},
+		{R: 200, G: 100, B: 0, Players: 0x0E, Duration: time.Second, Easing: EasingEase},
+		{R: 0, G: 0, B: 200, Players: 0x1F, Duration: time.Second, Easing: EasingStep},
+	}}
+
+	tests := []struct {
+		at   time.Duration
+		want Frame
+	}{
+		{0, Frame{0, 0, 0, 0x04}},
+		{250 * time.Millisecond, Frame{50, 25, 0, 0x04}},
+		{500 * time.Millisecond, Frame{100, 50, 0, 0x04}},
+		{time.Second, Frame{200, 100, 0, 0x0E}},
+		// eased: slow start, half way at the middle
+		{1100 * time.Millisecond, Frame{195, 98, 5, 0x0E}},
+		{1500 * time.Millisecond, Frame{100, 50, 100, 0x0E}},
+		// held until the next keyframe
+		{2900 * time.Millisecond, Frame{0, 0, 200, 0x1F}},
+		// looped
+		{3250 * time.Millisecond, Frame{50, 25, 0, 0x04}},
+		{-time.Second, Frame{0, 0, 0, 0x04}},
+	}
+	for _, tt := range tests {
+		if got := anim.Frame(tt.at); got != tt.want {
+			t.Errorf("Frame(%v) = %+v, want %+v", tt.at, got, tt.want)
+		}
+	}
+	if got := anim.Length(); got != 3*time.Second {
+		t.Errorf("Length() = %v, want 3s", got)
+	}
+	if got := (Animation{}).Frame(time.Second); got != (Frame{}) {
+		t.Errorf("empty animation Frame = %+v", got)
+	}
+}
+
+func TestBuiltinAnimations(t *testing.T) {
+	breathe := Breathe(200, 100, 0)
+	if got := breathe.Frame(0); got != (Frame{R: 20, G: 10}) {
+		t.Errorf("breathe starts at %+v, want a tenth of the color", got)
+	}
+	if got := breathe.Frame(1500 * time.Millisecond); got != (Frame{R: 200, G: 100}) {
+		t.Errorf("breathe peaks at %+v, want the color", got)
+	}
+
+	rainbow := Rainbow()
+	if got := rainbow.Frame(500 * time.Millisecond); got != (Frame{R: 255, G: 128}) {
+		t.Errorf("rainbow between red and yellow = %+v", got)
+	}
+	if got := rainbow.Frame(4 * time.Second); got != (Frame{B: 255}) {
+		t.Errorf("rainbow at 4s = %+v, want blue", got)
+	}
+
+	strobe := Strobe(255, 255, 255)
+	if got := strobe.Frame(50 * time.Millisecond); got != (Frame{R: 255, G: 255, B: 255}) {
+		t.Errorf("strobe flash = %+v", got)
+	}
+	if got := strobe.Frame(300 * time.Millisecond); got != (Frame{}) {
+		t.Errorf("strobe between flashes = %+v, want off", got)
+	}
+
+	fade := Fade(0, 0, 200)
+	if got := fade.Frame(time.Second); got != (Frame{B: 100}) {
+		t.Errorf("fade half way = %+v", got)
+	}
+
+	for _, anim := range []Animation{breathe, rainbow, strobe, fade} {
+		if !anim.Lightbar || anim.Players {
+			t.Errorf("built-in animations drive the lightbar only, got %+v", anim)
+		}
+	}
+}
+
+func TestChargingAnimations(t *testing.T) {
+	pulse := chargingPulse(100)
+	if got := pulse.Frame(0); got != (Frame{G: 153}) {
+		t.Errorf("charging pulse starts at %+v, want 60%% green", got)
+	}
+	if got := pulse.Frame(800 * time.Millisecond); got != (Frame{G: 255}) {
+		t.Errorf("charging pulse peaks at %+v, want green", got)
+	}
+
+	var masks []byte
+	for at := time.Duration(0); at < 3200*time.Millisecond; at += 400 * time.Millisecond {
+		masks = append(masks, chargingPlayers.Frame(at).Players)
+	}
+	want := []byte{0x04, 0x04, 0x0E, 0x0E, 0x1F, 0x1F, 0x04, 0x04}
+	if !slices.Equal(masks, want) {
+		t.Errorf("charging player LEDs = %x, want %x", masks, want)
+	}
+}
+
+func TestParseAnimation(t *testing.T) {
+	anim, err := ParseAnimation(config.Animation{Keyframes: []config.Keyframe{
+		{Color: "#FF8000", Players: "10001", Duration: "500ms", Easing: "ease"},
+		{Color: "0000ff", Duration: "1s"},
+	}})
+	if err != nil {
+		t.Fatalf("ParseAnimation error: %v", err)
+	}
+	want := []Keyframe{
+		{R: 255, G: 128, B: 0, Players: 0x11, Duration: 500 * time.Millisecond, Easing: EasingEase},
+		{R: 0, G: 0, B: 255, Duration: time.Second, Easing: EasingLinear},
+	}
+	if !slices.Equal(anim.Keyframes, want) || !anim.Lightbar || !anim.Players {
+		t.Fatalf("ParseAnimation = %+v, want keyframes %+v driving both LEDs", anim, want)
+	}
+
+	players, err := ParseAnimation(config.Animation{Keyframes: []config.Keyframe{{Players: "00100", Duration: "1s", Easing: "step"}}})
+	if err != nil || players.Lightbar || !players.Players {
+		t.Fatalf("player-only animation = %+v, %v", players, err)
+	}
+
+	invalid := []config.Keyframe{
+		{Color: "#FF80", Duration: "1s"},
+		{Color: "#GG0000", Duration: "1s"},
+		{Players: "10201", Duration: "1s"},
+		{Players: "101", Duration: "1s"},
+		{Color: "#FF0000", Duration: "soon"},
+		{Color: "#FF0000", Duration: "-1s"},
+		{Color: "#FF0000", Duration: "0s"},
+		{Color: "#FF0000", Duration: "1s", Easing: "bounce"},
+	}
+	for _, k := range invalid {
+		if _, err := ParseAnimation(config.Animation{Keyframes: []config.Keyframe{k}}); err == nil {
+			t.Errorf("expected an error for keyframe %+v", k)
+		}
+	}
+	if _, err := ParseAnimation(config.Animation{}); err == nil {
+		t.Errorf("expected an error without keyframes")
+	}
+}
+
+func TestLoadAnimation(t *testing.T) {
+	user := map[string]config.Animation{
+		"police":  {Keyframes: []config.Keyframe{{Color: "#FF0000", Duration: "300ms", Easing: "step"}, {Color: "#0000FF", Duration: "300ms", Easing: "step"}}},
+		"breathe": {Keyframes: []config.Keyframe{{Color: "#00FF00", Duration: "1s"}}},
+		"broken":  {},
+	}
+
+	if anim, err := LoadAnimation("police", user, 0, 0, 0); err != nil || anim.Frame(400*time.Millisecond) != (Frame{B: 255}) {
+		t.Errorf("user animation = %+v, %v", anim, err)
+	}
+	// a user-defined animation replaces the built-in one of the same name
+	if anim, err := LoadAnimation("breathe", user, 255, 0, 0); err != nil || anim.Frame(0) != (Frame{G: 255}) {
+		t.Errorf("replaced built-in animation = %+v, %v", anim, err)
+	}
+	if anim, err := LoadAnimation("", nil, 200, 0, 0); err != nil || anim.Frame(0) != (Frame{R: 20}) {
+		t.Errorf("default animation = %+v, %v", anim, err)
+	}
+	if _, err := LoadAnimation("strobe", user, 255, 255, 255); err != nil {
+		t.Errorf("built-in animation error: %v", err)
+	}
+	for _, name := range []string{"broken", "disco"} {
+		if _, err := LoadAnimation(name, user, 0, 0, 0); err == nil {
+			t.Errorf("expected an error loading %q", name)
+		}
+	}
+
+	names := AnimationNames(user)
+	want := []string{"breathe", "rainbow", "strobe", "fade", "broken", "police"}
+	if !slices.Equal(names, want) {
+		t.Errorf("AnimationNames = %v, want %v", names, want)
+	}
+}
+
+func TestHidrawRunAnimation(t *testing.T) {
+	fake := useFakeClock(t)
+	dev := hidrawtest.Use(t, "0003")
+	oldRate := FrameRate
+	FrameRate = 10
+	defer func() { FrameRate = oldRate }()
+
+	anim, err := ParseAnimation(config.Animation{Keyframes: []config.Keyframe{
+		{Color: "#000000", Players: "00100", Duration: "200ms"},
+		{Color: "#C80000", Players: "11111", Duration: "200ms"},
+	}})
+	if err != nil {
+		t.Fatalf("ParseAnimation error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Hidraw{}.RunAnimation(ctx, "/dev/input/js0", anim)
+	}()
+	fake.BlockUntil(1)
+	fake.Advance(100 * time.Millisecond)
+	cancel()
+	<-done
+
+	reports := dev.Reports(t)
+	if len(reports) != 2 {
+		t.Fatalf("expected a report per frame, got %d", len(reports))
+	}
+	for _, r := range reports {
+		if r.Flags1 != hidraw.Flag1Lightbar|hidraw.Flag1PlayerIndicator || r.PlayerLeds != 0x04 {
+			t.Fatalf("unexpected report flags %02x players %05b", r.Flags1, r.PlayerLeds)
+		}
+	}
+	if r := reports[1]; r.Red != 100 || r.Green != 0 || r.Blue != 0 {
+		t.Fatalf("unexpected color (%d, %d, %d) at 100ms", r.Red, r.Green, r.Blue)
+	}
+}
@@ -0,0 +1,230 @@
+package leds
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	"dualsense/internal/hidraw"
+	"dualsense/internal/service/discovery"
+	"dualsense/internal/sysfs"
+)
+
+// Sysfs drives the LEDs through the LED class devices exposed by hid-playstation.
+type Sysfs struct{}
+
+// Hidraw drives the LEDs by writing output reports to the controller hidraw node.
+// It is used when the driver does not expose the LEDs through sysfs.
+type Hidraw struct{}
+
+// DualShock4 drives the lightbar of a DualShock 4 through the red, green and blue
+// LED class devices exposed by hid-sony. The DualShock 4 has no player LEDs.
+type DualShock4 struct{}
+
+var (
+	_ Leds = Sysfs{}
+	_ Leds = Hidraw{}
+	_ Leds = DualShock4{}
+)
+
+// ForController returns the Leds implementation for the controller behind jsPath:
+// DualShock4 for a DualShock 4, sysfs when the lightbar LED class device exists,
+// hidraw otherwise.
+func ForController(jsPath string) Leds {
+	if discovery.DetectModel(jsPath).IsDualShock4() {
+		return DualShock4{}
+	}
+	if hasSysfsLightbar(jsPath) {
+		return Sysfs{}
+	}
+	if _, err := hidraw.NodePath(jsPath); err == nil {
+		if Debug {
+			fmt.Printf("Sysfs LEDs missing for %s, using hidraw output reports\n", jsPath)
+		}
+		return Hidraw{}
+	}
+	return Sysfs{}
+}
+
+func hasSysfsLightbar(jsPath string) bool {
+	basePath := getLedPath(jsPath)
+	if basePath == "" {
+		return false
+	}
+	matches, err := sysfs.FS.Glob(fmt.Sprintf("%s/*:rgb:indicator", basePath))
+	return err == nil && len(matches) > 0
+}
+
+// RunChargingAnimation animates player LEDs to indicate charging progress.
+func (Sysfs) RunChargingAnimation(ctx context.Context, jsPath string) {
+	RunChargingAnimation(ctx, jsPath)
+}
+
+// RunRGBChargingAnimation animates the RGB lightbar while charging.
+func (Sysfs) RunRGBChargingAnimation(ctx context.Context, hidPath string, batteryLevel chan float64) {
+	RunRGBChargingAnimation(ctx, hidPath, batteryLevel)
+}
+
+// RunAnimation plays anim on the lightbar and player LEDs it drives.
+func (Sysfs) RunAnimation(ctx context.Context, jsPath string, anim Animation) {
+	RunAnimation(ctx, jsPath, anim)
+}
+
+// SetBatteryColor sets the RGB lightbar color based on battery percent.
+func (Sysfs) SetBatteryColor(jsPath string, percent float64) { SetBatteryColor(jsPath, percent) }
+
+// SetBatteryLeds updates the player LEDs to represent battery level.
+func (Sysfs) SetBatteryLeds(jsPath string, percent float64) { SetBatteryLeds(jsPath, percent) }
+
+// SetPlayerNumber updates the player LEDs to indicate controller number.
+func (Sysfs) SetPlayerNumber(jsPath string, id int) { SetPlayerNumber(jsPath, id) }
+
+// SetLightbarRGB sets the multi_intensity and brightness of the RGB lightbar.
+func (Sysfs) SetLightbarRGB(jsPath string, r, g, b int) { SetLightbarRGB(jsPath, r, g, b) }
+
+// SetMuteLed turns the microphone mute LED on or off.
+func (Sysfs) SetMuteLed(jsPath string, on bool) { SetMuteLed(jsPath, on) }
+
+// RunChargingAnimation animates player LEDs to indicate charging progress.
+func (h Hidraw) RunChargingAnimation(ctx context.Context, jsPath string) {
+	if Debug {
+		fmt.Printf("Starting hidraw charging animation on %s\n", jsPath)
+	}
+	runChargingAnimation(ctx, func(mask byte) {
+		send(jsPath, &hidraw.OutputReport{Flags1: hidraw.Flag1PlayerIndicator, PlayerLeds: mask})
+	})
+}
+
+// RunRGBChargingAnimation animates the RGB lightbar while charging.
+func (h Hidraw) RunRGBChargingAnimation(ctx context.Context, hidPath string, batteryLevel chan float64) {
+	runRGBChargingAnimation(ctx, batteryLevel, func(r, g, b int) {
+		h.SetLightbarRGB(hidPath, r, g, b)
+	})
+}
+
+// RunAnimation plays anim on the lightbar and player LEDs it drives, with one output
+// report per frame.
+func (Hidraw) RunAnimation(ctx context.Context, jsPath string, anim Animation) {
+	runAnimation(ctx, anim, func(f Frame) {
+		report := &hidraw.OutputReport{}
+		if anim.Lightbar {
+			report.Flags1 |= hidraw.Flag1Lightbar
+			report.Red, report.Green, report.Blue = clampByte(f.R), clampByte(f.G), clampByte(f.B)
+		}
+		if anim.Players {
+			report.Flags1 |= hidraw.Flag1PlayerIndicator
+			report.PlayerLeds = f.Players
+		}
+		send(jsPath, report)
+	})
+}
+
+// SetBatteryColor sets the RGB lightbar color based on battery percent.
+func (h Hidraw) SetBatteryColor(jsPath string, percent float64) {
+	r, g := batteryColor(percent)
+	h.SetLightbarRGB(jsPath, r, g, 0)
+}
+
+// SetBatteryLeds updates the player LEDs to represent battery level.
+func (h Hidraw) SetBatteryLeds(jsPath string, percent float64) {
+	p15, p24, p3 := batteryLeds(percent, Clock.Now())
+	h.setPlayerLeds(jsPath, p15, p24, p3)
+}
+
+// SetPlayerNumber updates the player LEDs to indicate controller number.
+func (h Hidraw) SetPlayerNumber(jsPath string, id int) {
+	p15, p24, p3 := playerNumberLeds(id)
+	h.setPlayerLeds(jsPath, p15, p24, p3)
+}
+
+// SetLightbarRGB sets the lightbar color through an output report.
+func (Hidraw) SetLightbarRGB(jsPath string, r, g, b int) {
+	if Debug {
+		fmt.Printf("Setting hidraw lightbar RGB to (%d, %d, %d)\n", r, g, b)
+	}
+	send(jsPath, &hidraw.OutputReport{
+		Flags1: hidraw.Flag1Lightbar,
+		Red:    clampByte(r),
+		Green:  clampByte(g),
+		Blue:   clampByte(b),
+	})
+}
+
+// SetMuteLed turns the microphone mute LED on or off through an output report.
+func (Hidraw) SetMuteLed(jsPath string, on bool) {
+	report := &hidraw.OutputReport{Flags1: hidraw.Flag1MicMuteLed}
+	if on {
+		report.MuteLed = 1
+	}
+	send(jsPath, report)
+}
+
+func (Hidraw) setPlayerLeds(jsPath, p15, p24, p3 string) {
+	send(jsPath, &hidraw.OutputReport{
+		Flags1:     hidraw.Flag1PlayerIndicator,
+		PlayerLeds: playerMask(p15, p24, p3),
+	})
+}
+
+// RunChargingAnimation does nothing, the DualShock 4 has no player LEDs.
+func (DualShock4) RunChargingAnimation(_ context.Context, _ string) {}
+
+// RunRGBChargingAnimation animates the lightbar while charging.
+func (d DualShock4) RunRGBChargingAnimation(ctx context.Context, hidPath string, batteryLevel chan float64) {
+	runRGBChargingAnimation(ctx, batteryLevel, func(r, g, b int) {
+		d.SetLightbarRGB(hidPath, r, g, b)
+	})
+}
+
+// RunAnimation plays the lightbar of anim, the DualShock 4 has no player LEDs.
+func (d DualShock4) RunAnimation(ctx context.Context, jsPath string, anim Animation) {
+	if !anim.Lightbar {
+		return
+	}
+	runAnimation(ctx, anim, func(f Frame) {
+		d.SetLightbarRGB(jsPath, f.R, f.G, f.B)
+	})
+}
+
+// SetBatteryColor sets the lightbar color based on battery percent.
+func (d DualShock4) SetBatteryColor(jsPath string, percent float64) {
+	r, g := batteryColor(percent)
+	d.SetLightbarRGB(jsPath, r, g, 0)
+}
+
+// SetBatteryLeds does nothing, the DualShock 4 has no player LEDs.
+func (DualShock4) SetBatteryLeds(_ string, _ float64) {}
+
+// SetPlayerNumber does nothing, the DualShock 4 has no player LEDs.
+func (DualShock4) SetPlayerNumber(_ string, _ int) {}
+
+// SetLightbarRGB sets the brightness of the red, green and blue lightbar LEDs.
+func (DualShock4) SetLightbarRGB(jsPath string, r, g, b int) {
+	if Debug {
+		fmt.Printf("Setting DualShock 4 lightbar RGB to (%d, %d, %d)\n", r, g, b)
+	}
+	basePath := getLedPath(jsPath)
+	applyLed(basePath, "red", strconv.Itoa(int(clampByte(r))))
+	applyLed(basePath, "green", strconv.Itoa(int(clampByte(g))))
+	applyLed(basePath, "blue", strconv.Itoa(int(clampByte(b))))
+}
+
+// SetMuteLed does nothing, the DualShock 4 has no mute button.
+func (DualShock4) SetMuteLed(_ string, _ bool) {}
+
+func send(jsPath string, report *hidraw.OutputReport) {
+	if err := hidraw.Send(jsPath, report); err != nil && Debug {
+		log.Default().Println("Error writing hidraw output report:", err)
+	}
+}
+
+func clampByte(v int) byte {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return byte(v)
+}
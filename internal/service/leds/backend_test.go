@@ -0,0 +1,185 @@
+package leds
+
+import (
+	"fmt"
+	"testing"
+
+	"dualsense/internal/hidraw"
+	"dualsense/internal/hidraw/hidrawtest"
+	"dualsense/internal/sysfs"
+)
+
+func TestForController(t *testing.T) {
+	old := sysfs.FS
+	defer func() { sysfs.FS = old }()
+
+	// the LED class devices are preferred over the hidraw node
+	sysfs.FS = &fakeFS{
+		files: map[string][]byte{},
+		globs: map[string][]string{
+			"/sys/class/input/js0/device/leds/*:rgb:indicator":  {"/sys/class/input/js0/device/leds/input12:rgb:indicator"},
+			"/sys/class/input/js0/device/device/hidraw/hidraw*": {"/sys/class/input/js0/device/device/hidraw/hidraw1"},
+		},
+	}
+	if _, ok := ForController("/dev/input/js0").(Sysfs); !ok {
+		t.Fatalf("expected sysfs backend when LED class devices exist")
+	}
+
+	hidrawtest.Use(t, "0005")
+	if _, ok := ForController("/dev/input/js0").(Hidraw); !ok {
+		t.Fatalf("expected hidraw backend when LED class devices are missing")
+	}
+}
+
+func TestForControllerWithoutHidraw(t *testing.T) {
+	old := sysfs.FS
+	defer func() { sysfs.FS = old }()
+
+	// neither LED class devices nor a hidraw node: the sysfs backend is the last resort
+	sysfs.FS = hidrawtest.FS{Bustype: "0005"}
+	if _, ok := ForController("/dev/input/js0").(Sysfs); !ok {
+		t.Fatalf("expected sysfs backend without a hidraw node")
+	}
+}
+
+func TestHidrawSetLightbarRGB(t *testing.T) {
+	for _, bustype := range []string{"0003", "0005"} {
+		dev := hidrawtest.Use(t, bustype)
+
+		Hidraw{}.SetLightbarRGB("/dev/input/js0", 100, 150, 300)
+
+		reports := dev.Reports(t)
+		if len(reports) != 1 {
+			t.Fatalf("expected 1 report, got %d", len(reports))
+		}
+		r := reports[0]
+		if r.Flags1 != hidraw.Flag1Lightbar || r.Flags0 != 0 {
+			t.Fatalf("unexpected valid flags %02x %02x", r.Flags0, r.Flags1)
+		}
+		if r.Red != 100 || r.Green != 150 || r.Blue != 255 {
+			t.Fatalf("unexpected color (%d, %d, %d)", r.Red, r.Green, r.Blue)
+		}
+	}
+}
+
+func TestHidrawSetPlayerNumber(t *testing.T) {
+	tests := []struct {
+		id   int
+		want byte
+	}{
+		{1, 0x04},
+		{2, 0x0A},
+		{3, 0x0E},
+		{4, 0x1B},
+		{5, 0x1F},
+	}
+
+	for _, tt := range tests {
+		dev := hidrawtest.Use(t, "0003")
+
+		Hidraw{}.SetPlayerNumber("/dev/input/js0", tt.id)
+
+		reports := dev.Reports(t)
+		if len(reports) != 1 {
+			t.Fatalf("expected 1 report, got %d", len(reports))
+		}
+		if reports[0].Flags1 != hidraw.Flag1PlayerIndicator {
+			t.Fatalf("unexpected valid flags %02x", reports[0].Flags1)
+		}
+		if reports[0].PlayerLeds != tt.want {
+			t.Fatalf("player %d: want mask %05b got %05b", tt.id, tt.want, reports[0].PlayerLeds)
+		}
+	}
+}
+
+func TestHidrawSetBatteryColor(t *testing.T) {
+	dev := hidrawtest.Use(t, "0005")
+
+	Hidraw{}.SetBatteryColor("/dev/input/js0", 25)
+
+	reports := dev.Reports(t)
+	if len(reports) != 1 {
+		t.Fatalf("expected 1 report, got %d", len(reports))
+	}
+	if r := reports[0]; r.Red != 255 || r.Green != 127 || r.Blue != 0 {
+		t.Fatalf("unexpected color (%d, %d, %d)", r.Red, r.Green, r.Blue)
+	}
+}
+
+func TestHidrawSetMuteLed(t *testing.T) {
+	for _, on := range []bool{true, false} {
+		dev := hidrawtest.Use(t, "0005")
+
+		Hidraw{}.SetMuteLed("/dev/input/js0", on)
+
+		reports := dev.Reports(t)
+		if len(reports) != 1 {
+			t.Fatalf("expected 1 report, got %d", len(reports))
+		}
+		if reports[0].Flags1 != hidraw.Flag1MicMuteLed {
+			t.Fatalf("unexpected valid flags %02x", reports[0].Flags1)
+		}
+		if got := reports[0].MuteLed == 1; got != on {
+			t.Fatalf("mute LED on = %v, want %v", got, on)
+		}
+	}
+}
+
+// dualShock4FS returns a fake sysfs tree of a DualShock 4 v2 at js0.
+func dualShock4FS() *fakeFS {
+	return &fakeFS{
+		files: map[string][]byte{
+			"/sys/class/input/js0/device/id/vendor":  []byte("054c\n"),
+			"/sys/class/input/js0/device/id/product": []byte("09cc\n"),
+		},
+		globs: map[string][]string{},
+	}
+}
+
+func TestForControllerDualShock4(t *testing.T) {
+	old := sysfs.FS
+	defer func() { sysfs.FS = old }()
+
+	sysfs.FS = dualShock4FS()
+	if _, ok := ForController("/dev/input/js0").(DualShock4); !ok {
+		t.Fatalf("expected DualShock 4 backend for a DualShock 4")
+	}
+}
+
+func TestDualShock4SetLightbarRGB(t *testing.T) {
+	old := sysfs.FS
+	defer func() { sysfs.FS = old }()
+	fake := dualShock4FS()
+	sysfs.FS = fake
+
+	DualShock4{}.SetLightbarRGB("/dev/input/js0", 100, 150, 300)
+
+	base := "/sys/class/input/js0/device/leds/mock:"
+	want := map[string]string{
+		base + "red/brightness":   "100",
+		base + "green/brightness": "150",
+		base + "blue/brightness":  "255",
+	}
+	got := map[string]string{}
+	for _, w := range fake.writes {
+		got[w.path] = string(w.data)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("unexpected writes %v, want %v", got, want)
+	}
+}
+
+func TestDualShock4HasNoPlayerLeds(t *testing.T) {
+	old := sysfs.FS
+	defer func() { sysfs.FS = old }()
+	fake := dualShock4FS()
+	sysfs.FS = fake
+
+	DualShock4{}.SetPlayerNumber("/dev/input/js0", 2)
+	DualShock4{}.SetBatteryLeds("/dev/input/js0", 60)
+	DualShock4{}.SetMuteLed("/dev/input/js0", true)
+
+	if len(fake.writes) != 0 {
+		t.Fatalf("expected no writes, got %v", fake.writes)
+	}
+}
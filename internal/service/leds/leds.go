@@ -4,26 +4,31 @@ package leds
 import (
 	"context"
 	"fmt"
-	"math"
 	"path/filepath"
 	"time"
 
+	"dualsense/internal/clock"
 	"dualsense/internal/sysfs"
 )
 
 var (
 	// Debug enables debug logging within the leds package.
 	Debug bool
+
+	// Clock drives the LED animations and delayed updates. Tests replace it with a fake clock.
+	Clock clock.Clock = clock.Real{}
 )
 
 // Leds interface defines methods to control DualSense LEDs.
 type Leds interface {
 	RunChargingAnimation(ctx context.Context, jsPath string)
 	RunRGBChargingAnimation(ctx context.Context, hidPath string, batteryLevel chan float64)
+	RunAnimation(ctx context.Context, jsPath string, anim Animation)
 	SetBatteryColor(jsPath string, percent float64)
 	SetBatteryLeds(jsPath string, percent float64)
 	SetPlayerNumber(jsPath string, id int)
 	SetLightbarRGB(jsPath string, r, g, b int)
+	SetMuteLed(jsPath string, on bool)
 }
 
 // RunChargingAnimation animates player LEDs to indicate charging progress.
@@ -31,76 +36,63 @@ func RunChargingAnimation(ctx context.Context, jsPath string) {
 	if Debug {
 		fmt.Printf("Starting charging animation on %s\n", jsPath)
 	}
-	ticker := time.NewTicker(800 * time.Millisecond) // Vitesse de l'animation
-	defer ticker.Stop()
-
-	step := 0
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			var p15, p24, p3 string
-
-			switch step % 3 {
-			case 0: // --X--
-				p15, p24, p3 = "0", "0", "1"
-			case 1: // -XXX-
-				p15, p24, p3 = "0", "1", "1"
-			case 2: // XXXXX
-				p15, p24, p3 = "1", "1", "1"
-			}
-
-			ledBase := getLedPath(jsPath)
-			applyLed(ledBase, "player-1", p15)
-			applyLed(ledBase, "player-5", p15)
-			applyLed(ledBase, "player-2", p24)
-			applyLed(ledBase, "player-4", p24)
-			applyLed(ledBase, "player-3", p3)
-
-			step++
-		}
-	}
-
+	runChargingAnimation(ctx, func(mask byte) {
+		applyPlayerMask(jsPath, mask)
+	})
 }
 
 // RunRGBChargingAnimation animates the RGB lightbar while charging.
 func RunRGBChargingAnimation(ctx context.Context, hidPath string, batteryLevel chan float64) {
-	ticker := time.NewTicker(25 * time.Millisecond) // Animation fluide
-	defer ticker.Stop()
+	runRGBChargingAnimation(ctx, batteryLevel, func(r, g, b int) {
+		SetLightbarRGB(hidPath, r, g, b)
+	})
+}
 
-	percent := <-batteryLevel
-	// On calcule la couleur cible une fois au début
-	baseR, baseG := 255, 255
-	if percent > 50 {
-		baseR = int(255 * (100 - percent) / 50)
-	} else {
-		baseG = int(255 * percent / 50)
+// RunAnimation plays anim on the lightbar and player LEDs it drives until ctx is cancelled.
+func RunAnimation(ctx context.Context, jsPath string, anim Animation) {
+	if Debug {
+		fmt.Printf("Starting animation on %s\n", jsPath)
+	}
+	runAnimation(ctx, anim, func(f Frame) {
+		if anim.Lightbar {
+			SetLightbarRGB(jsPath, f.R, f.G, f.B)
+		}
+		if anim.Players {
+			applyPlayerMask(jsPath, f.Players)
+		}
+	})
+}
+
+func runChargingAnimation(ctx context.Context, setPlayers func(mask byte)) {
+	runAnimation(ctx, chargingPlayers, func(f Frame) {
+		setPlayers(f.Players)
+	})
+}
+
+// runRGBChargingAnimation pulses the battery color, which follows the levels
+// received from batteryLevel without restarting the pulse.
+func runRGBChargingAnimation(ctx context.Context, batteryLevel chan float64, setRGB func(r, g, b int)) {
+	var percent float64
+	select {
+	case <-ctx.Done():
+		return
+	case percent = <-batteryLevel:
 	}
+	p := &player{anim: chargingPulse(percent), start: Clock.Now(), show: func(f Frame) {
+		setRGB(f.R, f.G, f.B)
+	}}
+	p.draw(p.start)
 
-	theta := 0.0
+	ticker := Clock.NewTicker(frameInterval())
+	defer ticker.Stop()
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case percent := <-batteryLevel:
-			baseR, baseG = 255, 255
-			if percent > 50 {
-				baseR = int(255 * (100 - percent) / 50)
-			} else {
-				baseG = int(255 * percent / 50)
-			}
-		case <-ticker.C:
-			brightness := 0.6 + 0.4*math.Sin(theta)
-
-			r := int(float64(baseR) * brightness)
-			g := int(float64(baseG) * brightness)
-			SetLightbarRGB(hidPath, r, g, 0)
-
-			theta += 0.1
-			if theta > 2*math.Pi {
-				theta = 0
-			}
+			p.anim = chargingPulse(percent)
+		case now := <-ticker.C():
+			p.draw(now)
 		}
 	}
 }
@@ -110,20 +102,14 @@ func SetBatteryColor(jsPath string, percent float64) {
 	if Debug {
 		fmt.Printf("Setting battery color for %.2f%% battery\n", percent)
 	}
-	var r, g int
-	if percent > 50 {
-		r = int(255 * (100 - percent) / 50)
-		g = 255
-	} else {
-		r = 255
-		g = int(255 * percent / 50)
-	}
+	r, g := batteryColor(percent)
 
 	SetLightbarRGB(jsPath, r, g, 0)
 
 	// At connection lightbar is not ready immediately; reapply after a short delay.
+	delay := Clock.After(3 * time.Second)
 	go func() {
-		time.Sleep(3 * time.Second)
+		<-delay
 		SetLightbarRGB(jsPath, r, g, 0)
 	}()
 
@@ -134,11 +120,32 @@ func SetBatteryLeds(jsPath string, percent float64) {
 	if Debug {
 		fmt.Printf("Setting battery LEDs for %.2f%% battery\n", percent)
 	}
-	ledBase := getLedPath(jsPath)
+	p15, p24, p3 := batteryLeds(percent, Clock.Now())
+	applyPlayerLeds(jsPath, p15, p24, p3)
+}
 
-	var p15 string
-	var p24 string
-	var p3 string
+// SetPlayerNumber updates the player LEDs to indicate controller number.
+func SetPlayerNumber(jsPath string, id int) {
+	if Debug {
+		fmt.Printf("Setting player LED number to %d\n", id)
+	}
+	p15, p24, p3 := playerNumberLeds(id)
+	applyPlayerLeds(jsPath, p15, p24, p3)
+}
+
+// batteryColor returns the red and green components showing the battery percent,
+// from green when full to red when empty.
+func batteryColor(percent float64) (int, int) {
+	if percent > 50 {
+		return int(255 * (100 - percent) / 50), 255
+	}
+	return 255, int(255 * percent / 50)
+}
+
+// batteryLeds returns the player LED pattern for the battery percent. Low levels
+// blink, so the pattern depends on the current time.
+func batteryLeds(percent float64, now time.Time) (string, string, string) {
+	var p15, p24, p3 string
 	blink := false
 
 	if percent >= 75 {
@@ -155,44 +162,17 @@ func SetBatteryLeds(jsPath string, percent float64) {
 		blink = true
 	}
 
-	applyLed(ledBase, "player-1", p15)
-	applyLed(ledBase, "player-5", p15)
-	if blink {
-		if time.Now().Unix()%2 == 0 {
-			applyLed(ledBase, "player-2", p24)
-			applyLed(ledBase, "player-4", p24)
-
-		} else {
-			applyLed(ledBase, "player-2", "0")
-			applyLed(ledBase, "player-4", "0")
-		}
-	} else {
-		applyLed(ledBase, "player-2", p24)
-		applyLed(ledBase, "player-4", p24)
-	}
-	if blink {
-		if time.Now().Unix()%2 == 0 {
-			applyLed(ledBase, "player-3", p3)
-
-		} else {
-			applyLed(ledBase, "player-3", "0")
-		}
-	} else {
-		applyLed(ledBase, "player-3", p3)
+	if blink && now.Unix()%2 != 0 {
+		p24, p3 = "0", "0"
 	}
+	return p15, p24, p3
 }
 
-// SetPlayerNumber updates the player LEDs to indicate controller number.
-func SetPlayerNumber(jsPath string, id int) {
-	if Debug {
-		fmt.Printf("Setting player LED number to %d\n", id)
-	}
-	ledBase := getLedPath(jsPath)
-
-	playerNum := id
+// playerNumberLeds returns the player LED pattern for the controller number.
+func playerNumberLeds(id int) (string, string, string) {
 	p15, p24, p3 := "0", "0", "0"
 
-	switch playerNum {
+	switch id {
 	case 1:
 		p3 = "1"
 	case 2:
@@ -204,7 +184,51 @@ func SetPlayerNumber(jsPath string, id int) {
 	default:
 		p15, p24, p3 = "1", "1", "1"
 	}
+	return p15, p24, p3
+}
 
+// playerMask converts a player LED pattern to the bitmask used by output reports,
+// where bit 0 is the leftmost LED.
+func playerMask(p15, p24, p3 string) byte {
+	var mask byte
+	if p15 == "1" {
+		mask |= 1<<0 | 1<<4
+	}
+	if p24 == "1" {
+		mask |= 1<<1 | 1<<3
+	}
+	if p3 == "1" {
+		mask |= 1 << 2
+	}
+	return mask
+}
+
+// SetMuteLed turns the microphone mute LED on or off.
+func SetMuteLed(jsPath string, on bool) {
+	if Debug {
+		fmt.Printf("Setting mute LED to %v\n", on)
+	}
+	value := "0"
+	if on {
+		value = "1"
+	}
+	applyLed(getLedPath(jsPath), "micmute", value)
+}
+
+// applyPlayerMask turns each player LED on or off from its bit in mask.
+func applyPlayerMask(jsPath string, mask byte) {
+	ledBase := getLedPath(jsPath)
+	for i := range 5 {
+		value := "0"
+		if mask&(1<<i) != 0 {
+			value = "1"
+		}
+		applyLed(ledBase, fmt.Sprintf("player-%d", i+1), value)
+	}
+}
+
+func applyPlayerLeds(jsPath, p15, p24, p3 string) {
+	ledBase := getLedPath(jsPath)
 	applyLed(ledBase, "player-1", p15)
 	applyLed(ledBase, "player-5", p15)
 	applyLed(ledBase, "player-2", p24)
@@ -225,9 +249,10 @@ func applyLed(basePath, ledName, value string) {
 		return
 	}
 
-	path := matches[0]
-
-	_ = sysfs.FS.WriteFile(fmt.Sprintf("%s/brightness", path), []byte(value), 0644)
+	path := fmt.Sprintf("%s/brightness", matches[0])
+	if err := sysfs.FS.WriteFile(path, []byte(value), 0644); err != nil && Debug {
+		fmt.Printf("Error writing LED %s: %v\n", path, err)
+	}
 }
 
 func getLedPath(jsPath string) string {
@@ -1,11 +1,15 @@
 package leds
 
 import (
+	"context"
+	"dualsense/internal/clock"
 	"dualsense/internal/sysfs"
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 type writeRec struct {
@@ -199,7 +203,30 @@ func TestSetLightbarRGB(t *testing.T) {
 	}
 }
 
+func TestSetMuteLed(t *testing.T) {
+	old := sysfs.FS
+	fake := &fakeFS{files: map[string][]byte{}, globs: map[string][]string{}}
+	sysfs.FS = fake
+	defer func() { sysfs.FS = old }()
+	base := "/sys/class/input/js0/device/leds"
+	fake.globs[base+"/*:micmute"] = []string{base + "/input12:white:micmute"}
+
+	SetMuteLed("/dev/input/js0", true)
+	SetMuteLed("/dev/input/js0", false)
+
+	if len(fake.writes) != 2 {
+		t.Fatalf("expected 2 writes, got %d", len(fake.writes))
+	}
+	wantPath := base + "/input12:white:micmute/brightness"
+	for i, want := range []string{"1", "0"} {
+		if fake.writes[i].path != wantPath || string(fake.writes[i].data) != want {
+			t.Fatalf("write %d: got %q to %s, want %q to %s", i, fake.writes[i].data, fake.writes[i].path, want, wantPath)
+		}
+	}
+}
+
 func TestSetBatteryColor(t *testing.T) {
+	useFakeClock(t) // the delayed reapply is covered by TestSetBatteryColorReapplies
 	old := sysfs.FS
 	fake := &fakeFS{
 		files:  map[string][]byte{},
@@ -361,3 +388,112 @@ func TestSetBatteryLeds(t *testing.T) {
 	}
 
 }
+
+func useFakeClock(t *testing.T) *clock.Fake {
+	t.Helper()
+	old := Clock
+	fake := clock.NewFake(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	Clock = fake
+	t.Cleanup(func() { Clock = old })
+	return fake
+}
+
+func TestRunChargingAnimation(t *testing.T) {
+	fake := useFakeClock(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var frames []string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runChargingAnimation(ctx, func(mask byte) {
+			frames = append(frames, fmt.Sprintf("%05b", mask))
+		})
+	}()
+
+	// the first frame is shown at once, then each 800ms step
+	fake.BlockUntil(1)
+	for i := 0; i < 3; i++ {
+		fake.Advance(800 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	want := []string{"00100", "01110", "11111", "00100"}
+	if strings.Join(frames, " ") != strings.Join(want, " ") {
+		t.Fatalf("frames = %v, want %v", frames, want)
+	}
+}
+
+func TestRunRGBChargingAnimation(t *testing.T) {
+	fake := useFakeClock(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	levels := make(chan float64)
+
+	colors := make(chan [3]int, 10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runRGBChargingAnimation(ctx, levels, func(r, g, b int) {
+			colors <- [3]int{r, g, b}
+		})
+	}()
+
+	levels <- 100
+	// first frame at 60% brightness of full-battery green
+	if got := <-colors; got != [3]int{0, 153, 0} {
+		t.Fatalf("first frame = %v", got)
+	}
+
+	levels <- 0
+	fake.BlockUntil(1)
+	fake.Advance(400 * time.Millisecond)
+	got := <-colors
+	if got[0] <= 153 || got[1] != 0 || got[2] != 0 {
+		t.Fatalf("expected a brighter red frame after the battery level dropped, got %v", got)
+	}
+
+	cancel()
+	<-done
+}
+
+func TestSetBatteryColorReapplies(t *testing.T) {
+	fake := useFakeClock(t)
+	old := sysfs.FS
+	fs := &lockedFS{fakeFS: fakeFS{files: map[string][]byte{}, globs: map[string][]string{}}}
+	sysfs.FS = fs
+	defer func() { sysfs.FS = old }()
+
+	SetBatteryColor("/dev/input/js0", 100)
+	if n := fs.writeCount(); n != 2 {
+		t.Fatalf("expected 2 writes, got %d", n)
+	}
+
+	fake.BlockUntil(1)
+	fake.Advance(3 * time.Second)
+	deadline := time.Now().Add(time.Second)
+	for fs.writeCount() != 4 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected lightbar to be applied again after 3s, got %d writes", fs.writeCount())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// lockedFS is a fakeFS safe for writes from the delayed LED updates.
+type lockedFS struct {
+	mu sync.Mutex
+	fakeFS
+}
+
+func (f *lockedFS) WriteFile(path string, data []byte, perm os.FileMode) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.fakeFS.WriteFile(path, data, perm)
+}
+
+func (f *lockedFS) writeCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.writes)
+}
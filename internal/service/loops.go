@@ -3,17 +3,23 @@ package service
 
 import (
 	"context"
+	"dualsense/internal/clock"
 	"dualsense/internal/config"
+	"dualsense/internal/hidraw"
 	"dualsense/internal/service/battery"
 	"dualsense/internal/service/bluetooth"
 	"dualsense/internal/service/discovery"
+	"dualsense/internal/service/drift"
 	"dualsense/internal/service/leds"
+	"dualsense/internal/service/power"
+	"dualsense/internal/service/rumble"
+	"dualsense/internal/service/triggers"
 	"dualsense/internal/ui"
 	"fmt"
 	"log"
-	"os"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -24,6 +30,28 @@ import (
 var (
 	// Debug enables debug logging within the service package.
 	Debug bool
+
+	// Clock is the time source of the service loops. Tests replace it with a fake clock.
+	Clock clock.Clock = clock.Real{}
+
+	// Notify shows a desktop notification, or logs it when no Fyne app is running.
+	// Tests replace it to record alerts.
+	Notify = func(title, content string) {
+		app := fyne.CurrentApp()
+		if app == nil {
+			log.Default().Printf("%s: %s\n", title, content)
+			return
+		}
+		app.SendNotification(&fyne.Notification{Title: title, Content: content})
+	}
+
+	// Discovery finds the connected controllers and reports them as they come and go.
+	// Tests replace it with a fake.
+	Discovery discovery.Discovery = discovery.Sysfs{}
+
+	// LedsForController returns the LED backend of the battery loop and of the mic
+	// mute LED. Tests replace it to record the LED changes.
+	LedsForController = leds.ForController
 )
 
 type ControllerCLI struct {
@@ -31,7 +59,79 @@ type ControllerCLI struct {
 	ActivityChan chan time.Time
 	CancelFunc   context.CancelFunc
 	MacAddress   string
-	Status       string
+	Model        discovery.Model
+	Transport    discovery.Transport
+}
+
+// ControllerLoop is what the battery and activity loops of a connected controller
+// share. The loops run concurrently, so the charging status read by the battery
+// loop is only exchanged through Status and SetStatus.
+type ControllerLoop struct {
+	Path     string
+	MAC      string
+	ID       int
+	Model    discovery.Model
+	Conf     *config.Config
+	CtrlConf *config.ControllerConfig
+	// UI is the tab of the controller, nil without the GUI.
+	UI       *ui.ControllerState
+	Activity chan time.Time
+	// Dimmed is set while the idle policy keeps the lightbar off, and Asleep while
+	// the power backend put the controller to sleep. Either may be nil.
+	Dimmed *atomic.Bool
+	Asleep *atomic.Bool
+
+	status atomic.Value
+}
+
+// Status returns the last charging status read by the battery loop.
+func (c *ControllerLoop) Status() string {
+	status, _ := c.status.Load().(string)
+	return status
+}
+
+// SetStatus records the charging status read by the battery loop.
+func (c *ControllerLoop) SetStatus(status string) {
+	c.status.Store(status)
+}
+
+// PowerOff turns the controller off through the power backend of its transport.
+// When the controller is put to sleep instead, Asleep is set so that the activity
+// loop wakes it up once it is used again.
+func (c *ControllerLoop) PowerOff() error {
+	sleeping, err := power.ForController(c.Path).PowerOff(c.Path, c.MAC)
+	if sleeping && c.Asleep != nil {
+		c.Asleep.Store(true)
+	}
+	return err
+}
+
+// rgbModeDimmed marks the lightbar as turned off by the dim idle policy, so that
+// the RGB preference is applied again once the controller is used.
+const rgbModeDimmed = -2
+
+// rgbModeFlashing marks the lightbar as flashing for a critical battery alert, so
+// that the RGB preference is applied again once the flashing stops.
+const rgbModeFlashing = -3
+
+// rgbModeCharging marks the lightbar as playing the charging animation, so that the
+// RGB preference is applied again once charging stops.
+const rgbModeCharging = -4
+
+// criticalFlashDuration is how long the lightbar flashes red on a critical battery alert.
+const criticalFlashDuration = 10 * time.Second
+
+// alertNotification returns the title and content of the notification of a battery alert.
+func alertNotification(alert battery.Alert, id int) (string, string) {
+	switch alert.Severity {
+	case battery.AlertFull:
+		return "DualSense Fully Charged", fmt.Sprintf("Controller %d is fully charged", id)
+	case config.SeverityWarning:
+		return "DualSense Battery Warning", fmt.Sprintf("Controller %d battery is at %d%%", id, alert.Level)
+	case config.SeverityCritical:
+		return "DualSense Battery Critical", fmt.Sprintf("Controller %d battery is at %d%%, charge it now", id, alert.Level)
+	}
+	return "DualSense Battery Low", fmt.Sprintf("Controller %d battery is at %d%%", id, alert.Level)
 }
 
 type LedState struct {
@@ -44,10 +144,19 @@ type LedState struct {
 	PreviousBatteryLevel  int
 	PlayerNumber          int
 	RGBColor              string
+	RGBAnimation          string
 }
 
 // ManageBatteryAndLEDs handles battery monitoring and LED management for a controller.
-func ManageBatteryAndLEDs(ctx context.Context, state *ui.ControllerState, ctrlConf *config.ControllerConfig, conf *config.Config, path string, id int, storedStatus *string) {
+// While c.Dimmed is set, the lightbar is kept off, and while c.Asleep is set the LEDs
+// are left off as the power backend turned them. Battery readings are recorded in
+// history, which may be nil, and the time remaining estimated from them is shown
+// in the tab and the tray tooltip. The battery wear is tracked in health, which
+// may be nil too.
+func ManageBatteryAndLEDs(ctx context.Context, c *ControllerLoop, history *battery.History, health *battery.Health) {
+	state, ctrlConf, conf := c.UI, c.CtrlConf, c.Conf
+	path, mac, id := c.Path, c.MAC, c.ID
+	dimmed, asleep := c.Dimmed, c.Asleep
 	var firstIteration = true
 	batteryChan := make(chan float64)
 
@@ -63,11 +172,30 @@ func ManageBatteryAndLEDs(ctx context.Context, state *ui.ControllerState, ctrlCo
 		RGBColor:              "",
 	}
 
+	ledCtrl := LedsForController(path)
+	model := c.Model
+	transport := discovery.DetectTransport(path)
+	ticker := Clock.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
 	if Debug {
 		log.Default().Println("Starting battery loop for controller at path:", path)
 	}
 
+	// the estimate picks up the charge or discharge recorded before a restart
+	past, err := history.Samples(mac, Clock.Now().Add(-battery.EstimateWindow))
+	if err != nil && Debug {
+		log.Default().Println("Error reading battery history:", err)
+	}
+	estimator := battery.NewEstimator(past)
+	estimateText := ""
+	healthText := ""
+	alerter := battery.NewAlerter()
+	var flashUntil time.Time
+	flashOn := false
+
 	defer func() {
+		setTrayLine(id, "")
 		ledState.CancelPlayerAnim()
 		ledState.CancelRGBAnim()
 		if Debug {
@@ -93,17 +221,72 @@ func ManageBatteryAndLEDs(ctx context.Context, state *ui.ControllerState, ctrlCo
 					if err != nil {
 						log.Default().Println("Error setting battery value:", err)
 					}
-					state.Status = "Dualsense not found"
 				}
-				time.Sleep(5 * time.Second)
+				c.SetStatus("Dualsense not found")
+				setTrayLine(id, "")
+				if !waitTicks(ctx, ticker, 5) {
+					return
+				}
 				continue
 			}
 			status, err := battery.ChargingStatus(path)
 			if err != nil {
 				continue
 			}
+			status = poweredStatus(status, transport)
+			sample := battery.Sample{Time: Clock.Now(), Level: level, Status: status}
+			err = history.Record(mac, sample)
+			if err != nil && Debug {
+				log.Default().Println("Error recording battery history:", err)
+			}
+			estimator.Add(sample)
+			estimate, estimated := estimator.Estimate(sample.Time)
+			text := ""
+			if estimated {
+				text = estimate.String()
+			}
+			if state != nil && text != estimateText {
+				err = state.Estimate.Set(text)
+				if err != nil {
+					log.Default().Println("Error setting battery estimate:", err)
+				}
+			}
+			estimateText = text
+
+			err = health.Observe(mac, sample)
+			if err != nil && Debug {
+				log.Default().Println("Error recording battery health:", err)
+			}
+			if report, ok := health.Report(mac); ok && state != nil && report.Summary() != healthText {
+				healthText = report.Summary()
+				err = state.Health.Set(healthText)
+				if err != nil {
+					log.Default().Println("Error setting battery health:", err)
+				}
+			}
+
+			if alert, ok := alerter.Check(conf, level, status); ok {
+				log.Default().Printf("Battery %s (%d%%) for controller at path: %s\n", alert.Severity, level, path)
+				Notify(alertNotification(alert, id))
+				if alert.Severity == config.SeverityCritical {
+					switch conf.CriticalAction {
+					case config.CriticalActionFlash:
+						flashUntil = Clock.Now().Add(criticalFlashDuration)
+					case config.CriticalActionRumble:
+						if model.IsDualShock4() {
+							break
+						}
+						go func() {
+							if err := rumble.Play(ctx, path, rumble.AlertPattern); err != nil {
+								log.Default().Println("Error playing critical battery rumble:", err)
+							}
+						}()
+					}
+				}
+			}
+			setTrayLine(id, fmt.Sprintf("Controller %d: %s", id, batteryLine(level, status, estimate, estimated)))
 			// Mise à jour de l'UI Fyne
-			if state != nil && *storedStatus != status {
+			if state != nil && c.Status() != status {
 				err = state.BatteryValue.Set(float64(level) / 100.0)
 				if err != nil {
 					log.Default().Println("Error setting battery value:", err)
@@ -112,12 +295,8 @@ func ManageBatteryAndLEDs(ctx context.Context, state *ui.ControllerState, ctrlCo
 				if err != nil {
 					log.Default().Println("Error setting state text:", err)
 				}
-				state.Status = status
-			}
-
-			if storedStatus != nil {
-				*storedStatus = status
 			}
+			c.SetStatus(status)
 			if level != ledState.PreviousBatteryLevel || firstIteration {
 				select {
 				case batteryChan <- float64(level):
@@ -125,25 +304,50 @@ func ManageBatteryAndLEDs(ctx context.Context, state *ui.ControllerState, ctrlCo
 				default:
 					firstIteration = false
 				}
+			}
 
-				if level <= conf.BatteryAlert && conf.BatteryAlert != 0 && status != "Charging" {
-					log.Default().Printf("Battery low (%d%%) for controller at path: %s\n", level, path)
-					fyne.CurrentApp().SendNotification(&fyne.Notification{
-						Title:   "DualSense Battery Low",
-						Content: fmt.Sprintf("Controller %d battery is at %d%%", id, level),
-					})
+			if asleep != nil && asleep.Load() {
+				// the power backend turned the LEDs off, they are applied again on wake
+				ledState.CancelPlayerAnim()
+				ledState.CancelPlayerAnim = func() {}
+				ledState.PlayerAnimationActive = false
+				ledState.CancelRGBAnim()
+				ledState.CancelRGBAnim = func() {}
+				ledState.RGBAnimationActive = false
+				ledState.LedPlayerMode, ledState.LedRGBMode = -1, -1
+				firstIteration = true
+				if !waitTicks(ctx, ticker, 1) {
+					return
 				}
+				ledState.PreviousBatteryLevel = level
+				continue
 			}
 
 			ledPref := ctrlConf.LedPlayerPreference
 			rgbPref := ctrlConf.LedRGBPreference
 
-			if (ledPref == ui.PlayerModeBattery) && status == "Charging" {
+			var animation leds.Animation
+			var animationErr error
+			if rgbPref == ui.RGBModeAnimated {
+				animation, animationErr = ledAnimation(conf, ctrlConf)
+			}
+			flashing := Clock.Now().Before(flashUntil)
+			isDimmed := dimmed != nil && dimmed.Load()
+
+			if rgbPref == ui.RGBModeAnimated && animationErr == nil && animation.Players && !flashing && !isDimmed {
+				// the animation takes the player LEDs over
+				if ledState.PlayerAnimationActive {
+					ledState.CancelPlayerAnim()
+					ledState.CancelPlayerAnim = func() {}
+					ledState.PlayerAnimationActive = false
+				}
+				ledState.LedPlayerMode = -1
+			} else if (ledPref == ui.PlayerModeBattery) && status == "Charging" {
 				if !ledState.PlayerAnimationActive {
 					var animCtxPlayer context.Context
 					animCtxPlayer, ledState.CancelPlayerAnim = context.WithCancel(ctx)
 					ledState.PlayerAnimationActive = true
-					go leds.RunChargingAnimation(animCtxPlayer, path)
+					go ledCtrl.RunChargingAnimation(animCtxPlayer, path)
 				}
 
 			} else {
@@ -155,22 +359,24 @@ func ManageBatteryAndLEDs(ctx context.Context, state *ui.ControllerState, ctrlCo
 				if ledPref == ui.PlayerModeBattery {
 
 					if ledState.LedPlayerMode != ui.PlayerModeBattery || ledState.PreviousBatteryLevel != level {
-						leds.SetBatteryLeds(path, float64(level))
+						ledCtrl.SetBatteryLeds(path, float64(level))
 						// Leds is not ready immediately; reapply after a short delay.
+						delay := Clock.After(3 * time.Second)
 						go func() {
-							time.Sleep(3 * time.Second)
-							leds.SetBatteryLeds(path, float64(level))
+							<-delay
+							ledCtrl.SetBatteryLeds(path, float64(level))
 						}()
 						ledState.LedPlayerMode = ui.PlayerModeBattery
 					}
 
 				} else {
 					if ledState.LedPlayerMode != ui.PlayerModeNumber || ledState.PlayerNumber != id {
-						leds.SetPlayerNumber(path, id)
+						ledCtrl.SetPlayerNumber(path, id)
 						// Leds is not ready immediately; reapply after a short delay.
+						delay := Clock.After(3 * time.Second)
 						go func() {
-							time.Sleep(3 * time.Second)
-							leds.SetPlayerNumber(path, id)
+							<-delay
+							ledCtrl.SetPlayerNumber(path, id)
 						}()
 						ledState.LedPlayerMode = ui.PlayerModeNumber
 						ledState.PlayerNumber = id
@@ -178,14 +384,59 @@ func ManageBatteryAndLEDs(ctx context.Context, state *ui.ControllerState, ctrlCo
 				}
 
 			}
-			if status == "Charging" && (rgbPref == ui.RGBModeBattery) {
-				if !ledState.RGBAnimationActive {
+			if flashing {
+				if ledState.RGBAnimationActive {
+					ledState.CancelRGBAnim()
+					ledState.CancelRGBAnim = func() {}
+					ledState.RGBAnimationActive = false
+					firstIteration = true
+				}
+				flashOn = !flashOn
+				if flashOn {
+					ledCtrl.SetLightbarRGB(path, 255, 0, 0)
+				} else {
+					ledCtrl.SetLightbarRGB(path, 0, 0, 0)
+				}
+				ledState.LedRGBMode = rgbModeFlashing
+			} else if isDimmed {
+				if ledState.RGBAnimationActive {
+					ledState.CancelRGBAnim()
+					ledState.CancelRGBAnim = func() {}
+					ledState.RGBAnimationActive = false
+					firstIteration = true
+				}
+				if ledState.LedRGBMode != rgbModeDimmed {
+					ledCtrl.SetLightbarRGB(path, 0, 0, 0)
+					ledState.LedRGBMode = rgbModeDimmed
+				}
+			} else if status == "Charging" && (rgbPref == ui.RGBModeBattery) {
+				if !ledState.RGBAnimationActive || ledState.LedRGBMode != rgbModeCharging {
+					ledState.CancelRGBAnim()
 
 					var animCtxRGB context.Context
 					animCtxRGB, ledState.CancelRGBAnim = context.WithCancel(ctx)
 					ledState.RGBAnimationActive = true
+					ledState.LedRGBMode = rgbModeCharging
 					firstIteration = true
-					go leds.RunRGBChargingAnimation(animCtxRGB, path, batteryChan)
+					go ledCtrl.RunRGBChargingAnimation(animCtxRGB, path, batteryChan)
+				}
+			} else if rgbPref == ui.RGBModeAnimated {
+				if ledState.LedRGBMode != ui.RGBModeAnimated || ledState.RGBAnimation != ctrlConf.LedRGBAnimation || ledState.RGBColor != ctrlConf.LedRGBStatic {
+					ledState.CancelRGBAnim()
+					ledState.CancelRGBAnim = func() {}
+					ledState.RGBAnimationActive = false
+					if animationErr != nil {
+						log.Default().Println("Error loading LED animation:", animationErr)
+						ledCtrl.SetLightbarRGB(path, 0, 0, 0)
+					} else {
+						var animCtxRGB context.Context
+						animCtxRGB, ledState.CancelRGBAnim = context.WithCancel(ctx)
+						ledState.RGBAnimationActive = true
+						go ledCtrl.RunAnimation(animCtxRGB, path, animation)
+					}
+					ledState.LedRGBMode = ui.RGBModeAnimated
+					ledState.RGBAnimation = ctrlConf.LedRGBAnimation
+					ledState.RGBColor = ctrlConf.LedRGBStatic
 				}
 			} else {
 				if ledState.RGBAnimationActive {
@@ -198,18 +449,19 @@ func ManageBatteryAndLEDs(ctx context.Context, state *ui.ControllerState, ctrlCo
 				switch rgbPref {
 				case ui.RGBModeBattery:
 					if ledState.LedRGBMode != ui.RGBModeBattery || ledState.PreviousBatteryLevel != level {
-						leds.SetBatteryColor(path, float64(level))
+						ledCtrl.SetBatteryColor(path, float64(level))
 						ledState.LedRGBMode = ui.RGBModeBattery
 					}
 				case ui.RGBModeStatic:
 					if ledState.LedRGBMode != ui.RGBModeStatic || ledState.RGBColor != ctrlConf.LedRGBStatic {
 
 						r, g, b := hexToRGB(ctrlConf.LedRGBStatic)
-						leds.SetLightbarRGB(path, r, g, b)
+						ledCtrl.SetLightbarRGB(path, r, g, b)
 						// At connection lightbar is not ready immediately; reapply after a short delay.
+						delay := Clock.After(3 * time.Second)
 						go func() {
-							time.Sleep(3 * time.Second)
-							leds.SetLightbarRGB(path, r, g, b)
+							<-delay
+							ledCtrl.SetLightbarRGB(path, r, g, b)
 						}()
 
 						ledState.LedRGBMode = ui.RGBModeStatic
@@ -218,28 +470,60 @@ func ManageBatteryAndLEDs(ctx context.Context, state *ui.ControllerState, ctrlCo
 
 				case ui.RGBModeOff:
 					if ledState.LedRGBMode != ui.RGBModeOff {
-						leds.SetLightbarRGB(path, 0, 0, 0)
+						ledCtrl.SetLightbarRGB(path, 0, 0, 0)
 						ledState.LedRGBMode = ui.RGBModeOff
 					}
 				}
 			}
 
-			time.Sleep(1 * time.Second)
+			if !waitTicks(ctx, ticker, 1) {
+				return
+			}
 
 			ledState.PreviousBatteryLevel = level
 		}
 	}
 }
 
-// StartActivityLoop monitors inactivity and triggers auto-disconnect when idle.
-func StartActivityLoop(ctx context.Context, state *ui.ControllerState, activityChan chan time.Time, conf *config.Config, mac string, path string, storedStatus *string) {
+// StartActivityLoop monitors inactivity on c.Activity and applies the controller idle
+// policy once its idle timeout elapses: auto-disconnect, or dimming the lightbar through
+// c.Dimmed. The controller is disconnected through the power backend of its transport;
+// when it is put to sleep instead, c.Asleep is set until the controller is used again.
+func StartActivityLoop(ctx context.Context, c *ControllerLoop) {
+	state, activityChan, conf, ctrlConf := c.UI, c.Activity, c.Conf, c.CtrlConf
+	path, dimmed, asleep := c.Path, c.Dimmed, c.Asleep
 
 	if Debug {
 		log.Default().Println("Starting activity loop for controller at path:", path)
 	}
 
-	lastActivityTime := time.Now()
-	ticker := time.NewTicker(1 * time.Second)
+	setDimmed := func(dim bool) {
+		if dimmed != nil && dimmed.Swap(dim) != dim && Debug {
+			log.Default().Printf("Lightbar dimmed=%v for controller at path: %s\n", dim, path)
+		}
+	}
+	defer setDimmed(false)
+
+	transport := discovery.DetectTransport(path)
+	powerCtrl := power.ForController(path)
+	poweredOff := false
+	wake := func() {
+		poweredOff = false
+		if asleep == nil || !asleep.Swap(false) {
+			return
+		}
+		if Debug {
+			log.Default().Println("Waking up controller at path:", path)
+		}
+		if err := powerCtrl.Wake(path); err != nil {
+			log.Default().Println("Error waking up controller:", err)
+		}
+	}
+	defer wake()
+
+	lastActivityTime := Clock.Now()
+	ticker := Clock.NewTicker(1 * time.Second)
+	defer ticker.Stop()
 	for {
 		select {
 		case <-ctx.Done(): // Si on annule le contexte, on arrête TOUT
@@ -249,6 +533,8 @@ func StartActivityLoop(ctx context.Context, state *ui.ControllerState, activityC
 			return
 		case t := <-activityChan:
 			lastActivityTime = t
+			setDimmed(false)
+			wake()
 			if state != nil {
 				err := state.LastActivityBinding.Set("In use")
 
@@ -256,16 +542,11 @@ func StartActivityLoop(ctx context.Context, state *ui.ControllerState, activityC
 					log.Default().Println("Error setting last activity binding:", err)
 				}
 			}
-		case <-ticker.C:
-			var status string
-			if storedStatus != nil {
-				status = *storedStatus
-			} else {
-				status = ""
-			}
+		case <-ticker.C():
+			status := c.Status()
 
 			if strings.Contains(status, "not found") || strings.Contains(status, "Recherche") {
-				lastActivityTime = time.Now()
+				lastActivityTime = Clock.Now()
 
 				if state != nil {
 					err := state.LastActivityBinding.Set("Disconnected")
@@ -275,57 +556,109 @@ func StartActivityLoop(ctx context.Context, state *ui.ControllerState, activityC
 				}
 				continue
 			}
-			diff := time.Since(lastActivityTime)
+			diff := Clock.Now().Sub(lastActivityTime)
 
-			currentChoice := conf.IdleMinutes
+			currentChoice := conf.IdleTimeout(ctrlConf)
+			policy := ctrlConf.IdlePolicy
 
-			if state != nil {
-				if currentChoice == 0 {
-					err := state.LastActivityBinding.Set(fmt.Sprintf("Inactive : %s (Auto-off Disabled)", diff.Truncate(time.Second)))
-					if err != nil {
-						log.Default().Println("Error setting last activity binding:", err)
-					}
+			if currentChoice == 0 || policy == config.IdlePolicyNone {
+				setActivityText(state, fmt.Sprintf("Inactive : %s (Auto-off Disabled)", diff.Truncate(time.Second)))
+				continue
+			}
+			// over USB the controller always charges, and is only put to sleep
+			charging := strings.Contains(status, "Charging") || strings.Contains(status, "Full")
+			if charging && transport != discovery.TransportUSB {
+				setActivityText(state, fmt.Sprintf("Inactive : %s (disabled due to charging)", diff.Truncate(time.Second)))
+				continue
+			}
+
+			limit := time.Duration(currentChoice) * time.Minute
+
+			setActivityText(state, fmt.Sprintf("Inactive : %s / %d min", diff.Truncate(time.Second), currentChoice))
+			if diff <= limit {
+				continue
+			}
+
+			switch policy {
+			case config.IdlePolicyDim:
+				setDimmed(true)
+			default:
+				if poweredOff {
 					continue
 				}
-				if strings.Contains(status, "Charging") || strings.Contains(status, "Full") {
-					err := state.LastActivityBinding.Set(fmt.Sprintf("Inactive : %s (disabled due to charging)", diff.Truncate(time.Second)))
-					if err != nil {
-						log.Default().Println("Error setting last activity binding:", err)
-					}
-					continue
+				log.Default().Println("Auto disconnect !")
+				poweredOff = true
+
+				if err := c.PowerOff(); err != nil {
+					log.Default().Println("Fail power off:", err)
+				}
+				if asleep != nil && asleep.Load() {
+					setActivityText(state, fmt.Sprintf("Asleep : %s", diff.Truncate(time.Second)))
 				}
 			}
+		}
+	}
+}
 
-			limit := time.Duration(currentChoice) * time.Minute
+// StartDriftLoop periodically reports the drift analysis of the controller sticks
+// in the controller tab, and logs when drift is first suspected.
+func StartDriftLoop(ctx context.Context, state *ui.ControllerState, analyzer *drift.Analyzer, path string) {
+	ticker := Clock.NewTicker(30 * time.Second)
+	defer ticker.Stop()
 
-			if state != nil {
-				err := state.LastActivityBinding.Set(fmt.Sprintf("Inactive : %s / %d min", diff.Truncate(time.Second), currentChoice))
-				if err != nil {
-					log.Default().Println("Error setting last activity binding:", err)
-				}
+	reported := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			report := analyzer.Report()
+			if report.Drifting() && !reported {
+				log.Default().Printf("%s for controller at path: %s\n", report.Summary(), path)
 			}
-			if diff > limit {
-				log.Default().Println("Auto disconnect !")
+			reported = report.Drifting()
 
-				if mac != "" {
-					err := bluetooth.DisconnectDualSenseNative(mac)
-					if err != nil {
-						log.Default().Println("Fail D-Bus:", err)
-					}
+			if state != nil {
+				err := state.Drift.Set(report.Summary())
+				if err != nil {
+					log.Default().Println("Error setting drift binding:", err)
 				}
 			}
 		}
 	}
 }
 
+// poweredStatus returns the charging status of a controller connected through
+// transport. Over USB the controller is powered by the port, so it is charging
+// even when the driver did not notice the cable yet.
+func poweredStatus(status string, transport discovery.Transport) string {
+	if transport == discovery.TransportUSB && (status == "Discharging" || status == "Unknown") {
+		return "Charging"
+	}
+	return status
+}
+
+func setActivityText(state *ui.ControllerState, text string) {
+	if state == nil {
+		return
+	}
+	err := state.LastActivityBinding.Set(text)
+	if err != nil {
+		log.Default().Println("Error setting last activity binding:", err)
+	}
+}
+
 // StartControllerManager watches for controllers and creates UI tabs for them.
-func StartControllerManager(globalState *ui.GlobalState, conf *config.Config) *container.AppTabs {
+// onChange, when not nil, is called on the UI thread with the loops of the connected
+// controllers each time a controller comes or goes.
+func StartControllerManager(globalState *ui.GlobalState, conf *config.Config, onChange func(connected []*ControllerLoop)) *container.AppTabs {
 	if Debug {
 		log.Default().Println("StartControllerManager: Debug mode enabled")
 	}
 	emptyTab := container.NewTabItem("Info", widget.NewLabel("Waiting for DualSense..."))
 	tabs := container.NewAppTabs(emptyTab)
 	activeControllers := make(map[string]*ui.ControllerTab)
+	loops := make(map[string]*ControllerLoop)
 
 	refreshTabs := func() {
 		var items []*container.TabItem
@@ -333,7 +666,7 @@ func StartControllerManager(globalState *ui.GlobalState, conf *config.Config) *c
 			items = append(items, emptyTab)
 		} else {
 			for _, ctrl := range activeControllers {
-				tabName := fmt.Sprintf("DualSense %s", ShortMAC(ctrl.MacAddress))
+				tabName := fmt.Sprintf("%s %s", ctrl.State.Model, ShortMAC(ctrl.MacAddress))
 				items = append(items, container.NewTabItem(tabName, ctrl.Container))
 			}
 		}
@@ -343,51 +676,116 @@ func StartControllerManager(globalState *ui.GlobalState, conf *config.Config) *c
 	}
 
 	go func() {
+		controllerIDs := make(map[string]int)
+		connections := newConnectionTracker()
+		history := openBatteryHistory()
+		health := openBatteryHealth()
+		devices := Discovery.Watch(context.Background())
+		btEvents := watchBluetooth(context.Background())
+
 		for {
-			foundPaths, err := discovery.FindAllDualSense()
-			if err != nil {
-				log.Default().Println("Error finding DualSense controllers:", err)
-				return
+			var ev discovery.Event
+			select {
+			case ev = <-devices:
+			case btEv, ok := <-btEvents:
+				if !ok {
+					btEvents = nil
+					continue
+				}
+				updateTabConnection(activeControllers, connections, btEv)
+				continue
 			}
-			changed := false
 
-			for id, path := range foundPaths {
-				if _, exists := activeControllers[path]; !exists {
+			switch ev.Action {
+			case discovery.Added:
+				if _, exists := activeControllers[ev.Path]; exists {
+					continue
+				}
+				if Debug {
+					log.Default().Println("New DualSense detected at path:", ev.Path)
+				}
 
+				mac := bluetooth.ControllerMAC(ev.Path)
+				id := freeControllerID(controllerIDs)
+				for oldPath, ctrl := range activeControllers {
+					if mac == "" || ctrl.MacAddress != mac {
+						continue
+					}
+					// the controller switched between USB and Bluetooth before the node of
+					// the previous transport was removed: the new node takes over its tab
 					if Debug {
-						log.Default().Println("New DualSense detected at path:", path)
+						log.Default().Printf("Controller %s moved from %s to %s\n", mac, oldPath, ev.Path)
 					}
+					ctrl.CancelFunc()
+					id = controllerIDs[oldPath]
+					delete(activeControllers, oldPath)
+					delete(loops, oldPath)
+					delete(controllerIDs, oldPath)
+				}
+				controllerIDs[ev.Path] = id
 
-					ctx, cancel := context.WithCancel(context.Background())
-					mac := bluetooth.ControllerMAC(path)
-					ctrlConf := conf.ControllerConfig(mac)
-					newTab := ui.CreateNewControllerTab(globalState, path, conf, ctrlConf, mac, id+1)
-					newTab.CancelFunc = cancel
-					activeControllers[path] = newTab
-
-					go MonitorJoystick(path, newTab.ActivityChan, ctrlConf)
-					go ManageBatteryAndLEDs(ctx, newTab.State, ctrlConf, conf, path, id+1, &newTab.State.Status)
-					go StartActivityLoop(ctx, newTab.State, newTab.ActivityChan, conf, mac, path, &newTab.State.Status)
-
-					changed = true
+				ctx, cancel := context.WithCancel(context.Background())
+				ctrlConf := conf.ControllerConfig(mac)
+				model := discovery.ControllerModel(ev.Path)
+				transport := discovery.DetectTransport(ev.Path)
+				newTab := ui.CreateNewControllerTab(globalState, ev.Path, conf, ctrlConf, mac, id, model, transport, history, func() (hidraw.Info, error) {
+					return readHidInfo(ev.Path)
+				})
+				newTab.CancelFunc = cancel
+				activeControllers[ev.Path] = newTab
+				if mac != "" && transport != discovery.TransportUSB {
+					err := newTab.State.Connection.Set(connections.state(mac))
+					if err != nil {
+						log.Default().Println("Error setting connection state:", err)
+					}
 				}
-			}
 
-			for path, ctrl := range activeControllers {
-				if !pathExists(path) {
-					ctrl.CancelFunc()
-					delete(activeControllers, path)
-					changed = true
+				analyzer := drift.NewAnalyzer()
+				go MonitorInput(ctx, ev.Path, newTab.ActivityChan, ctrlConf, analyzer, newTab.Input)
+				go newTab.InputView.Run(ctx)
+				go newTab.BatteryChart.Run(ctx)
+				go StartDriftLoop(ctx, newTab.State, analyzer, ev.Path)
+				go applyHidSettings(ev.Path, ctrlConf, model)
+				loop := &ControllerLoop{
+					Path:     ev.Path,
+					MAC:      mac,
+					ID:       id,
+					Model:    model,
+					Conf:     conf,
+					CtrlConf: ctrlConf,
+					UI:       newTab.State,
+					Activity: newTab.ActivityChan,
+					Dimmed:   new(atomic.Bool),
+					Asleep:   new(atomic.Bool),
+				}
+				loops[ev.Path] = loop
+				go ManageBatteryAndLEDs(ctx, loop, history, health)
+				go StartActivityLoop(ctx, loop)
+				if conf.MicMute && model.HasMuteButton() {
+					go MonitorMicMute(ctx, ev.Path, LedsForController(ev.Path))
 				}
-			}
 
-			if changed {
-				fyne.Do(func() {
-					refreshTabs()
-				})
+			case discovery.Removed:
+				ctrl, exists := activeControllers[ev.Path]
+				if !exists {
+					continue
+				}
+				if Debug {
+					log.Default().Println("DualSense removed at path:", ev.Path)
+				}
+				ctrl.CancelFunc()
+				delete(activeControllers, ev.Path)
+				delete(loops, ev.Path)
+				delete(controllerIDs, ev.Path)
 			}
 
-			time.Sleep(2 * time.Second)
+			connected := connectedControllers(loops)
+			fyne.Do(func() {
+				refreshTabs()
+				if onChange != nil {
+					onChange(connected)
+				}
+			})
 		}
 	}()
 
@@ -401,59 +799,216 @@ func StartControllerManagerCLI(conf *config.Config) {
 	activeControllers := make(map[string]*ControllerCLI)
 
 	go func() {
+		controllerIDs := make(map[string]int)
+		connections := newConnectionTracker()
+		history := openBatteryHistory()
+		health := openBatteryHealth()
+		devices := Discovery.Watch(context.Background())
+		btEvents := watchBluetooth(context.Background())
+
 		for {
-			foundPaths, err := discovery.FindAllDualSense()
-			if err != nil {
-				log.Default().Println("Error finding DualSense controllers:", err)
-				return
+			var ev discovery.Event
+			select {
+			case ev = <-devices:
+			case btEv, ok := <-btEvents:
+				if !ok {
+					btEvents = nil
+					continue
+				}
+				if state, changed := connections.update(btEv); changed {
+					log.Default().Printf("Controller %s: %s\n", btEv.MAC, state)
+				}
+				continue
 			}
-			for id, path := range foundPaths {
-				ctx, cancel := context.WithCancel(context.Background())
 
-				defer cancel()
-				mac := bluetooth.ControllerMAC(path)
-				ctrlConf := conf.ControllerConfig(mac)
-				if _, exists := activeControllers[path]; !exists {
+			switch ev.Action {
+			case discovery.Added:
+				if _, exists := activeControllers[ev.Path]; exists {
+					continue
+				}
+				if Debug {
+					log.Default().Println("New DualSense detected at path:", ev.Path)
+				}
 
-					if Debug {
-						log.Default().Println("New DualSense detected at path:", path)
+				mac := bluetooth.ControllerMAC(ev.Path)
+				id := freeControllerID(controllerIDs)
+				for oldPath, ctrl := range activeControllers {
+					if mac == "" || ctrl.MacAddress != mac {
+						continue
 					}
-					activityChan := make(chan time.Time)
-					activeControllers[path] = &ControllerCLI{
-						Path:         path,
-						ActivityChan: activityChan,
-						CancelFunc:   cancel,
-						MacAddress:   mac,
+					// the controller switched between USB and Bluetooth before the node of
+					// the previous transport was removed: the new node takes over its number
+					if Debug {
+						log.Default().Printf("Controller %s moved from %s to %s\n", mac, oldPath, ev.Path)
 					}
-					go MonitorJoystick(path, activeControllers[path].ActivityChan, ctrlConf)
-					go ManageBatteryAndLEDs(ctx, nil, ctrlConf, conf, path, id+1, &activeControllers[path].Status)
-					go StartActivityLoop(ctx, nil, activeControllers[path].ActivityChan, conf, mac, path, &activeControllers[path].Status)
+					ctrl.CancelFunc()
+					id = controllerIDs[oldPath]
+					delete(activeControllers, oldPath)
+					delete(controllerIDs, oldPath)
+				}
+				controllerIDs[ev.Path] = id
 
+				ctx, cancel := context.WithCancel(context.Background())
+				ctrlConf := conf.ControllerConfig(mac)
+				ctrl := &ControllerCLI{
+					Path:         ev.Path,
+					ActivityChan: make(chan time.Time),
+					CancelFunc:   cancel,
+					MacAddress:   mac,
+					Model:        discovery.ControllerModel(ev.Path),
+					Transport:    discovery.DetectTransport(ev.Path),
 				}
+				log.Default().Printf("Controller %d (%s %s) connected over %s\n", id, ctrl.Model, mac, ctrl.Transport)
+				activeControllers[ev.Path] = ctrl
 
-			}
-			for path, ctrl := range activeControllers {
-				if !pathExists(path) {
-					ctrl.CancelFunc()
-					delete(activeControllers, path)
+				analyzer := drift.NewAnalyzer()
+				go MonitorInput(ctx, ev.Path, ctrl.ActivityChan, ctrlConf, analyzer, nil)
+				go StartDriftLoop(ctx, nil, analyzer, ev.Path)
+				go applyHidSettings(ev.Path, ctrlConf, ctrl.Model)
+				loop := &ControllerLoop{
+					Path:     ev.Path,
+					MAC:      mac,
+					ID:       id,
+					Model:    ctrl.Model,
+					Conf:     conf,
+					CtrlConf: ctrlConf,
+					Activity: ctrl.ActivityChan,
+					Dimmed:   new(atomic.Bool),
+					Asleep:   new(atomic.Bool),
+				}
+				go ManageBatteryAndLEDs(ctx, loop, history, health)
+				go StartActivityLoop(ctx, loop)
+				if conf.MicMute && ctrl.Model.HasMuteButton() {
+					go MonitorMicMute(ctx, ev.Path, LedsForController(ev.Path))
 				}
+
+			case discovery.Removed:
+				ctrl, exists := activeControllers[ev.Path]
+				if !exists {
+					continue
+				}
+				if Debug {
+					log.Default().Println("DualSense removed at path:", ev.Path)
+				}
+				ctrl.CancelFunc()
+				delete(activeControllers, ev.Path)
+				delete(controllerIDs, ev.Path)
 			}
-			time.Sleep(2 * time.Second)
 		}
 	}()
 
 	select {}
 }
 
-func pathExists(path string) bool {
-	_, err := os.Stat(path)
-	if err == nil {
-		return true
+// openBatteryHistory returns the battery history, or nil when the state directory is unavailable.
+func openBatteryHistory() *battery.History {
+	history, err := battery.OpenHistory()
+	if err != nil {
+		log.Default().Println("Battery history disabled:", err)
+		return nil
+	}
+	return history
+}
+
+// openBatteryHealth returns the battery health, or nil when it cannot be read.
+func openBatteryHealth() *battery.Health {
+	health, err := battery.OpenHealth()
+	if err != nil {
+		log.Default().Println("Battery health disabled:", err)
+		return nil
+	}
+	return health
+}
+
+// connectedControllers returns the loops of the controllers that have a tab and a MAC.
+func connectedControllers(loops map[string]*ControllerLoop) []*ControllerLoop {
+	var connected []*ControllerLoop
+	for _, loop := range loops {
+		if loop.MAC != "" {
+			connected = append(connected, loop)
+		}
+	}
+	return connected
+}
+
+// updateTabConnection shows the connection state and signal strength carried by a BlueZ
+// event in the tab of the matching controller.
+func updateTabConnection(activeControllers map[string]*ui.ControllerTab, connections *connectionTracker, ev bluetooth.DeviceEvent) {
+	state, changed := connections.update(ev)
+	if Debug && changed {
+		log.Default().Printf("Controller %s: %s\n", ev.MAC, state)
+	}
+
+	for _, ctrl := range activeControllers {
+		// BlueZ events of a controller plugged in over USB are about its previous link
+		if ctrl.MacAddress != ev.MAC || ctrl.State.Transport == discovery.TransportUSB {
+			continue
+		}
+		if changed {
+			err := ctrl.State.Connection.Set(state)
+			if err != nil {
+				log.Default().Println("Error setting connection state:", err)
+			}
+		}
+		if ev.Kind == bluetooth.EventRSSI {
+			err := ctrl.State.Signal.Set(formatRSSI(ev.RSSI))
+			if err != nil {
+				log.Default().Println("Error setting signal strength:", err)
+			}
+		}
+	}
+}
+
+// applyHidSettings restores the adaptive trigger effects and rumble limit of a newly detected controller.
+// Both are DualSense output reports, a DualShock 4 gets neither.
+func applyHidSettings(path string, ctrlConf *config.ControllerConfig, model discovery.Model) {
+	if model.IsDualShock4() {
+		return
 	}
-	if os.IsNotExist(err) {
-		return false
+	apply := func() {
+		if model.HasAdaptiveTriggers() && (ctrlConf.TriggerLeft.Mode != "" || ctrlConf.TriggerRight.Mode != "") {
+			err := triggers.Apply(path, ctrlConf.TriggerLeft, ctrlConf.TriggerRight)
+			if err != nil {
+				log.Default().Println("Error applying trigger effects:", err)
+			}
+		}
+		if ctrlConf.RumbleScale < 1 {
+			err := rumble.SetPowerLimit(path, ctrlConf.RumbleScale)
+			if err != nil {
+				log.Default().Println("Error applying rumble limit:", err)
+			}
+		}
 	}
-	return false
+
+	apply()
+	// Controller is not ready immediately after connection; reapply after a short delay.
+	Clock.Sleep(3 * time.Second)
+	apply()
+}
+
+// waitTicks waits for n ticks of ticker. It returns false when ctx is cancelled first.
+func waitTicks(ctx context.Context, ticker clock.Ticker, n int) bool {
+	for i := 0; i < n; i++ {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C():
+		}
+	}
+	return true
+}
+
+// freeControllerID returns the lowest controller number not used by ids.
+func freeControllerID(ids map[string]int) int {
+	used := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		used[id] = true
+	}
+	id := 1
+	for used[id] {
+		id++
+	}
+	return id
 }
 
 // ShortMAC returns a short (last 5 chars) representation of the MAC address.
@@ -464,6 +1019,16 @@ func ShortMAC(fullMAC string) string {
 	return fullMAC
 }
 
+// ledAnimation returns the animation of the animated RGB mode of a controller,
+// the built-in animations being played with its static color, white when unset.
+func ledAnimation(conf *config.Config, ctrlConf *config.ControllerConfig) (leds.Animation, error) {
+	r, g, b := 255, 255, 255
+	if ctrlConf.LedRGBStatic != "" {
+		r, g, b = hexToRGB(ctrlConf.LedRGBStatic)
+	}
+	return leds.LoadAnimation(ctrlConf.LedRGBAnimation, conf.Animations, r, g, b)
+}
+
 func hexToRGB(hexStr string) (int, int, int) {
 	hexStr = strings.TrimPrefix(hexStr, "#")
 	if len(hexStr) != 6 {
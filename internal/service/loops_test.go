@@ -1,6 +1,27 @@
 package service
 
-import "testing"
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"dualsense/internal/clock"
+	"dualsense/internal/config"
+	"dualsense/internal/hidraw"
+	"dualsense/internal/service/battery"
+	"dualsense/internal/service/discovery"
+	"dualsense/internal/service/leds"
+	"dualsense/internal/sysfs"
+)
 
 func TestHexToRGB(t *testing.T) {
 
@@ -45,3 +66,573 @@ func TestShortMac(t *testing.T) {
 	}
 
 }
+
+func TestFreeControllerID(t *testing.T) {
+
+	tests := []struct {
+		ids  map[string]int
+		want int
+	}{
+		{map[string]int{}, 1},
+		{map[string]int{"/dev/input/js0": 1}, 2},
+		{map[string]int{"/dev/input/js0": 1, "/dev/input/js2": 3}, 2},
+		{map[string]int{"/dev/input/js1": 2}, 1},
+	}
+
+	for _, tt := range tests {
+		if got := freeControllerID(tt.ids); got != tt.want {
+			t.Errorf("freeControllerID(%v) = %d; want %d", tt.ids, got, tt.want)
+		}
+	}
+}
+
+const idleMAC = "AA:AA:AA:AA:AA:AA"
+
+type activityLoop struct {
+	clock    *clock.Fake
+	activity chan time.Time
+	dimmed   *atomic.Bool
+	asleep   *atomic.Bool
+}
+
+// startActivityLoop runs StartActivityLoop on a fake clock for a discharging controller.
+func startActivityLoop(t *testing.T, conf *config.Config, ctrlConf *config.ControllerConfig) *activityLoop {
+	t.Helper()
+	oldClock := Clock
+	fake := clock.NewFake(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	Clock = fake
+
+	l := &activityLoop{clock: fake, activity: make(chan time.Time), dimmed: new(atomic.Bool), asleep: new(atomic.Bool)}
+	loop := &ControllerLoop{
+		Path:     "/dev/input/js0",
+		MAC:      idleMAC,
+		Conf:     conf,
+		CtrlConf: ctrlConf,
+		Activity: l.activity,
+		Dimmed:   l.dimmed,
+		Asleep:   l.asleep,
+	}
+	loop.SetStatus("Discharging")
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		StartActivityLoop(ctx, loop)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		Clock = oldClock
+	})
+
+	fake.BlockUntil(1)
+	return l
+}
+
+func TestStartActivityLoop_Policies(t *testing.T) {
+	tests := []struct {
+		name        string
+		global      int
+		ctrlConf    config.ControllerConfig
+		idle        time.Duration
+		disconnects bool
+		dims        bool
+	}{
+		{"global timeout", 1, config.ControllerConfig{IdlePolicy: config.IdlePolicyDisconnect}, 61 * time.Second, true, false},
+		{"per-controller timeout overrides global", 10, config.ControllerConfig{IdleMinutes: 2, IdlePolicy: config.IdlePolicyDisconnect}, 121 * time.Second, true, false},
+		{"not idle long enough", 10, config.ControllerConfig{IdleMinutes: 2, IdlePolicy: config.IdlePolicyDisconnect}, 119 * time.Second, false, false},
+		{"never overrides global", 1, config.ControllerConfig{IdleMinutes: config.IdleNever, IdlePolicy: config.IdlePolicyDisconnect}, time.Hour, false, false},
+		{"global never", 0, config.ControllerConfig{IdlePolicy: config.IdlePolicyDisconnect}, time.Hour, false, false},
+		{"dim policy", 1, config.ControllerConfig{IdlePolicy: config.IdlePolicyDim}, 61 * time.Second, false, true},
+		{"none policy", 1, config.ControllerConfig{IdlePolicy: config.IdlePolicyNone}, time.Hour, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := fakeBlueZ(t, idleMAC)
+			conf := &config.Config{IdleMinutes: tt.global}
+			ctrlConf := tt.ctrlConf
+			l := startActivityLoop(t, conf, &ctrlConf)
+
+			l.clock.Advance(tt.idle)
+			// the second tick is only received once the first one has been handled
+			l.clock.Advance(time.Second)
+
+			disconnects := len(bus.CallsTo("org.bluez.Device1.Disconnect"))
+			if tt.disconnects && disconnects == 0 {
+				t.Fatalf("expected controller to be disconnected")
+			}
+			if !tt.disconnects && disconnects != 0 {
+				t.Fatalf("unexpected %d Disconnect calls", disconnects)
+			}
+			if l.dimmed.Load() != tt.dims {
+				t.Fatalf("dimmed = %v, want %v", l.dimmed.Load(), tt.dims)
+			}
+		})
+	}
+}
+
+func TestStartActivityLoop_ActivityUndims(t *testing.T) {
+	fakeBlueZ(t, idleMAC)
+	conf := &config.Config{IdleMinutes: 5}
+	ctrlConf := &config.ControllerConfig{IdleMinutes: 1, IdlePolicy: config.IdlePolicyDim}
+	l := startActivityLoop(t, conf, ctrlConf)
+
+	l.clock.Advance(61 * time.Second)
+	l.clock.Advance(time.Second)
+	if !l.dimmed.Load() {
+		t.Fatalf("expected lightbar to be dimmed after idle timeout")
+	}
+
+	l.activity <- l.clock.Now()
+	l.clock.Advance(time.Second)
+	if l.dimmed.Load() {
+		t.Fatalf("expected activity to restore the lightbar")
+	}
+
+	// the idle period starts again from the last activity
+	l.clock.Advance(50 * time.Second)
+	l.clock.Advance(time.Second)
+	if l.dimmed.Load() {
+		t.Fatalf("expected lightbar to stay on within the idle timeout")
+	}
+}
+
+func TestStartActivityLoop_USB(t *testing.T) {
+	oldFS, oldOpen := sysfs.FS, hidraw.Open
+	defer func() { sysfs.FS, hidraw.Open = oldFS, oldOpen }()
+	sysfs.FS = usbFS{}
+	var reports []*hidraw.OutputReport
+	var reportsMu sync.Mutex
+	hidraw.Open = func(_ string) (io.ReadWriteCloser, error) {
+		return reportWriter(func(p []byte) {
+			r, err := hidraw.ParseOutputReport(p)
+			if err != nil {
+				t.Errorf("invalid report % x: %v", p, err)
+				return
+			}
+			reportsMu.Lock()
+			defer reportsMu.Unlock()
+			reports = append(reports, r)
+		}), nil
+	}
+	sent := func() []*hidraw.OutputReport {
+		reportsMu.Lock()
+		defer reportsMu.Unlock()
+		return append([]*hidraw.OutputReport(nil), reports...)
+	}
+
+	bus := fakeBlueZ(t, idleMAC)
+	conf := &config.Config{IdleMinutes: 1}
+	l := startActivityLoop(t, conf, &config.ControllerConfig{IdlePolicy: config.IdlePolicyDisconnect})
+
+	l.clock.Advance(61 * time.Second)
+	l.clock.Advance(time.Second)
+
+	if n := len(bus.CallsTo("org.bluez.Device1.Disconnect")); n != 0 {
+		t.Fatalf("unexpected %d Disconnect calls over USB", n)
+	}
+	if !l.asleep.Load() {
+		t.Fatalf("expected the controller to be put to sleep")
+	}
+	got := sent()
+	if len(got) != 1 {
+		t.Fatalf("expected 1 sleep report, got %d", len(got))
+	}
+	if got[0].Flags1&hidraw.Flag1PowerSave == 0 || got[0].PowerSave == 0 || got[0].Red|got[0].Green|got[0].Blue|got[0].PlayerLeds != 0 {
+		t.Fatalf("unexpected sleep report %+v", got[0])
+	}
+
+	// asleep until used again
+	l.clock.Advance(time.Second)
+	if n := len(sent()); n != 1 {
+		t.Fatalf("expected the sleep report to be sent once, got %d", n)
+	}
+	l.activity <- l.clock.Now()
+	l.clock.Advance(time.Second)
+	if l.asleep.Load() {
+		t.Fatalf("expected activity to wake the controller up")
+	}
+	got = sent()
+	if len(got) != 2 || got[1].Flags1 != hidraw.Flag1PowerSave || got[1].PowerSave != 0 {
+		t.Fatalf("expected a wake report, got %+v", got)
+	}
+}
+
+func TestPoweredStatus(t *testing.T) {
+	tests := []struct {
+		status    string
+		transport discovery.Transport
+		want      string
+	}{
+		{"Discharging", discovery.TransportUSB, "Charging"},
+		{"Unknown", discovery.TransportUSB, "Charging"},
+		{"Full", discovery.TransportUSB, "Full"},
+		{"Not charging", discovery.TransportUSB, "Not charging"},
+		{"Discharging", discovery.TransportBluetooth, "Discharging"},
+		{"Discharging", discovery.TransportUnknown, "Discharging"},
+	}
+	for _, tt := range tests {
+		if got := poweredStatus(tt.status, tt.transport); got != tt.want {
+			t.Errorf("poweredStatus(%q, %v) = %q, want %q", tt.status, tt.transport, got, tt.want)
+		}
+	}
+}
+
+// reportWriter is a hidraw node passing each report written to write.
+type reportWriter func(p []byte)
+
+func (reportWriter) Read(_ []byte) (int, error) { return 0, io.EOF }
+func (w reportWriter) Write(p []byte) (int, error) {
+	w(append([]byte(nil), p...))
+	return len(p), nil
+}
+func (reportWriter) Close() error { return nil }
+
+// controllerFS is a fake sysfs exposing the battery and sysfs LEDs of js0.
+type controllerFS struct {
+	mu       sync.Mutex
+	capacity int
+	status   string
+	writes   map[string]string
+}
+
+const (
+	lifecycleJS      = "/dev/input/js0"
+	lifecycleBattery = "/sys/class/input/js0/device/device/power_supply/ps-controller-battery-aa:aa:aa:aa:aa:aa"
+	lifecycleLeds    = "/sys/class/input/js0/device/leds"
+)
+
+var controllerFSPaths = []string{
+	lifecycleBattery,
+	lifecycleLeds + "/input5:rgb:indicator",
+	lifecycleLeds + "/input5:white:player-1",
+	lifecycleLeds + "/input5:white:player-2",
+	lifecycleLeds + "/input5:white:player-3",
+	lifecycleLeds + "/input5:white:player-4",
+	lifecycleLeds + "/input5:white:player-5",
+}
+
+func (f *controllerFS) set(capacity int, status string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.capacity, f.status = capacity, status
+}
+
+func (f *controllerFS) written(path string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.writes[path]
+}
+
+func (f *controllerFS) ReadFile(path string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch path {
+	case lifecycleBattery + "/capacity":
+		return []byte(strconv.Itoa(f.capacity) + "\n"), nil
+	case lifecycleBattery + "/status":
+		return []byte(f.status + "\n"), nil
+	}
+	return nil, fmt.Errorf("not found: %s", path)
+}
+
+func (f *controllerFS) WriteFile(path string, data []byte, _ os.FileMode) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.writes[path] = string(data)
+	return nil
+}
+
+func (f *controllerFS) Glob(pattern string) ([]string, error) {
+	var matches []string
+	for _, path := range controllerFSPaths {
+		if ok, _ := filepath.Match(pattern, path); ok {
+			matches = append(matches, path)
+		}
+	}
+	return matches, nil
+}
+
+func (f *controllerFS) Stat(path string) (os.FileInfo, error) {
+	if path == lifecycleLeds {
+		return nil, nil
+	}
+	return nil, fmt.Errorf("not found: %s", path)
+}
+
+func TestControllerLifecycle(t *testing.T) {
+	fs := &controllerFS{capacity: 80, status: "Discharging", writes: map[string]string{}}
+	oldFS, oldOpen, oldNotify, oldTooltip := sysfs.FS, OpenJoystick, Notify, Tooltip
+	oldClock, oldLedsClock := Clock, leds.Clock
+	fake := clock.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	joystick, press := io.Pipe()
+
+	var alertsMu sync.Mutex
+	var alerts []string
+	sysfs.FS = fs
+	Clock, leds.Clock = fake, fake
+	OpenJoystick = func(_ string) (io.ReadCloser, error) { return joystick, nil }
+	Notify = func(_, content string) {
+		alertsMu.Lock()
+		defer alertsMu.Unlock()
+		alerts = append(alerts, content)
+	}
+	sentAlerts := func() []string {
+		alertsMu.Lock()
+		defer alertsMu.Unlock()
+		return append([]string(nil), alerts...)
+	}
+	var tooltip atomic.Value
+	Tooltip = func(text string) { tooltip.Store(text) }
+	bus := fakeBlueZ(t, idleMAC)
+	disconnects := func() int { return len(bus.CallsTo("org.bluez.Device1.Disconnect")) }
+
+	conf := &config.Config{IdleMinutes: 10, BatteryAlert: 15}
+	ctrlConf := conf.ControllerConfig(idleMAC)
+	activity := make(chan time.Time)
+	loop := &ControllerLoop{
+		Path:     lifecycleJS,
+		MAC:      idleMAC,
+		ID:       1,
+		Conf:     conf,
+		CtrlConf: ctrlConf,
+		Activity: activity,
+		Dimmed:   new(atomic.Bool),
+	}
+	history := battery.NewHistory(t.TempDir())
+	health, err := battery.NewHealth(filepath.Join(t.TempDir(), battery.HealthFile))
+	if err != nil {
+		t.Fatalf("NewHealth error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(4)
+	// relay the joystick activity so that a button press is known to have
+	// reached the activity loop before the clock moves on
+	pressed, delivered := make(chan time.Time), make(chan struct{})
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case t := <-pressed:
+				select {
+				case activity <- t:
+					delivered <- struct{}{}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	go func() { defer wg.Done(); MonitorJoystick(ctx, lifecycleJS, pressed, ctrlConf, nil, nil) }()
+	go func() {
+		defer wg.Done()
+		ManageBatteryAndLEDs(ctx, loop, history, health)
+	}()
+	go func() {
+		defer wg.Done()
+		StartActivityLoop(ctx, loop)
+	}()
+	defer func() {
+		cancel()
+		wg.Wait()
+		sysfs.FS, OpenJoystick, Notify, Tooltip = oldFS, oldOpen, oldNotify, oldTooltip
+		Clock, leds.Clock = oldClock, oldLedsClock
+	}()
+
+	run := func(d time.Duration) {
+		for elapsed := time.Duration(0); elapsed < d; elapsed += time.Second {
+			fake.Advance(time.Second)
+		}
+	}
+	pressButton := func() {
+		event := make([]byte, 8)
+		event[4], event[6], event[7] = 1, 1, 0 // button 0 pressed
+		if _, err := press.Write(event); err != nil {
+			t.Fatalf("writing joystick event: %v", err)
+		}
+		<-delivered
+	}
+
+	// connect: the battery loop and the activity loop tick every second
+	fake.BlockUntil(2)
+	pressButton()
+	run(5 * time.Second)
+	if got := fs.written(lifecycleLeds + "/input5:rgb:indicator/multi_intensity"); got != "102 255 0" {
+		t.Fatalf("expected lightbar to show 80%% battery, got %q", got)
+	}
+	if got := fs.written(lifecycleLeds + "/input5:white:player-3/brightness"); got != "1" {
+		t.Fatalf("expected player 1 LED, got %q", got)
+	}
+	if got := sentAlerts(); len(got) != 0 {
+		t.Fatalf("unexpected battery alert at 80%%: %v", got)
+	}
+	if got := tooltip.Load(); got != "DualSense Manager\nController 1: 80%, discharging" {
+		t.Fatalf("unexpected tray tooltip %q", got)
+	}
+
+	// battery alert, raised once per level change
+	fs.set(12, "Discharging")
+	run(5 * time.Second)
+	if got := sentAlerts(); len(got) != 1 || got[0] != "Controller 1 battery is at 12%" {
+		t.Fatalf("expected one low battery alert, got %v", got)
+	}
+
+	// charging pauses the idle timeout and the alerts
+	fs.set(11, "Charging")
+	run(30 * time.Minute)
+	if n := disconnects(); n != 0 {
+		t.Fatalf("controller disconnected while charging")
+	}
+	if got := sentAlerts(); len(got) != 1 {
+		t.Fatalf("unexpected battery alert while charging: %v", got)
+	}
+
+	// used again, then unplugged and left idle for 10 minutes; the press reaches
+	// the activity loop while charging, before any idle time is counted
+	pressButton()
+	fs.set(11, "Discharging")
+	run(9 * time.Minute)
+	if n := disconnects(); n != 0 {
+		t.Fatalf("controller disconnected after 9 minutes idle")
+	}
+	run(time.Minute + 2*time.Second)
+	if n := disconnects(); n == 0 {
+		t.Fatalf("expected controller to be disconnected after 10 minutes idle")
+	}
+
+	// the battery history keeps the changes, and a heartbeat while charging
+	samples, err := history.Samples(idleMAC, time.Time{})
+	if err != nil {
+		t.Fatalf("reading battery history: %v", err)
+	}
+	var changes []string
+	charging := 0
+	for _, s := range samples {
+		change := fmt.Sprintf("%d%% %s", s.Level, s.Status)
+		if len(changes) == 0 || changes[len(changes)-1] != change {
+			changes = append(changes, change)
+		}
+		if s.Status == "Charging" {
+			charging++
+		}
+	}
+	wantChanges := []string{"80% Discharging", "12% Discharging", "11% Charging", "11% Discharging"}
+	if !reflect.DeepEqual(changes, wantChanges) {
+		t.Fatalf("battery history changes = %v, want %v", changes, wantChanges)
+	}
+	if charging != 6 {
+		t.Fatalf("expected 6 samples over 30 minutes of charging, got %d", charging)
+	}
+
+	// the charge is counted in the battery health
+	if report, ok := health.Report(idleMAC); !ok || report.Charges != 1 {
+		t.Fatalf("expected one charge in the battery health, got %+v", report)
+	}
+}
+
+// lightbarRecorder is the sysfs LED backend recording the lightbar changes instead
+// of writing them, so that no delayed reapply outlives a test.
+type lightbarRecorder struct {
+	leds.Sysfs
+	mu      sync.Mutex
+	changes []string
+}
+
+func (l *lightbarRecorder) SetBatteryColor(_ string, percent float64) {
+	l.record(fmt.Sprintf("battery %.0f%%", percent))
+}
+
+func (l *lightbarRecorder) SetLightbarRGB(_ string, r, g, b int) {
+	l.record(fmt.Sprintf("%d %d %d", r, g, b))
+}
+
+func (l *lightbarRecorder) record(change string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.changes = append(l.changes, change)
+}
+
+func (l *lightbarRecorder) recorded() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.changes...)
+}
+
+func TestCriticalAlertFlashesLightbar(t *testing.T) {
+	fs := &controllerFS{capacity: 25, status: "Discharging", writes: map[string]string{}}
+	oldFS, oldNotify, oldClock, oldLedsClock, oldLeds := sysfs.FS, Notify, Clock, leds.Clock, LedsForController
+	fake := clock.NewFake(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	sysfs.FS = fs
+	Clock, leds.Clock = fake, fake
+	lightbar := &lightbarRecorder{}
+	LedsForController = func(_ string) leds.Leds { return lightbar }
+
+	var alertsMu sync.Mutex
+	var alerts []string
+	Notify = func(title, content string) {
+		alertsMu.Lock()
+		defer alertsMu.Unlock()
+		alerts = append(alerts, title+": "+content)
+	}
+	sentAlerts := func() []string {
+		alertsMu.Lock()
+		defer alertsMu.Unlock()
+		return append([]string(nil), alerts...)
+	}
+
+	conf := &config.Config{
+		BatteryAlerts: []config.BatteryAlert{
+			{Level: 30, Severity: config.SeverityWarning},
+			{Level: 5, Severity: config.SeverityCritical},
+		},
+		CriticalAction: config.CriticalActionFlash,
+	}
+	loop := &ControllerLoop{Path: lifecycleJS, MAC: idleMAC, ID: 2, Conf: conf, CtrlConf: conf.ControllerConfig(idleMAC)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ManageBatteryAndLEDs(ctx, loop, nil, nil)
+	}()
+	defer func() {
+		cancel()
+		<-done
+		sysfs.FS, Notify, LedsForController = oldFS, oldNotify, oldLeds
+		Clock, leds.Clock = oldClock, oldLedsClock
+	}()
+
+	run := func(d time.Duration) {
+		for elapsed := time.Duration(0); elapsed < d; elapsed += time.Second {
+			fake.Advance(time.Second)
+		}
+	}
+	fake.BlockUntil(1)
+	run(5 * time.Second)
+	fs.set(5, "Discharging")
+	run(5 * time.Second)
+	want := []string{
+		"DualSense Battery Warning: Controller 2 battery is at 25%",
+		"DualSense Battery Critical: Controller 2 battery is at 5%, charge it now",
+	}
+	if got := sentAlerts(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("alerts = %v, want %v", got, want)
+	}
+	if !slices.Contains(lightbar.recorded(), "255 0 0") {
+		t.Fatalf("expected the lightbar to flash red, got %v", lightbar.recorded())
+	}
+
+	// the battery color is back once the flashing stops
+	run(criticalFlashDuration)
+	if got := lightbar.recorded(); got[len(got)-1] != "battery 5%" {
+		t.Fatalf("expected the 5%% battery color after flashing, got %v", got)
+	}
+	if got := sentAlerts(); len(got) != 2 {
+		t.Fatalf("expected no alert repeated, got %v", got)
+	}
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"dualsense/internal/hidraw"
+	"dualsense/internal/service/audio"
+	"dualsense/internal/service/leds"
+	"log"
+	"time"
+)
+
+// MonitorMicMute toggles the mute of the default audio source when the mute button
+// of the controller behind path is pressed, and mirrors the source mute on the mute
+// LED through ledCtrl, until ctx is cancelled.
+func MonitorMicMute(ctx context.Context, path string, ledCtrl leds.Leds) {
+	go func() {
+		for muted := range audio.WatchSourceMute(ctx) {
+			ledCtrl.SetMuteLed(path, muted)
+		}
+	}()
+
+	// The mute button is handled by hid-playstation and not reported to the input
+	// nodes, so it is read from the input reports on the hidraw node.
+	for ctx.Err() == nil {
+		if err := readMuteButton(ctx, path); err != nil && Debug {
+			log.Default().Println("Error reading mute button:", err)
+		}
+		select {
+		case <-ctx.Done():
+		case <-Clock.After(5 * time.Second):
+		}
+	}
+}
+
+// readMuteButton toggles the source mute on each press of the mute button, until
+// the hidraw node of the controller is closed or ctx is cancelled.
+func readMuteButton(ctx context.Context, path string) error {
+	node, err := hidraw.NodePath(path)
+	if err != nil {
+		return err
+	}
+	f, err := hidraw.Open(node)
+	if err != nil {
+		return err
+	}
+	// Unblock the pending read when the controller goes away.
+	stopClose := context.AfterFunc(ctx, func() { _ = f.Close() })
+	defer func() {
+		if stopClose() {
+			if err := f.Close(); err != nil {
+				log.Default().Println("Error closing hidraw node:", err)
+			}
+		}
+	}()
+
+	buf := make([]byte, hidraw.InputReportBTSz)
+	held := false
+	for {
+		n, err := f.Read(buf)
+		if err != nil {
+			return err
+		}
+		pressed, ok := hidraw.MuteButton(buf[:n])
+		if !ok {
+			continue
+		}
+		if pressed && !held {
+			if err := audio.ToggleSourceMute(ctx); err != nil {
+				log.Default().Println(err)
+			}
+		}
+		held = pressed
+	}
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"dualsense/internal/hidraw"
+	"dualsense/internal/service/audio"
+	"dualsense/internal/sysfs"
+)
+
+// hidrawNodeFS is a fake sysfs exposing the hidraw node of js0.
+type hidrawNodeFS struct{ inputFS }
+
+func (hidrawNodeFS) Glob(pattern string) ([]string, error) {
+	if pattern != "/sys/class/input/js0/device/device/hidraw/hidraw*" {
+		return nil, nil
+	}
+	return []string{"/sys/class/input/js0/device/device/hidraw/hidraw2"}, nil
+}
+
+// inputReports is a hidraw node returning one input report per read.
+type inputReports [][]byte
+
+func (r *inputReports) Read(p []byte) (int, error) {
+	if len(*r) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, (*r)[0])
+	*r = (*r)[1:]
+	return n, nil
+}
+func (r *inputReports) Write(p []byte) (int, error) { return len(p), nil }
+func (r *inputReports) Close() error                { return nil }
+
+// usbInputReport returns a USB input report with the mute button held or released.
+func usbInputReport(muted bool) []byte {
+	b := make([]byte, hidraw.InputReportUSBSz)
+	b[0] = hidraw.InputReportUSB
+	if muted {
+		b[10] = hidraw.Button2MicMute
+	}
+	return b
+}
+
+func TestReadMuteButtonTogglesOnPress(t *testing.T) {
+	oldFS, oldOpen, oldRun := sysfs.FS, hidraw.Open, audio.Run
+	defer func() { sysfs.FS, hidraw.Open, audio.Run = oldFS, oldOpen, oldRun }()
+	sysfs.FS = hidrawNodeFS{}
+
+	reports := inputReports{
+		usbInputReport(false),
+		usbInputReport(true),
+		usbInputReport(true), // still held
+		{0x01, 0x80, 0x80},   // reduced report, ignored
+		usbInputReport(false),
+		usbInputReport(true),
+	}
+	hidraw.Open = func(path string) (io.ReadWriteCloser, error) {
+		if path != "/dev/hidraw2" {
+			return nil, fmt.Errorf("unexpected hidraw node %s", path)
+		}
+		return &reports, nil
+	}
+	var commands []string
+	audio.Run = func(_ context.Context, args ...string) ([]byte, error) {
+		commands = append(commands, strings.Join(args, " "))
+		return nil, nil
+	}
+
+	if err := readMuteButton(context.Background(), "/dev/input/js0"); err != io.EOF {
+		t.Fatalf("readMuteButton error = %v, want EOF", err)
+	}
+	toggle := "set-source-mute " + audio.DefaultSource + " toggle"
+	if len(commands) != 2 || commands[0] != toggle || commands[1] != toggle {
+		t.Fatalf("got pactl commands %q, want two toggles", commands)
+	}
+}
+
+func TestReadMuteButtonWithoutHidraw(t *testing.T) {
+	oldFS := sysfs.FS
+	defer func() { sysfs.FS = oldFS }()
+	sysfs.FS = inputFS{}
+
+	if err := readMuteButton(context.Background(), "/dev/input/js0"); err == nil {
+		t.Fatalf("expected an error without hidraw node")
+	}
+}
@@ -1,11 +1,15 @@
 package service
 
 import (
+	"context"
 	"dualsense/internal/config"
+	"dualsense/internal/service/drift"
+	"dualsense/internal/service/input"
 	"encoding/binary"
 	"io"
 	"log"
 	"os"
+	"sync"
 	"time"
 )
 
@@ -15,22 +19,42 @@ var OpenJoystick = func(path string) (io.ReadCloser, error) {
 	return os.Open(path)
 }
 
-// MonitorJoystick reads joystick events and notifies activity via activityChan.
-func MonitorJoystick(path string, activityChan chan time.Time, ctrlConf *config.ControllerConfig) {
+// driftAxes maps the stick axes, by evdev code, to the axes analysed for drift.
+var driftAxes = map[uint16]drift.Axis{
+	input.AbsX:  drift.LeftX,
+	input.AbsY:  drift.LeftY,
+	input.AbsRX: drift.RightX,
+	input.AbsRY: drift.RightY,
+}
+
+// driftPollInterval is how often the stick axes are read for the drift analyzer.
+// evdev only reports changes larger than the axis fuzz, so a resting stick sends
+// few events or none at all.
+const driftPollInterval = 100 * time.Millisecond
+
+// MonitorJoystick reads joystick events and notifies activity via activityChan until ctx is cancelled.
+// Stick and button events are also fed to analyzer and live, which may be nil.
+func MonitorJoystick(ctx context.Context, path string, activityChan chan time.Time, ctrlConf *config.ControllerConfig, analyzer *drift.Analyzer, live *input.State) {
 	if Debug {
 		log.Default().Println("Starting joystick monitor for controller at path:", path)
 	}
 
-	for {
+	for ctx.Err() == nil {
 		f, err := OpenJoystick(path)
 		if err != nil {
-			time.Sleep(5 * time.Second)
+			select {
+			case <-ctx.Done():
+			case <-Clock.After(5 * time.Second):
+			}
 			continue
 		}
+		// Unblock the pending read when the controller goes away.
+		stopClose := context.AfterFunc(ctx, func() { _ = f.Close() })
 
 		// Structure d'un événement joystick Linux (8 octets)
 		// Time (4) | Value (2) | Type (1) | Index (1)
 		buffer := make([]byte, 8)
+		var axes axisState
 
 		for {
 			_, err := io.ReadFull(f, buffer)
@@ -41,11 +65,26 @@ func MonitorJoystick(path string, activityChan chan time.Time, ctrlConf *config.
 				break
 			}
 
-			deadzone := int16(ctrlConf.Deadzone)
-
 			evType := buffer[6]
 			evValue := int16(binary.LittleEndian.Uint16(buffer[4:6]))
 
+			// Initial state events (JS_EVENT_INIT) give the resting position to the drift
+			// analyzer and the live state.
+			switch evType &^ 0x80 {
+			case 1:
+				analyzer.Button(int(buffer[7]), evValue != 0, Clock.Now())
+				if code, ok := input.JoystickButton(buffer[7]); ok {
+					live.SetButton(code, evValue != 0)
+				}
+			case 2:
+				if axis, ok := driftAxes[uint16(buffer[7])]; ok {
+					analyzer.Axis(axis, int(evValue), Clock.Now())
+				}
+				if code, value, ok := joystickAxis(buffer[7], int(evValue)); ok {
+					live.SetAxis(code, value)
+				}
+			}
+
 			isReal := false
 			switch evType {
 			case 1: // Bouton pressé
@@ -54,26 +93,240 @@ func MonitorJoystick(path string, activityChan chan time.Time, ctrlConf *config.
 					log.Default().Printf("Button %d event detected with value: %d\n", buffer[7], evValue)
 				}
 			case 2: // Axe bougé
-				if evValue > deadzone || evValue < -deadzone {
-					isReal = true
-					if Debug {
+				isReal = joystickAxisActivity(&axes, ctrlConf.Deadzones, buffer[7], int(evValue))
+				if Debug {
+					if isReal {
 						log.Default().Printf("Axis %d event detected with value: %d\n", buffer[7], evValue)
-					}
-				} else {
-					if Debug {
+					} else {
 						log.Default().Printf("Axis %d event ignored due to deadzone with value: %d\n", buffer[7], evValue)
 					}
 				}
 			}
 
 			if isReal {
-				activityChan <- time.Now()
+				select {
+				case activityChan <- Clock.Now():
+				case <-ctx.Done():
+				}
 			}
 		}
 
 		// Close file before retrying to avoid accumulating open descriptors
+		if stopClose() {
+			if err := f.Close(); err != nil {
+				log.Default().Println("Error closing joystick file:", err)
+			}
+		}
+	}
+}
+
+// MonitorInput reads evdev events from the gamepad, touchpad and motion sensors nodes
+// of the controller behind path and notifies activity via activityChan until ctx is
+// cancelled. Motion events are not activity: gyro noise would keep an idle controller
+// awake. It falls back to MonitorJoystick when the gamepad node cannot be found or opened.
+// Gamepad stick and button events are also fed to analyzer, and every event to live;
+// both may be nil.
+func MonitorInput(ctx context.Context, path string, activityChan chan time.Time, ctrlConf *config.ControllerConfig, analyzer *drift.Analyzer, live *input.State) {
+	nodes, err := input.Nodes(path)
+	if err != nil {
+		if Debug {
+			log.Default().Println("Falling back to joystick monitor:", err)
+		}
+		MonitorJoystick(ctx, path, activityChan, ctrlConf, analyzer, live)
+		return
+	}
+
+	files := make(map[input.Node]io.ReadCloser)
+	for _, node := range nodes {
+		f, err := input.OpenEventDevice(node.Path)
+		if err != nil {
+			log.Default().Printf("Cannot open %s: %v\n", node.Path, err)
+			if node.Kind == input.KindGamepad {
+				closeEventDevices(files)
+				log.Default().Println("Falling back to joystick monitor for", path)
+				MonitorJoystick(ctx, path, activityChan, ctrlConf, analyzer, live)
+				return
+			}
+			continue
+		}
+		files[node] = f
+	}
+
+	var wg sync.WaitGroup
+	for node, f := range files {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			monitorEventNode(ctx, node, f, activityChan, ctrlConf, analyzer, live)
+		}()
+	}
+	wg.Wait()
+}
+
+func closeEventDevices(files map[input.Node]io.ReadCloser) {
+	for _, f := range files {
 		if err := f.Close(); err != nil {
-			log.Default().Println("Error closing joystick file:", err)
+			log.Default().Println("Error closing input device:", err)
+		}
+	}
+}
+
+func monitorEventNode(ctx context.Context, node input.Node, f io.ReadCloser, activityChan chan time.Time, ctrlConf *config.ControllerConfig, analyzer *drift.Analyzer, live *input.State) {
+	if Debug {
+		log.Default().Printf("Starting input monitor for %s (%s)\n", node.Path, node.Name)
+	}
+	// Unblock the pending read when the controller goes away.
+	stopClose := context.AfterFunc(ctx, func() { _ = f.Close() })
+	defer func() {
+		if stopClose() {
+			if err := f.Close(); err != nil {
+				log.Default().Println("Error closing input device:", err)
+			}
+		}
+		if Debug {
+			log.Default().Println("Stopping input monitor for", node.Path)
+		}
+	}()
+
+	if node.Kind == input.KindGamepad && analyzer != nil {
+		pollCtx, stopPoll := context.WithCancel(ctx)
+		polled := make(chan struct{})
+		go func() {
+			defer close(polled)
+			pollDriftAxes(pollCtx, f, analyzer)
+		}()
+		// Stop polling before the device file is closed.
+		defer func() {
+			stopPoll()
+			<-polled
+		}()
+	}
+
+	var axes axisState
+	for {
+		ev, err := input.ReadEvent(f)
+		if err != nil {
+			return
+		}
+		live.Apply(ev, node.Kind)
+		if node.Kind == input.KindGamepad {
+			recordDrift(analyzer, ev)
+		}
+		if !isInputActivity(ev, node.Kind, &axes, ctrlConf.Deadzones) {
+			continue
+		}
+		if Debug {
+			log.Default().Printf("Input event type %d code %d value %d on %s\n", ev.Type, ev.Code, ev.Value, node.Path)
+		}
+
+		select {
+		case activityChan <- ev.Time:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// recordDrift feeds a gamepad evdev event to the drift analyzer. Samples are timed
+// with Clock, like the polled ones and those of the joystick interface.
+func recordDrift(analyzer *drift.Analyzer, ev input.Event) {
+	switch ev.Type {
+	case input.EvKey:
+		analyzer.Button(int(ev.Code), ev.Value != 0, Clock.Now())
+	case input.EvAbs:
+		if axis, ok := driftAxes[ev.Code]; ok {
+			analyzer.Axis(axis, (int(ev.Value)-128)*256, Clock.Now())
+		}
+	}
+}
+
+// pollDriftAxes feeds the current stick positions of the gamepad node f to the drift
+// analyzer right away, then every driftPollInterval until ctx is cancelled or the
+// axes cannot be read.
+func pollDriftAxes(ctx context.Context, f io.Reader, analyzer *drift.Analyzer) {
+	poll := func() bool {
+		now := Clock.Now()
+		for _, code := range []uint16{input.AbsX, input.AbsY, input.AbsRX, input.AbsRY} {
+			value, err := input.ReadAbs(f, code)
+			if err != nil {
+				if Debug {
+					log.Default().Println("Stopping stick polling:", err)
+				}
+				return false
+			}
+			analyzer.Axis(driftAxes[code], (int(value)-128)*256, now)
+		}
+		return true
+	}
+	if !poll() {
+		return
+	}
+
+	ticker := Clock.NewTicker(driftPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			if !poll() {
+				return
+			}
+		}
+	}
+}
+
+// joystickAxis returns the evdev code of a joystick interface axis and its value in
+// the scale of evdev axes. hid-playstation exposes the sticks and triggers as axes 0
+// to 5 in evdev code order, triggers resting at -32767, then the d-pad.
+func joystickAxis(index byte, value int) (uint16, int, bool) {
+	switch code := uint16(index); code {
+	case input.AbsZ, input.AbsRZ:
+		return code, (value + 32767) / 2, true
+	case input.AbsX, input.AbsY, input.AbsRX, input.AbsRY:
+		return code, value, true
+	case 6:
+		return input.AbsHat0X, value, true
+	case 7:
+		return input.AbsHat0Y, value, true
+	}
+	return 0, 0, false
+}
+
+// joystickAxisActivity reports whether a joystick interface axis event shows the
+// controller is in use.
+func joystickAxisActivity(axes *axisState, dz config.Deadzones, index byte, value int) bool {
+	code, v, ok := joystickAxis(index, value)
+	if ok && code <= input.AbsRZ {
+		return axes.outsideDeadzone(dz, code, v)
+	}
+	return value != 0
+}
+
+// isInputActivity reports whether an evdev event shows the controller is in use.
+// hid-playstation reports sticks and triggers in 0-255; they are scaled to the
+// joystick interface range the deadzones are expressed in.
+func isInputActivity(ev input.Event, kind input.Kind, axes *axisState, dz config.Deadzones) bool {
+	switch kind {
+	case input.KindTouchpad:
+		return ev.Type == input.EvKey || ev.Type == input.EvAbs
+	case input.KindGamepad:
+	default:
+		return false
+	}
+
+	switch ev.Type {
+	case input.EvKey:
+		return true
+	case input.EvAbs:
+		switch ev.Code {
+		case input.AbsX, input.AbsY, input.AbsRX, input.AbsRY:
+			return axes.outsideDeadzone(dz, ev.Code, (int(ev.Value)-128)*256)
+		case input.AbsZ, input.AbsRZ:
+			return axes.outsideDeadzone(dz, ev.Code, int(ev.Value)*128)
+		case input.AbsHat0X, input.AbsHat0Y:
+			return ev.Value != 0
 		}
 	}
+	return false
 }
@@ -2,12 +2,19 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
+	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
 	"dualsense/internal/config"
+	"dualsense/internal/service/input"
+	"dualsense/internal/sysfs"
 )
 
 type fakeReadCloser struct {
@@ -25,6 +32,12 @@ func makeEvent(value int16, evType byte, index byte) []byte {
 	return b
 }
 
+// axialDeadzones returns the deadzones migrated from a legacy single deadzone.
+func axialDeadzones(size int) config.Deadzones {
+	stick := config.StickDeadzone{Size: size, Shape: config.DeadzoneAxial}
+	return config.Deadzones{LeftStick: stick, RightStick: stick, LeftTrigger: size, RightTrigger: size}
+}
+
 func TestMonitorJoystickReceivesActivity(t *testing.T) {
 
 	tests := []struct {
@@ -47,7 +60,7 @@ func TestMonitorJoystickReceivesActivity(t *testing.T) {
 			name: "axis movement above deadzone",
 			events: func() []byte {
 				var buf bytes.Buffer
-				buf.Write(makeEvent(2000, 2, 5)) // axis event above deadzone
+				buf.Write(makeEvent(2000, 2, 3)) // axis event above deadzone
 				return buf.Bytes()
 			}(),
 			deadzone: 5000,
@@ -57,7 +70,7 @@ func TestMonitorJoystickReceivesActivity(t *testing.T) {
 			name: "axis movement below deadzone",
 			events: func() []byte {
 				var buf bytes.Buffer
-				buf.Write(makeEvent(3000, 2, 5)) // axis event above deadzone
+				buf.Write(makeEvent(3000, 2, 3)) // axis event above deadzone
 				return buf.Bytes()
 			}(),
 			deadzone: 2000,
@@ -77,11 +90,20 @@ func TestMonitorJoystickReceivesActivity(t *testing.T) {
 			activityChan := make(chan time.Time, 10)
 
 			ctrlConf := config.ControllerConfig{
-				Deadzone: tt.deadzone,
+				Deadzones: axialDeadzones(tt.deadzone),
 			}
 
-			// Run monitor in background
-			go MonitorJoystick("/dev/fakejs0", activityChan, &ctrlConf)
+			// Run monitor in background, stopping it before OpenJoystick is restored
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				MonitorJoystick(ctx, "/dev/fakejs0", activityChan, &ctrlConf, nil, nil)
+			}()
+			defer func() {
+				cancel()
+				<-done
+			}()
 
 			// Wait for up to 1s to receive expected number of activities
 			timeout := time.After(1 * time.Second)
@@ -105,3 +127,159 @@ func TestMonitorJoystickReceivesActivity(t *testing.T) {
 		})
 	}
 }
+
+// inputFS is a fake sysfs exposing the gamepad, motion sensors and touchpad event nodes of js0.
+type inputFS struct{}
+
+const inputHID = "/sys/class/input/js0/device/device/input"
+
+func (inputFS) ReadFile(path string) ([]byte, error) {
+	names := map[string]string{
+		inputHID + "/input12/name": "DualSense Wireless Controller",
+		inputHID + "/input13/name": "DualSense Wireless Controller Motion Sensors",
+		inputHID + "/input14/name": "DualSense Wireless Controller Touchpad",
+	}
+	if name, ok := names[path]; ok {
+		return []byte(name + "\n"), nil
+	}
+	return nil, fmt.Errorf("not found: %s", path)
+}
+func (inputFS) WriteFile(_ string, _ []byte, _ os.FileMode) error {
+	return fmt.Errorf("not implemented")
+}
+func (inputFS) Glob(pattern string) ([]string, error) {
+	if pattern != "/sys/class/input/js0/device/device/input/input*/event*" {
+		return nil, nil
+	}
+	return []string{inputHID + "/input12/event7", inputHID + "/input13/event8", inputHID + "/input14/event9"}, nil
+}
+func (inputFS) Stat(_ string) (os.FileInfo, error) { return nil, fmt.Errorf("not implemented") }
+
+func readRecording(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("reading recording: %v", err)
+	}
+	return data
+}
+
+func TestMonitorInputReplaysRecordings(t *testing.T) {
+	recorded := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name      string
+		gamepad   string
+		touchpad  string
+		motion    string
+		deadzone  int
+		want      int
+		firstTime time.Time
+	}{
+		{"resting sticks stay inside deadzone", "gamepad_idle.evdev", "", "", 1500, 0, time.Time{}},
+		{"buttons and d-pad", "gamepad_buttons.evdev", "", "", 1500, 3, recorded.Add(100 * time.Millisecond)},
+		{"sticks and triggers", "gamepad_sticks.evdev", "", "", 1500, 3, recorded.Add(time.Second)},
+		{"touchpad swipe", "", "touchpad_swipe.evdev", "", 1500, 4, recorded.Add(2 * time.Second)},
+		{"motion sensors are not activity", "", "", "motion_resting.evdev", 1500, 0, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldFS, oldOpen := sysfs.FS, input.OpenEventDevice
+			defer func() { sysfs.FS, input.OpenEventDevice = oldFS, oldOpen }()
+			sysfs.FS = inputFS{}
+
+			streams := map[string][]byte{"/dev/input/event7": nil, "/dev/input/event8": nil, "/dev/input/event9": nil}
+			if tt.gamepad != "" {
+				streams["/dev/input/event7"] = readRecording(t, tt.gamepad)
+			}
+			if tt.touchpad != "" {
+				streams["/dev/input/event9"] = readRecording(t, tt.touchpad)
+			}
+			if tt.motion != "" {
+				streams["/dev/input/event8"] = readRecording(t, tt.motion)
+			}
+			var mu sync.Mutex
+			input.OpenEventDevice = func(path string) (io.ReadCloser, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				stream, ok := streams[path]
+				if !ok {
+					t.Errorf("unexpected open of %s", path)
+					return nil, fmt.Errorf("permission denied")
+				}
+				return fakeReadCloser{bytes.NewReader(stream)}, nil
+			}
+
+			activityChan := make(chan time.Time, 64)
+			ctrlConf := &config.ControllerConfig{Deadzones: axialDeadzones(tt.deadzone)}
+			// the recordings end with EOF, which stops the monitor
+			live := input.NewState()
+			MonitorInput(context.Background(), "/dev/input/js0", activityChan, ctrlConf, nil, live)
+			close(activityChan)
+
+			var activities []time.Time
+			for at := range activityChan {
+				activities = append(activities, at)
+			}
+			if len(activities) != tt.want {
+				t.Fatalf("expected %d activities, got %d", tt.want, len(activities))
+			}
+			if tt.want > 0 && !activities[0].Equal(tt.firstTime) {
+				t.Fatalf("expected first activity at the recorded %v, got %v", tt.firstTime, activities[0])
+			}
+			if tt.motion != "" {
+				want := input.Motion{Accel: [3]int{-191, 0, 0}, Gyro: [3]int{6, 0, 0}, Timestamp: 36000}
+				if got := live.Snapshot().Motion; got != want {
+					t.Fatalf("expected the last motion sample %+v, got %+v", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestMonitorInputFallsBackToJoystick(t *testing.T) {
+	tests := []struct {
+		name string
+		fs   sysfs.FileSystem
+	}{
+		{"no event node", &inputFSWithoutNodes{}},
+		{"event node not readable", inputFS{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldFS, oldOpenEvent, oldOpenJoystick := sysfs.FS, input.OpenEventDevice, OpenJoystick
+			defer func() { sysfs.FS, input.OpenEventDevice, OpenJoystick = oldFS, oldOpenEvent, oldOpenJoystick }()
+			sysfs.FS = tt.fs
+			input.OpenEventDevice = func(path string) (io.ReadCloser, error) {
+				return nil, fmt.Errorf("open %s: permission denied", path)
+			}
+			OpenJoystick = func(_ string) (io.ReadCloser, error) {
+				return fakeReadCloser{bytes.NewReader(makeEvent(1, 1, 3))}, nil
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			activityChan := make(chan time.Time)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				MonitorInput(ctx, "/dev/input/js0", activityChan, &config.ControllerConfig{Deadzones: axialDeadzones(1500)}, nil, nil)
+			}()
+			defer func() {
+				cancel()
+				<-done
+			}()
+
+			select {
+			case <-activityChan:
+			case <-time.After(time.Second):
+				t.Fatalf("expected activity from the joystick interface")
+			}
+		})
+	}
+}
+
+type inputFSWithoutNodes struct{ inputFS }
+
+func (*inputFSWithoutNodes) Glob(_ string) ([]string, error) { return nil, nil }
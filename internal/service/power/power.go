@@ -0,0 +1,112 @@
+// Package power turns idle controllers off, through BlueZ or by putting them to sleep
+// with an output report when BlueZ cannot disconnect them.
+package power
+
+import (
+	"fmt"
+	"log"
+
+	"dualsense/internal/hidraw"
+	"dualsense/internal/service/bluetooth"
+	"dualsense/internal/service/discovery"
+	"dualsense/internal/service/leds"
+)
+
+// Debug enables the logging of the power backend fallbacks.
+var Debug bool
+
+// Power turns a controller off, or puts it to sleep when it cannot be turned off.
+type Power interface {
+	// PowerOff turns the controller off. It returns true when the controller was put
+	// to sleep instead, and stays connected until Wake.
+	PowerOff(jsPath, mac string) (bool, error)
+	// Wake ends the sleep of a controller used again.
+	Wake(jsPath string) error
+}
+
+// BlueZ asks BlueZ to disconnect the controller, which then turns itself off.
+type BlueZ struct{}
+
+// Sleep puts the controller to sleep: the lightbar, the player LEDs and the motors
+// are turned off, and the haptics and audio are powered down. It works over USB,
+// where the controller cannot be turned off, and without BlueZ.
+type Sleep struct{}
+
+// fallback turns the controller off with primary, and with secondary when it fails.
+type fallback struct {
+	primary, secondary Power
+}
+
+var (
+	_ Power = BlueZ{}
+	_ Power = Sleep{}
+	_ Power = fallback{}
+)
+
+// ForController returns the Power implementation for the controller behind jsPath:
+// sleep over USB, BlueZ falling back to sleep over Bluetooth, and BlueZ when the
+// transport is unknown.
+func ForController(jsPath string) Power {
+	switch discovery.DetectTransport(jsPath) {
+	case discovery.TransportUSB:
+		return Sleep{}
+	case discovery.TransportBluetooth:
+		return fallback{primary: BlueZ{}, secondary: Sleep{}}
+	}
+	return BlueZ{}
+}
+
+// PowerOff disconnects the controller through BlueZ.
+func (BlueZ) PowerOff(jsPath, mac string) (bool, error) {
+	if mac == "" {
+		return false, fmt.Errorf("no MAC address for %s", jsPath)
+	}
+	return false, bluetooth.DisconnectDualSenseNative(mac)
+}
+
+// Wake does nothing, a disconnected controller is woken up by its PS button.
+func (BlueZ) Wake(_ string) error { return nil }
+
+// PowerOff puts the controller to sleep.
+func (Sleep) PowerOff(jsPath, _ string) (bool, error) {
+	if discovery.ControllerModel(jsPath).IsDualShock4() {
+		leds.DualShock4{}.SetLightbarRGB(jsPath, 0, 0, 0)
+		return true, nil
+	}
+	err := hidraw.Send(jsPath, &hidraw.OutputReport{
+		Flags0:    hidraw.Flag0CompatibleVibration | hidraw.Flag0HapticsSelect,
+		Flags1:    hidraw.Flag1PowerSave | hidraw.Flag1Lightbar | hidraw.Flag1PlayerIndicator,
+		Flags2:    hidraw.Flag2CompatibleVibration2,
+		PowerSave: hidraw.PowerSaveHaptics | hidraw.PowerSaveAudio,
+	})
+	return err == nil, err
+}
+
+// Wake powers the haptics and audio up again. The LEDs are restored by their owner.
+func (Sleep) Wake(jsPath string) error {
+	if discovery.ControllerModel(jsPath).IsDualShock4() {
+		return nil
+	}
+	return hidraw.Send(jsPath, &hidraw.OutputReport{Flags1: hidraw.Flag1PowerSave})
+}
+
+// PowerOff turns the controller off with the primary backend, or the secondary one.
+func (f fallback) PowerOff(jsPath, mac string) (bool, error) {
+	asleep, err := f.primary.PowerOff(jsPath, mac)
+	if err == nil {
+		return asleep, nil
+	}
+	if Debug {
+		log.Default().Println("Powering off with the fallback backend:", err)
+	}
+	return f.secondary.PowerOff(jsPath, mac)
+}
+
+// Wake wakes the controller up with both backends, only the one that put it to
+// sleep has anything to undo.
+func (f fallback) Wake(jsPath string) error {
+	if err := f.primary.Wake(jsPath); err != nil {
+		return err
+	}
+	return f.secondary.Wake(jsPath)
+}
@@ -0,0 +1,171 @@
+package power
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"dualsense/internal/dbustest"
+	"dualsense/internal/hidraw"
+	"dualsense/internal/hidraw/hidrawtest"
+	"dualsense/internal/service/bluetooth"
+	"dualsense/internal/sysfs"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// controllerFS is a fake sysfs tree of js0 with the given bus type and product ID.
+type controllerFS struct {
+	bustype string
+	product string
+	writes  map[string]string
+}
+
+func (f controllerFS) ReadFile(path string) ([]byte, error) {
+	switch path {
+	case "/sys/class/input/js0/device/id/bustype":
+		return []byte(f.bustype + "\n"), nil
+	case "/sys/class/input/js0/device/id/vendor":
+		return []byte("054c\n"), nil
+	case "/sys/class/input/js0/device/id/product":
+		return []byte(f.product + "\n"), nil
+	}
+	return nil, fmt.Errorf("not found: %s", path)
+}
+func (f controllerFS) WriteFile(path string, data []byte, _ os.FileMode) error {
+	f.writes[path] = string(data)
+	return nil
+}
+func (f controllerFS) Glob(pattern string) ([]string, error) {
+	switch pattern {
+	case "/sys/class/input/js0/device/device/hidraw/hidraw*":
+		return []string{"/sys/class/input/js0/device/device/hidraw/hidraw1"}, nil
+	case "/sys/class/input/js0/device/leds/*:red", "/sys/class/input/js0/device/leds/*:green", "/sys/class/input/js0/device/leds/*:blue":
+		return []string{"/sys/class/input/js0/device/leds/input3" + pattern[len("/sys/class/input/js0/device/leds/*"):]}, nil
+	}
+	return nil, nil
+}
+func (f controllerFS) Stat(path string) (os.FileInfo, error) {
+	if path == "/sys/class/input/js0/device/leds" {
+		return nil, nil
+	}
+	return nil, fmt.Errorf("not found: %s", path)
+}
+
+// useController fakes js0 with the given bus type and product ID, and returns its
+// hidraw node and sysfs tree.
+func useController(t *testing.T, bustype, product string) (*hidrawtest.Device, controllerFS) {
+	t.Helper()
+	oldFS, oldBus := sysfs.FS, bluetooth.ConnectSystemBus
+	fs := controllerFS{bustype: bustype, product: product, writes: map[string]string{}}
+	dev := hidrawtest.UseDevice(t)
+	sysfs.FS = fs
+	bluetooth.ConnectSystemBus = func(_ ...dbus.ConnOption) (*dbus.Conn, error) {
+		return nil, fmt.Errorf("no bus available")
+	}
+	t.Cleanup(func() { sysfs.FS, bluetooth.ConnectSystemBus = oldFS, oldBus })
+	return dev, fs
+}
+
+func TestForController(t *testing.T) {
+	tests := []struct {
+		name    string
+		bustype string
+		want    Power
+	}{
+		{"usb", "0003", Sleep{}},
+		{"bluetooth", "0005", fallback{primary: BlueZ{}, secondary: Sleep{}}},
+		{"unknown", "0019", BlueZ{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useController(t, tt.bustype, "0ce6")
+			if got := ForController("/dev/input/js0"); got != tt.want {
+				t.Fatalf("ForController = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSleep(t *testing.T) {
+	dev, _ := useController(t, "0003", "0ce6")
+
+	asleep, err := Sleep{}.PowerOff("/dev/input/js0", "AA:BB:CC:DD:EE:FF")
+	if err != nil || !asleep {
+		t.Fatalf("PowerOff = %v, %v; want asleep", asleep, err)
+	}
+	if err := (Sleep{}).Wake("/dev/input/js0"); err != nil {
+		t.Fatalf("Wake error: %v", err)
+	}
+
+	reports := dev.Reports(t)
+	if len(reports) != 2 {
+		t.Fatalf("expected 2 reports, got %d", len(reports))
+	}
+	sleep := reports[0]
+	if sleep.Flags1 != hidraw.Flag1PowerSave|hidraw.Flag1Lightbar|hidraw.Flag1PlayerIndicator {
+		t.Fatalf("unexpected sleep flags %02x", sleep.Flags1)
+	}
+	if sleep.PowerSave != hidraw.PowerSaveHaptics|hidraw.PowerSaveAudio {
+		t.Fatalf("unexpected power save %02x", sleep.PowerSave)
+	}
+	if sleep.Red|sleep.Green|sleep.Blue|sleep.PlayerLeds|sleep.MotorLeft|sleep.MotorRight != 0 {
+		t.Fatalf("expected LEDs and motors off, got %+v", sleep)
+	}
+	if wake := reports[1]; wake.Flags1 != hidraw.Flag1PowerSave || wake.PowerSave != 0 {
+		t.Fatalf("unexpected wake report %+v", wake)
+	}
+}
+
+func TestSleepDualShock4(t *testing.T) {
+	dev, fs := useController(t, "0003", "09cc")
+
+	asleep, err := Sleep{}.PowerOff("/dev/input/js0", "")
+	if err != nil || !asleep {
+		t.Fatalf("PowerOff = %v, %v; want asleep", asleep, err)
+	}
+	// the DualSense output report is not sent, the lightbar LEDs are turned off
+	if writes := dev.Writes(); len(writes) != 0 {
+		t.Fatalf("unexpected hidraw reports % x", writes)
+	}
+	for _, led := range []string{"red", "green", "blue"} {
+		if got := fs.writes["/sys/class/input/js0/device/leds/input3:"+led+"/brightness"]; got != "0" {
+			t.Fatalf("%s brightness = %q, want 0", led, got)
+		}
+	}
+}
+
+func TestFallbackSleepsWithoutBlueZ(t *testing.T) {
+	dev, _ := useController(t, "0005", "0ce6")
+
+	asleep, err := ForController("/dev/input/js0").PowerOff("/dev/input/js0", "AA:BB:CC:DD:EE:FF")
+	if err != nil || !asleep {
+		t.Fatalf("PowerOff = %v, %v; want asleep", asleep, err)
+	}
+	if n := len(dev.Writes()); n != 1 {
+		t.Fatalf("expected a sleep report, got %d reports", n)
+	}
+}
+
+func TestFallbackDisconnectsThroughBlueZ(t *testing.T) {
+	dev, _ := useController(t, "0005", "0ce6")
+	bus := dbustest.New()
+	bus.Handle("org.freedesktop.DBus.ObjectManager.GetManagedObjects", func(_ *dbus.Message) ([]interface{}, *dbus.Error) {
+		return []interface{}{map[dbus.ObjectPath]map[string]map[string]dbus.Variant{
+			"/org/bluez/hci0/dev_AA_BB_CC_DD_EE_FF": {"org.bluez.Device1": {"Address": dbus.MakeVariant("AA:BB:CC:DD:EE:FF")}},
+		}}, nil
+	})
+	bus.Handle("org.bluez.Device1.Disconnect", func(_ *dbus.Message) ([]interface{}, *dbus.Error) { return nil, nil })
+	bluetooth.ConnectSystemBus = bus.Connect
+
+	asleep, err := ForController("/dev/input/js0").PowerOff("/dev/input/js0", "AA:BB:CC:DD:EE:FF")
+	if err != nil || asleep {
+		t.Fatalf("PowerOff = %v, %v; want disconnected", asleep, err)
+	}
+	if n := len(bus.CallsTo("org.bluez.Device1.Disconnect")); n != 1 {
+		t.Fatalf("expected 1 Disconnect call, got %d", n)
+	}
+	if len(dev.Writes()) != 0 {
+		t.Fatalf("unexpected sleep report over Bluetooth with BlueZ")
+	}
+}
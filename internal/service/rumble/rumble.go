@@ -0,0 +1,102 @@
+// Package rumble drives the DualSense rumble motors through HID output reports.
+package rumble
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"dualsense/internal/hidraw"
+)
+
+var (
+	// Debug enables debug logging within the rumble package.
+	Debug bool
+)
+
+// Step holds both motor intensities (0-255) for a duration.
+type Step struct {
+	Duration time.Duration
+	Left     uint8
+	Right    uint8
+}
+
+// Pattern is a sequence of rumble steps played in order.
+type Pattern []Step
+
+// TestPattern exercises the left motor, the right motor, then both.
+var TestPattern = Pattern{
+	{Duration: 500 * time.Millisecond, Left: 255},
+	{Duration: 200 * time.Millisecond},
+	{Duration: 500 * time.Millisecond, Right: 255},
+	{Duration: 200 * time.Millisecond},
+	{Duration: 500 * time.Millisecond, Left: 255, Right: 255},
+}
+
+// AlertPattern pulses both motors three times, to warn of a critical battery level.
+var AlertPattern = Pattern{
+	{Duration: 300 * time.Millisecond, Left: 255, Right: 255},
+	{Duration: 200 * time.Millisecond},
+	{Duration: 300 * time.Millisecond, Left: 255, Right: 255},
+	{Duration: 200 * time.Millisecond},
+	{Duration: 300 * time.Millisecond, Left: 255, Right: 255},
+}
+
+// wait blocks for d or until ctx is cancelled.
+// It is a package-level variable so tests can play patterns without sleeping.
+var wait = func(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Play plays the pattern on the controller behind jsPath, and stops the motors when
+// the pattern ends or ctx is cancelled. The intensities are capped by the power
+// limit of the controller, see SetPowerLimit.
+func Play(ctx context.Context, jsPath string, p Pattern) error {
+	if Debug {
+		fmt.Printf("Playing %d step rumble pattern on %s\n", len(p), jsPath)
+	}
+	defer func() { _ = setMotors(jsPath, 0, 0) }()
+
+	for _, step := range p {
+		if err := setMotors(jsPath, step.Left, step.Right); err != nil {
+			return err
+		}
+		if err := wait(ctx, step.Duration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetPowerLimit caps the strength of every rumble effect played by the controller,
+// including those sent by games, in 12.5% steps.
+func SetPowerLimit(jsPath string, scale float64) error {
+	return hidraw.Send(jsPath, &hidraw.OutputReport{
+		Flags1:     hidraw.Flag1MotorPower,
+		MotorPower: powerReduction(scale) << 4,
+	})
+}
+
+// powerReduction converts a scale to the controller rumble power reduction (0-7).
+func powerReduction(scale float64) byte {
+	reduction := math.Round((1 - math.Max(0, math.Min(1, scale))) * 8)
+	return byte(math.Min(7, reduction))
+}
+
+func setMotors(jsPath string, left, right uint8) error {
+	return hidraw.Send(jsPath, &hidraw.OutputReport{
+		Flags0:    hidraw.Flag0CompatibleVibration | hidraw.Flag0HapticsSelect,
+		Flags2:    hidraw.Flag2CompatibleVibration2,
+		MotorLeft: left,
+		// The right (small) motor is the high frequency one.
+		MotorRight: right,
+	})
+}
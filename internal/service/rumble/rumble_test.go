@@ -0,0 +1,86 @@
+package rumble
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"dualsense/internal/hidraw"
+	"dualsense/internal/hidraw/hidrawtest"
+)
+
+func TestPlay(t *testing.T) {
+	dev := hidrawtest.Use(t, "0003")
+	var waits []time.Duration
+	oldWait := wait
+	wait = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	defer func() { wait = oldWait }()
+
+	p := Pattern{
+		{Duration: 300 * time.Millisecond, Left: 200},
+		{Duration: 100 * time.Millisecond, Right: 100},
+	}
+	if err := Play(context.Background(), "/dev/input/js0", p); err != nil {
+		t.Fatalf("Play error: %v", err)
+	}
+
+	if len(waits) != 2 || waits[0] != 300*time.Millisecond || waits[1] != 100*time.Millisecond {
+		t.Fatalf("unexpected waits %v", waits)
+	}
+
+	// intensities are sent unscaled, the controller power limit caps them
+	want := [][2]byte{{200, 0}, {0, 100}, {0, 0}}
+	reports := dev.Reports(t)
+	if len(reports) != len(want) {
+		t.Fatalf("expected %d reports, got %d", len(want), len(reports))
+	}
+	for i, r := range reports {
+		if r.Flags0 != hidraw.Flag0CompatibleVibration|hidraw.Flag0HapticsSelect || r.Flags2 != hidraw.Flag2CompatibleVibration2 {
+			t.Fatalf("report %d: unexpected valid flags %02x %02x", i, r.Flags0, r.Flags2)
+		}
+		if r.MotorLeft != want[i][0] || r.MotorRight != want[i][1] {
+			t.Fatalf("report %d: want motors %v got [%d %d]", i, want[i], r.MotorLeft, r.MotorRight)
+		}
+	}
+}
+
+func TestPlayCancelledStopsMotors(t *testing.T) {
+	dev := hidrawtest.Use(t, "0003")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := Play(ctx, "/dev/input/js0", Pattern{{Duration: time.Hour, Left: 255, Right: 255}})
+	if err == nil {
+		t.Fatalf("expected context error")
+	}
+
+	reports := dev.Reports(t)
+	if last := reports[len(reports)-1]; last.MotorLeft != 0 || last.MotorRight != 0 {
+		t.Fatalf("expected motors stopped, got [%d %d]", last.MotorLeft, last.MotorRight)
+	}
+}
+
+func TestSetPowerLimit(t *testing.T) {
+	tests := []struct {
+		scale float64
+		want  byte
+	}{
+		{1, 0x00},
+		{0.5, 0x40},
+		{0.25, 0x60},
+		{0, 0x70},
+	}
+
+	for _, tt := range tests {
+		dev := hidrawtest.Use(t, "0003")
+		if err := SetPowerLimit("/dev/input/js0", tt.scale); err != nil {
+			t.Fatalf("SetPowerLimit error: %v", err)
+		}
+		if r := dev.Reports(t)[0]; r.Flags1 != hidraw.Flag1MotorPower || r.MotorPower != tt.want {
+			t.Errorf("SetPowerLimit(%v): flags %02x power %02x; want power %02x", tt.scale, r.Flags1, r.MotorPower, tt.want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"dualsense/internal/service/battery"
+)
+
+// Tooltip sets the tray icon tooltip. It does nothing until the tray is set up;
+// tests replace it to record the tooltip.
+var Tooltip = func(text string) {}
+
+// trayTooltipTitle is the tooltip shown while no controller is connected.
+const trayTooltipTitle = "DualSense Manager"
+
+// trayLines holds the battery line of each running controller, by controller id.
+var trayLines = struct {
+	sync.Mutex
+	lines map[int]string
+}{lines: make(map[int]string)}
+
+// setTrayLine sets the tooltip line of a controller, or removes it when line is
+// empty, and updates the tooltip when it changed.
+func setTrayLine(id int, line string) {
+	trayLines.Lock()
+	defer trayLines.Unlock()
+
+	if trayLines.lines[id] == line {
+		return
+	}
+	if line == "" {
+		delete(trayLines.lines, id)
+	} else {
+		trayLines.lines[id] = line
+	}
+
+	ids := make([]int, 0, len(trayLines.lines))
+	for id := range trayLines.lines {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	text := []string{trayTooltipTitle}
+	for _, id := range ids {
+		text = append(text, trayLines.lines[id])
+	}
+	Tooltip(strings.Join(text, "\n"))
+}
+
+// batteryLine describes a battery reading and its estimate, e.g.
+// "80%, ~3h 20m remaining", or "80%, discharging" while no estimate is available.
+func batteryLine(level int, status string, estimate battery.Estimate, estimated bool) string {
+	if estimated {
+		return fmt.Sprintf("%d%%, %s", level, estimate)
+	}
+	return fmt.Sprintf("%d%%, %s", level, strings.ToLower(status))
+}
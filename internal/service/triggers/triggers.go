@@ -0,0 +1,172 @@
+// Package triggers encodes and applies DualSense adaptive trigger effects.
+package triggers
+
+import (
+	"fmt"
+	"math"
+
+	"dualsense/internal/config"
+	"dualsense/internal/hidraw"
+)
+
+var (
+	// Debug enables debug logging within the triggers package.
+	Debug bool
+)
+
+// Effect modes accepted in config.TriggerEffect.
+const (
+	ModeOff       = "off"
+	ModeFeedback  = "feedback"
+	ModeWeapon    = "weapon"
+	ModeVibration = "vibration"
+	ModeSlope     = "slope"
+)
+
+// Effect identifiers sent in the first byte of a trigger parameter block.
+const (
+	effectOff       = 0x05
+	effectFeedback  = 0x21
+	effectWeapon    = 0x25
+	effectVibration = 0x26
+)
+
+const zones = 10
+
+// Preset is a named trigger effect offered in the UI.
+type Preset struct {
+	Name   string
+	Effect config.TriggerEffect
+}
+
+// Presets lists the trigger effects offered in the controller tab.
+var Presets = []Preset{
+	{Name: "Off", Effect: config.TriggerEffect{Mode: ModeOff}},
+	{Name: "Feedback", Effect: config.TriggerEffect{Mode: ModeFeedback, Start: 3, Strength: 6}},
+	{Name: "Weapon", Effect: config.TriggerEffect{Mode: ModeWeapon, Start: 3, End: 6, Strength: 8}},
+	{Name: "Vibration", Effect: config.TriggerEffect{Mode: ModeVibration, Start: 2, Strength: 6, Frequency: 30}},
+	{Name: "Slope", Effect: config.TriggerEffect{Mode: ModeSlope, Start: 0, End: 9, Strength: 1, EndStrength: 8}},
+}
+
+// PresetName returns the name of the preset matching the effect, "Off" for an
+// unset effect and "Custom" for parameters edited by hand in the config file.
+func PresetName(e config.TriggerEffect) string {
+	if e.Mode == "" {
+		return Presets[0].Name
+	}
+	for _, p := range Presets {
+		if p.Effect == e {
+			return p.Name
+		}
+	}
+	return "Custom"
+}
+
+// Encode returns the 11-byte trigger parameter block for an effect.
+// Invalid parameters encode as the "off" effect.
+func Encode(e config.TriggerEffect) [11]byte {
+	switch e.Mode {
+	case ModeFeedback:
+		return feedback(e.Start, e.Strength)
+	case ModeWeapon:
+		return weapon(e.Start, e.End, e.Strength)
+	case ModeVibration:
+		return vibration(e.Start, e.Strength, e.Frequency)
+	case ModeSlope:
+		return slope(e.Start, e.End, e.Strength, e.EndStrength)
+	default:
+		return off()
+	}
+}
+
+// Apply sends both trigger effects to the controller behind jsPath.
+func Apply(jsPath string, left, right config.TriggerEffect) error {
+	if Debug {
+		fmt.Printf("Applying trigger effects L2=%+v R2=%+v on %s\n", left, right, jsPath)
+	}
+	return hidraw.Send(jsPath, &hidraw.OutputReport{
+		Flags0:       hidraw.Flag0LeftTrigger | hidraw.Flag0RightTrigger,
+		LeftTrigger:  Encode(left),
+		RightTrigger: Encode(right),
+	})
+}
+
+func off() [11]byte {
+	return [11]byte{effectOff}
+}
+
+// feedback resists with the same strength from the start zone to the end of travel.
+func feedback(start, strength int) [11]byte {
+	if start < 0 || start >= zones || strength <= 0 || strength > 8 {
+		return off()
+	}
+	strengths := make([]int, zones)
+	for i := start; i < zones; i++ {
+		strengths[i] = strength
+	}
+	return zoneFeedback(strengths)
+}
+
+// weapon resists between the start and end zones, then snaps like a trigger break.
+func weapon(start, end, strength int) [11]byte {
+	if start < 2 || start > 7 || end <= start || end > 8 || strength <= 0 || strength > 8 {
+		return off()
+	}
+	startStop := uint16(1)<<start | uint16(1)<<end
+	return [11]byte{effectWeapon, byte(startStop), byte(startStop >> 8), byte(strength - 1)}
+}
+
+// vibration pulses the trigger motor at frequency Hz from the start zone onwards.
+func vibration(start, amplitude, frequency int) [11]byte {
+	if start < 0 || start >= zones || amplitude <= 0 || amplitude > 8 || frequency <= 0 || frequency > 255 {
+		return off()
+	}
+	var active uint16
+	var amplitudes uint32
+	for i := start; i < zones; i++ {
+		amplitudes |= uint32(amplitude-1) << (3 * i)
+		active |= 1 << i
+	}
+	return [11]byte{
+		effectVibration,
+		byte(active), byte(active >> 8),
+		byte(amplitudes), byte(amplitudes >> 8), byte(amplitudes >> 16), byte(amplitudes >> 24),
+		0, 0, byte(frequency), 0,
+	}
+}
+
+// slope ramps resistance linearly from startStrength at the start zone to
+// endStrength at the end zone, holding endStrength for the rest of the travel.
+func slope(start, end, startStrength, endStrength int) [11]byte {
+	if start < 0 || start > 8 || end <= start || end >= zones ||
+		startStrength <= 0 || startStrength > 8 || endStrength <= 0 || endStrength > 8 {
+		return off()
+	}
+	step := float64(endStrength-startStrength) / float64(end-start)
+	strengths := make([]int, zones)
+	for i := start; i < zones; i++ {
+		if i <= end {
+			strengths[i] = int(math.Round(float64(startStrength) + step*float64(i-start)))
+		} else {
+			strengths[i] = endStrength
+		}
+	}
+	return zoneFeedback(strengths)
+}
+
+// zoneFeedback encodes a per-zone resistance profile, 3 bits of strength per zone.
+func zoneFeedback(strengths []int) [11]byte {
+	var active uint16
+	var forces uint32
+	for i, s := range strengths {
+		if s > 0 {
+			forces |= uint32(s-1) << (3 * i)
+			active |= 1 << i
+		}
+	}
+	return [11]byte{
+		effectFeedback,
+		byte(active), byte(active >> 8),
+		byte(forces), byte(forces >> 8), byte(forces >> 16), byte(forces >> 24),
+	}
+}
@@ -0,0 +1,134 @@
+package triggers
+
+import (
+	"testing"
+
+	"dualsense/internal/config"
+	"dualsense/internal/hidraw"
+	"dualsense/internal/hidraw/hidrawtest"
+)
+
+func TestEncode(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		effect config.TriggerEffect
+		want   [11]byte
+	}{
+		{
+			name:   "unset",
+			effect: config.TriggerEffect{},
+			want:   [11]byte{0x05},
+		},
+		{
+			name:   "off",
+			effect: config.TriggerEffect{Mode: ModeOff},
+			want:   [11]byte{0x05},
+		},
+		{
+			name:   "feedback",
+			effect: config.TriggerEffect{Mode: ModeFeedback, Start: 3, Strength: 6},
+			want:   [11]byte{0x21, 0xF8, 0x03, 0x00, 0xDA, 0xB6, 0x2D},
+		},
+		{
+			name:   "feedback full travel",
+			effect: config.TriggerEffect{Mode: ModeFeedback, Start: 0, Strength: 1},
+			want:   [11]byte{0x21, 0xFF, 0x03},
+		},
+		{
+			name:   "weapon",
+			effect: config.TriggerEffect{Mode: ModeWeapon, Start: 3, End: 6, Strength: 8},
+			want:   [11]byte{0x25, 0x48, 0x00, 0x07},
+		},
+		{
+			name:   "weapon end zone 8",
+			effect: config.TriggerEffect{Mode: ModeWeapon, Start: 2, End: 8, Strength: 3},
+			want:   [11]byte{0x25, 0x04, 0x01, 0x02},
+		},
+		{
+			name:   "vibration",
+			effect: config.TriggerEffect{Mode: ModeVibration, Start: 2, Strength: 6, Frequency: 30},
+			want:   [11]byte{0x26, 0xFC, 0x03, 0x40, 0xDB, 0xB6, 0x2D, 0x00, 0x00, 30, 0x00},
+		},
+		{
+			name:   "slope rising",
+			effect: config.TriggerEffect{Mode: ModeSlope, Start: 0, End: 9, Strength: 1, EndStrength: 8},
+			want:   [11]byte{0x21, 0xFF, 0x03, 0x88, 0x34, 0xB6, 0x3E},
+		},
+		{
+			name:   "slope falling then held",
+			effect: config.TriggerEffect{Mode: ModeSlope, Start: 2, End: 5, Strength: 8, EndStrength: 2},
+			want:   [11]byte{0x21, 0xFC, 0x03, 0xC0, 0xBB, 0x24, 0x09},
+		},
+		{
+			name:   "feedback without strength",
+			effect: config.TriggerEffect{Mode: ModeFeedback, Start: 3},
+			want:   [11]byte{0x05},
+		},
+		{
+			name:   "weapon start out of range",
+			effect: config.TriggerEffect{Mode: ModeWeapon, Start: 1, End: 6, Strength: 8},
+			want:   [11]byte{0x05},
+		},
+		{
+			name:   "vibration without frequency",
+			effect: config.TriggerEffect{Mode: ModeVibration, Start: 2, Strength: 6},
+			want:   [11]byte{0x05},
+		},
+		{
+			name:   "slope reversed zones",
+			effect: config.TriggerEffect{Mode: ModeSlope, Start: 6, End: 3, Strength: 1, EndStrength: 8},
+			want:   [11]byte{0x05},
+		},
+		{
+			name:   "unknown mode",
+			effect: config.TriggerEffect{Mode: "galloping", Start: 2, Strength: 6},
+			want:   [11]byte{0x05},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Encode(tt.effect)
+			if got != tt.want {
+				t.Fatalf("Encode(%+v) = % x; want % x", tt.effect, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPresetName(t *testing.T) {
+	for _, p := range Presets {
+		if got := PresetName(p.Effect); got != p.Name {
+			t.Errorf("PresetName(%+v) = %s; want %s", p.Effect, got, p.Name)
+		}
+	}
+	if got := PresetName(config.TriggerEffect{}); got != "Off" {
+		t.Errorf("PresetName(unset) = %s; want Off", got)
+	}
+	if got := PresetName(config.TriggerEffect{Mode: ModeFeedback, Start: 1, Strength: 2}); got != "Custom" {
+		t.Errorf("PresetName(custom) = %s; want Custom", got)
+	}
+}
+
+func TestApply(t *testing.T) {
+	dev := hidrawtest.Use(t, "0005")
+
+	left := config.TriggerEffect{Mode: ModeWeapon, Start: 3, End: 6, Strength: 8}
+	right := config.TriggerEffect{Mode: ModeFeedback, Start: 3, Strength: 6}
+	if err := Apply("/dev/input/js0", left, right); err != nil {
+		t.Fatalf("Apply error: %v", err)
+	}
+
+	reports := dev.Reports(t)
+	if len(reports) != 1 {
+		t.Fatalf("expected 1 report, got %d", len(reports))
+	}
+	report := reports[0]
+	if report.Flags0 != hidraw.Flag0LeftTrigger|hidraw.Flag0RightTrigger {
+		t.Fatalf("unexpected valid flags %02x", report.Flags0)
+	}
+	if report.LeftTrigger != Encode(left) || report.RightTrigger != Encode(right) {
+		t.Fatalf("unexpected trigger blocks L=% x R=% x", report.LeftTrigger, report.RightTrigger)
+	}
+}
@@ -0,0 +1,180 @@
+package ui
+
+import (
+	"context"
+	"dualsense/internal/service/battery"
+	"fmt"
+	"image/color"
+	"log"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	chartWidth   = 480
+	chartHeight  = 160
+	chartMargin  = 40
+	chartRefresh = time.Minute
+	// chartMaxGap is the longest gap between two samples drawn as a line; the
+	// controller was off during longer ones.
+	chartMaxGap = 2 * battery.HeartbeatInterval
+)
+
+var chargingColor = color.NRGBA{R: 0x2E, G: 0xB8, B: 0x4B, A: 0xFF}
+
+var chartPeriods = map[string]time.Duration{
+	"Last 24 hours": 24 * time.Hour,
+	"Last 7 days":   7 * 24 * time.Hour,
+}
+
+// BatteryChart draws the battery level history of a controller, with the periods
+// spent plugged in in green and discharging periods in the theme primary color.
+type BatteryChart struct {
+	Container *fyne.Container
+
+	load    func(since time.Time) ([]battery.Sample, error)
+	period  time.Duration
+	plot    *fyne.Container
+	summary *widget.Label
+	// visible reports whether the chart is shown, it is only refreshed then.
+	visible func() bool
+}
+
+// NewBatteryChart builds a chart of the samples returned by load.
+func NewBatteryChart(load func(since time.Time) ([]battery.Sample, error)) *BatteryChart {
+	c := &BatteryChart{
+		load:    load,
+		period:  chartPeriods["Last 24 hours"],
+		plot:    container.NewWithoutLayout(),
+		summary: widget.NewLabel(""),
+		visible: func() bool { return true },
+	}
+
+	periodSelect := widget.NewSelect([]string{"Last 24 hours", "Last 7 days"}, nil)
+	periodSelect.SetSelected("Last 24 hours")
+	periodSelect.OnChanged = func(selected string) {
+		c.period = chartPeriods[selected]
+		c.reload()
+	}
+
+	legend := container.NewHBox(
+		canvas.NewText("— Charging", chargingColor),
+		canvas.NewText("— Discharging", theme.Color(theme.ColorNamePrimary)),
+	)
+	c.Container = container.NewVBox(
+		container.NewBorder(nil, nil, widget.NewLabel("History :"), nil, periodSelect),
+		container.NewCenter(fixedSize(fyne.NewSize(chartMargin+chartWidth, chartHeight+theme.CaptionTextSize()+4), c.plot)),
+		legend,
+		c.summary,
+	)
+	return c
+}
+
+// Run refreshes the chart while it is visible, until ctx is cancelled.
+func (c *BatteryChart) Run(ctx context.Context) {
+	ticker := time.NewTicker(chartRefresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fyne.Do(func() {
+				if c.visible() {
+					c.reload()
+				}
+			})
+		}
+	}
+}
+
+// reload loads the samples of the selected period in the background, then draws
+// them. It must run on the UI thread.
+func (c *BatteryChart) reload() {
+	period := c.period
+	go func() {
+		now := time.Now()
+		samples, err := c.load(now.Add(-period))
+		if err != nil {
+			log.Default().Println("Error loading battery history:", err)
+		}
+		fyne.Do(func() { c.draw(samples, now, period) })
+	}()
+}
+
+func (c *BatteryChart) draw(samples []battery.Sample, now time.Time, period time.Duration) {
+	start := now.Add(-period)
+	x := func(t time.Time) float32 {
+		return chartMargin + float32(t.Sub(start))/float32(period)*chartWidth
+	}
+	y := func(level int) float32 {
+		return chartHeight - float32(level)/100*chartHeight
+	}
+
+	objects := []fyne.CanvasObject{}
+	for _, level := range []int{0, 50, 100} {
+		grid := canvas.NewLine(outlineColor)
+		grid.Position1 = fyne.NewPos(chartMargin, y(level))
+		grid.Position2 = fyne.NewPos(chartMargin+chartWidth, y(level))
+		label := canvas.NewText(fmt.Sprintf("%d%%", level), theme.Color(theme.ColorNameForeground))
+		label.TextSize = theme.CaptionTextSize()
+		label.Move(fyne.NewPos(0, y(level)-label.TextSize/2))
+		objects = append(objects, grid, label)
+	}
+	for _, mark := range []struct {
+		text string
+		at   float32
+	}{{fmt.Sprintf("-%s", periodName(period)), chartMargin}, {"now", chartMargin + chartWidth - 20}} {
+		label := canvas.NewText(mark.text, theme.Color(theme.ColorNameForeground))
+		label.TextSize = theme.CaptionTextSize()
+		label.Move(fyne.NewPos(mark.at, chartHeight+2))
+		objects = append(objects, label)
+	}
+
+	// Readings are joined by lines, the last one extended to now while the
+	// controller is still reporting.
+	if n := len(samples); n > 0 && now.Sub(samples[n-1].Time) < chartMaxGap {
+		samples = append(samples, battery.Sample{Time: now, Level: samples[n-1].Level, Status: samples[n-1].Status})
+	}
+	var charging, discharging time.Duration
+	for i := 1; i < len(samples); i++ {
+		from, to := samples[i-1], samples[i]
+		gap := to.Time.Sub(from.Time)
+		if gap > chartMaxGap {
+			continue
+		}
+		lineColor := theme.Color(theme.ColorNamePrimary)
+		if from.Status == "Charging" || from.Status == "Full" {
+			lineColor = chargingColor
+			charging += gap
+		} else {
+			discharging += gap
+		}
+		line := canvas.NewLine(lineColor)
+		line.StrokeWidth = 2
+		line.Position1 = fyne.NewPos(x(from.Time), y(from.Level))
+		line.Position2 = fyne.NewPos(x(to.Time), y(to.Level))
+		objects = append(objects, line)
+	}
+
+	c.plot.Objects = objects
+	c.plot.Refresh()
+	if len(samples) == 0 {
+		c.summary.SetText("No battery history yet")
+		return
+	}
+	c.summary.SetText(fmt.Sprintf("Charging : %s, discharging : %s", charging.Truncate(time.Minute), discharging.Truncate(time.Minute)))
+}
+
+func periodName(period time.Duration) string {
+	if period >= 48*time.Hour {
+		return fmt.Sprintf("%dd", int(period.Hours()/24))
+	}
+	return fmt.Sprintf("%dh", int(period.Hours()))
+}
@@ -4,6 +4,10 @@ package ui
 import (
 	"context"
 	"dualsense/internal/config"
+	"dualsense/internal/hidraw"
+	"dualsense/internal/service/battery"
+	"dualsense/internal/service/discovery"
+	"dualsense/internal/service/input"
 	"fmt"
 	"strings"
 	"time"
@@ -21,32 +25,42 @@ type ControllerTab struct {
 	Container    *fyne.Container
 	CancelFunc   context.CancelFunc
 	MacAddress   string
+	// Input is the live state of the controller inputs, drawn by InputView.
+	Input        *input.State
+	InputView    *InputView
+	BatteryChart *BatteryChart
+	InfoPanel    *InfoPanel
 }
 
 // CreateNewControllerTab builds a `ControllerTab` with bindings and UI widgets.
-func CreateNewControllerTab(globalState *GlobalState, path string, conf *config.Config, ctrlConf *config.ControllerConfig, macAddress string, id int) *ControllerTab {
+// The battery chart shows the samples of the controller recorded in history, and
+// the settings shown depend on the controller model and transport. The info panel
+// shows what loadInfo reads from the controller.
+func CreateNewControllerTab(globalState *GlobalState, path string, conf *config.Config, ctrlConf *config.ControllerConfig, macAddress string, id int, model discovery.Model, transport discovery.Transport, history *battery.History, loadInfo func() (hidraw.Info, error)) *ControllerTab {
 	state := &ControllerState{
 		ControllerID:        binding.NewInt(),
 		BatteryValue:        binding.NewFloat(),
+		Estimate:            binding.NewString(),
+		Health:              binding.NewString(),
 		State:               binding.NewString(),
 		LastActivityBinding: binding.NewString(),
+		Connection:          binding.NewString(),
+		Signal:              binding.NewString(),
+		Drift:               binding.NewString(),
 		Mac:                 binding.NewString(),
-		DeadzoneValue:       binding.NewFloat(),
 		LedPlayerPreference: binding.NewInt(),
 		LedRGBPreference:    binding.NewInt(),
 		LedRGBStaticColor:   binding.NewString(),
 		GlobalState:         globalState,
-		Status:              "",
+		Path:                path,
+		Model:               model,
+		Transport:           transport,
 	}
 
 	err := state.ControllerID.Set(id)
 	if err != nil {
 		fmt.Println("Error setting controller ID:", err)
 	}
-	err = state.DeadzoneValue.Set(float64(ctrlConf.Deadzone))
-	if err != nil {
-		fmt.Println("Error setting deadzone value:", err)
-	}
 	err = state.LedRGBPreference.Set(ctrlConf.LedRGBPreference)
 	if err != nil {
 		fmt.Println("Error setting LED RGB preference:", err)
@@ -59,6 +73,14 @@ func CreateNewControllerTab(globalState *GlobalState, path string, conf *config.
 	if err != nil {
 		fmt.Println("Error setting MAC text:", err)
 	}
+	err = state.Signal.Set("Unknown")
+	if err != nil {
+		fmt.Println("Error setting signal text:", err)
+	}
+	err = state.Drift.Set("Analysing…")
+	if err != nil {
+		fmt.Println("Error setting drift text:", err)
+	}
 	// initialize static RGB color binding without leading '#'
 	if ctrlConf.LedRGBStatic != "" {
 		err = state.LedRGBStaticColor.Set(strings.TrimPrefix(ctrlConf.LedRGBStatic, "#"))
@@ -70,12 +92,43 @@ func CreateNewControllerTab(globalState *GlobalState, path string, conf *config.
 	activityChan := make(chan time.Time)
 
 	uiContent := CreateContent(conf, ctrlConf, state)
+	inputState := input.NewState()
+	inputView := NewInputView(inputState, ctrlConf)
+
+	batteryChart := NewBatteryChart(func(since time.Time) ([]battery.Sample, error) {
+		return history.Samples(macAddress, since)
+	})
+	infoPanel := NewInfoPanel(loadInfo)
+
+	inputTab := container.NewTabItem("Input", container.NewVScroll(inputView.Container))
+	batteryTab := container.NewTabItem("Battery", container.NewVScroll(batteryChart.Container))
+	infoTab := container.NewTabItem("Info", container.NewVScroll(infoPanel.Container))
+	views := container.NewAppTabs(container.NewTabItem("Settings", uiContent), inputTab, batteryTab, infoTab)
+	views.OnSelected = func(item *container.TabItem) {
+		switch item {
+		case batteryTab:
+			batteryChart.reload()
+		case infoTab:
+			infoPanel.reload()
+		}
+	}
+	content := container.NewPadded(views)
+	inputView.visible = func() bool {
+		return content.Visible() && views.Selected() == inputTab
+	}
+	batteryChart.visible = func() bool {
+		return content.Visible() && views.Selected() == batteryTab
+	}
 
 	return &ControllerTab{
 		Path:         path,
 		State:        state,
 		ActivityChan: activityChan,
-		Container:    container.NewPadded(uiContent),
+		Container:    content,
 		MacAddress:   macAddress,
+		Input:        inputState,
+		InputView:    inputView,
+		BatteryChart: batteryChart,
+		InfoPanel:    infoPanel,
 	}
 }
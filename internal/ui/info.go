@@ -0,0 +1,95 @@
+package ui
+
+import (
+	"dualsense/internal/hidraw"
+	"errors"
+	"fmt"
+	"log"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// InfoPanel shows the serial, firmware, hardware and factory calibration
+// information read from the feature reports of a controller.
+type InfoPanel struct {
+	Container *fyne.Container
+
+	load   func() (hidraw.Info, error)
+	form   *widget.Form
+	status *widget.Label
+	loaded bool
+}
+
+// NewInfoPanel builds a panel showing the information returned by load.
+func NewInfoPanel(load func() (hidraw.Info, error)) *InfoPanel {
+	p := &InfoPanel{
+		load:   load,
+		form:   widget.NewForm(),
+		status: widget.NewLabel("Reading controller information…"),
+	}
+	refresh := widget.NewButton("Refresh", func() {
+		p.loaded = false
+		p.reload()
+	})
+	p.Container = container.NewVBox(p.status, p.form, container.NewHBox(refresh))
+	return p
+}
+
+// reload reads the information in the background the first time the panel is
+// shown, then fills the form. It must run on the UI thread.
+func (p *InfoPanel) reload() {
+	if p.loaded {
+		return
+	}
+	p.loaded = true
+	go func() {
+		info, err := p.load()
+		if err != nil && !errors.Is(err, hidraw.ErrUnsupported) {
+			log.Default().Println("Error reading controller information:", err)
+		}
+		fyne.Do(func() { p.draw(info, err) })
+	}()
+}
+
+func (p *InfoPanel) draw(info hidraw.Info, err error) {
+	p.form.Items = nil
+	if errors.Is(err, hidraw.ErrUnsupported) {
+		p.status.SetText("Controller information is not supported on this model")
+		p.status.Show()
+		p.form.Refresh()
+		return
+	}
+	if err != nil {
+		p.loaded = false
+		p.status.SetText("Unable to read controller information: " + err.Error())
+		p.status.Show()
+		p.form.Refresh()
+		return
+	}
+	p.status.Hide()
+
+	fw, cal := info.Firmware, info.Calibration
+	rows := []struct{ name, value string }{
+		{"Serial", info.Serial},
+		{"Firmware build date", fw.BuildDate.Format("2006-01-02 15:04:05")},
+		{"Firmware version", fw.FirmwareVersion.String()},
+		{"Update version", fw.UpdateVersion.String()},
+		{"Hardware version", fw.HardwareVersion.String()},
+		{"Firmware type", fmt.Sprintf("%d", fw.FirmwareType)},
+		{"Software series", fmt.Sprintf("%d", fw.SoftwareSeries)},
+		{"Gyro bias (pitch/yaw/roll)", fmt.Sprintf("%d / %d / %d", cal.GyroPitchBias, cal.GyroYawBias, cal.GyroRollBias)},
+		{"Gyro pitch (+/-)", fmt.Sprintf("%d / %d", cal.GyroPitchPlus, cal.GyroPitchMinus)},
+		{"Gyro yaw (+/-)", fmt.Sprintf("%d / %d", cal.GyroYawPlus, cal.GyroYawMinus)},
+		{"Gyro roll (+/-)", fmt.Sprintf("%d / %d", cal.GyroRollPlus, cal.GyroRollMinus)},
+		{"Gyro speed (+/-)", fmt.Sprintf("%d / %d", cal.GyroSpeedPlus, cal.GyroSpeedMinus)},
+		{"Accel X (+/-)", fmt.Sprintf("%d / %d", cal.AccelXPlus, cal.AccelXMinus)},
+		{"Accel Y (+/-)", fmt.Sprintf("%d / %d", cal.AccelYPlus, cal.AccelYMinus)},
+		{"Accel Z (+/-)", fmt.Sprintf("%d / %d", cal.AccelZPlus, cal.AccelZMinus)},
+	}
+	for _, row := range rows {
+		p.form.Append(row.name, widget.NewLabel(row.value))
+	}
+	p.form.Refresh()
+}
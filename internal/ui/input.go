@@ -0,0 +1,294 @@
+package ui
+
+import (
+	"context"
+	"dualsense/internal/config"
+	"dualsense/internal/service/input"
+	"fmt"
+	"image/color"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	stickViewSize    = 140
+	stickDotSize     = 12
+	triggerBarWidth  = 140
+	triggerBarHeight = 14
+	touchpadScale    = 0.1
+	inputRefreshRate = 33 * time.Millisecond
+	axisMax          = 32767
+)
+
+var (
+	deadzoneColor = color.NRGBA{R: 0xFF, G: 0x80, B: 0x00, A: 0x60}
+	outlineColor  = color.NRGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xFF}
+)
+
+// inputButtons lists the buttons shown by the input view, in display order.
+var inputButtons = []struct {
+	label   string
+	pressed func(input.Snapshot) bool
+}{
+	{"Cross", pressedButton(input.BtnSouth)},
+	{"Circle", pressedButton(input.BtnEast)},
+	{"Square", pressedButton(input.BtnWest)},
+	{"Triangle", pressedButton(input.BtnNorth)},
+	{"L1", pressedButton(input.BtnTL)},
+	{"R1", pressedButton(input.BtnTR)},
+	{"L3", pressedButton(input.BtnThumbL)},
+	{"R3", pressedButton(input.BtnThumbR)},
+	{"Create", pressedButton(input.BtnSelect)},
+	{"Options", pressedButton(input.BtnStart)},
+	{"PS", pressedButton(input.BtnMode)},
+	{"Touchpad", pressedButton(input.BtnLeft)},
+	{"Up", func(s input.Snapshot) bool { return s.HatY < 0 }},
+	{"Down", func(s input.Snapshot) bool { return s.HatY > 0 }},
+	{"Left", func(s input.Snapshot) bool { return s.HatX < 0 }},
+	{"Right", func(s input.Snapshot) bool { return s.HatX > 0 }},
+}
+
+func pressedButton(code uint16) func(input.Snapshot) bool {
+	return func(s input.Snapshot) bool { return s.Buttons[code] }
+}
+
+// InputView draws the live state of the controller inputs: both sticks with their
+// deadzone, the triggers with their deadzone, the buttons and the touchpad points.
+type InputView struct {
+	Container *fyne.Container
+
+	state    *input.State
+	ctrlConf *config.ControllerConfig
+	// visible reports whether the view is shown, it is only refreshed then.
+	visible func() bool
+
+	leftStick, rightStick *stickView
+	l2, r2                *triggerView
+	buttons               []*canvas.Rectangle
+	touchpad              *touchpadView
+}
+
+// NewInputView builds the input view of a controller. The deadzones are read from
+// ctrlConf on every refresh, so the deadzone sliders update the overlay live.
+func NewInputView(state *input.State, ctrlConf *config.ControllerConfig) *InputView {
+	v := &InputView{
+		state:      state,
+		ctrlConf:   ctrlConf,
+		visible:    func() bool { return true },
+		leftStick:  newStickView(),
+		rightStick: newStickView(),
+		l2:         newTriggerView(),
+		r2:         newTriggerView(),
+		touchpad:   newTouchpadView(),
+	}
+
+	buttonCells := make([]fyne.CanvasObject, 0, len(inputButtons))
+	for _, button := range inputButtons {
+		background := canvas.NewRectangle(theme.Color(theme.ColorNameButton))
+		background.CornerRadius = 4
+		v.buttons = append(v.buttons, background)
+		buttonCells = append(buttonCells, container.NewStack(background, widget.NewLabelWithStyle(button.label, fyne.TextAlignCenter, fyne.TextStyle{})))
+	}
+
+	v.Container = container.NewVBox(
+		container.NewGridWithColumns(2,
+			container.NewVBox(widget.NewLabel("Left stick :"), container.NewCenter(v.leftStick.Container)),
+			container.NewVBox(widget.NewLabel("Right stick :"), container.NewCenter(v.rightStick.Container)),
+		),
+		container.NewGridWithColumns(2,
+			container.NewBorder(nil, nil, widget.NewLabel("L2 :"), nil, container.NewCenter(v.l2.Container)),
+			container.NewBorder(nil, nil, widget.NewLabel("R2 :"), nil, container.NewCenter(v.r2.Container)),
+		),
+		widget.NewLabel("Buttons :"),
+		container.NewGridWithColumns(4, buttonCells...),
+		widget.NewLabel("Touchpad :"),
+		container.NewCenter(v.touchpad.Container),
+	)
+	v.Refresh()
+	return v
+}
+
+// Run refreshes the view while it is visible, until ctx is cancelled.
+func (v *InputView) Run(ctx context.Context) {
+	ticker := time.NewTicker(inputRefreshRate)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fyne.Do(func() {
+				if v.visible() {
+					v.Refresh()
+				}
+			})
+		}
+	}
+}
+
+// Refresh redraws the view from the current input state. It must run on the UI thread.
+func (v *InputView) Refresh() {
+	snap := v.state.Snapshot()
+	dz := v.ctrlConf.Deadzones
+
+	v.leftStick.update(snap.LeftX, snap.LeftY, dz.LeftStick)
+	v.rightStick.update(snap.RightX, snap.RightY, dz.RightStick)
+	v.l2.update(snap.L2, dz.LeftTrigger)
+	v.r2.update(snap.R2, dz.RightTrigger)
+	for i, button := range inputButtons {
+		fill := theme.Color(theme.ColorNameButton)
+		if button.pressed(snap) {
+			fill = theme.Color(theme.ColorNamePrimary)
+		}
+		if v.buttons[i].FillColor != fill {
+			v.buttons[i].FillColor = fill
+			v.buttons[i].Refresh()
+		}
+	}
+	v.touchpad.update(snap.Touch)
+}
+
+// stickView draws a stick position within its travel, over its deadzone.
+type stickView struct {
+	Container *fyne.Container
+
+	travel      *canvas.Circle
+	radial      *canvas.Circle
+	axialX      *canvas.Rectangle
+	axialY      *canvas.Rectangle
+	dot         *canvas.Circle
+	coordinates *canvas.Text
+}
+
+func newStickView() *stickView {
+	s := &stickView{
+		travel:      canvas.NewCircle(color.Transparent),
+		radial:      canvas.NewCircle(deadzoneColor),
+		axialX:      canvas.NewRectangle(deadzoneColor),
+		axialY:      canvas.NewRectangle(deadzoneColor),
+		dot:         canvas.NewCircle(theme.Color(theme.ColorNamePrimary)),
+		coordinates: canvas.NewText("", theme.Color(theme.ColorNameForeground)),
+	}
+	s.travel.StrokeColor = outlineColor
+	s.travel.StrokeWidth = 2
+	s.travel.Resize(fyne.NewSize(stickViewSize, stickViewSize))
+	s.dot.Resize(fyne.NewSize(stickDotSize, stickDotSize))
+	s.coordinates.TextSize = theme.CaptionTextSize()
+	s.coordinates.Move(fyne.NewPos(0, stickViewSize+2))
+
+	s.Container = fixedSize(fyne.NewSize(stickViewSize, stickViewSize+theme.CaptionTextSize()+4),
+		s.travel, s.radial, s.axialX, s.axialY, s.dot, s.coordinates)
+	return s
+}
+
+func (s *stickView) update(x, y int, deadzone config.StickDeadzone) {
+	const radius = stickViewSize / 2
+	size := float32(deadzone.Size) / axisMax * stickViewSize
+
+	if deadzone.Shape == config.DeadzoneAxial {
+		s.radial.Hide()
+		s.axialX.Resize(fyne.NewSize(size, stickViewSize))
+		s.axialX.Move(fyne.NewPos(radius-size/2, 0))
+		s.axialY.Resize(fyne.NewSize(stickViewSize, size))
+		s.axialY.Move(fyne.NewPos(0, radius-size/2))
+		s.axialX.Show()
+		s.axialY.Show()
+	} else {
+		s.axialX.Hide()
+		s.axialY.Hide()
+		s.radial.Resize(fyne.NewSize(size, size))
+		s.radial.Move(fyne.NewPos(radius-size/2, radius-size/2))
+		s.radial.Show()
+	}
+
+	s.dot.Move(fyne.NewPos(
+		radius+float32(x)/axisMax*radius-stickDotSize/2,
+		radius+float32(y)/axisMax*radius-stickDotSize/2,
+	))
+	s.coordinates.Text = fmt.Sprintf("X %6d  Y %6d", x, y)
+	s.Container.Refresh()
+}
+
+// triggerView draws a trigger pressure as a bar, with a marker at its deadzone.
+type triggerView struct {
+	Container *fyne.Container
+
+	fill     *canvas.Rectangle
+	deadzone *canvas.Rectangle
+}
+
+func newTriggerView() *triggerView {
+	background := canvas.NewRectangle(color.Transparent)
+	background.StrokeColor = outlineColor
+	background.StrokeWidth = 1
+	background.Resize(fyne.NewSize(triggerBarWidth, triggerBarHeight))
+
+	t := &triggerView{
+		fill:     canvas.NewRectangle(theme.Color(theme.ColorNamePrimary)),
+		deadzone: canvas.NewRectangle(deadzoneColor),
+	}
+	t.Container = fixedSize(fyne.NewSize(triggerBarWidth, triggerBarHeight), background, t.fill, t.deadzone)
+	return t
+}
+
+func (t *triggerView) update(value, deadzone int) {
+	t.fill.Resize(fyne.NewSize(float32(value)/axisMax*triggerBarWidth, triggerBarHeight))
+	t.deadzone.Resize(fyne.NewSize(float32(deadzone)/axisMax*triggerBarWidth, triggerBarHeight))
+	t.Container.Refresh()
+}
+
+// touchpadView draws the fingers on the touchpad.
+type touchpadView struct {
+	Container *fyne.Container
+
+	points []*canvas.Circle
+}
+
+func newTouchpadView() *touchpadView {
+	size := fyne.NewSize(input.TouchpadWidth*touchpadScale, input.TouchpadHeight*touchpadScale)
+	surface := canvas.NewRectangle(color.Transparent)
+	surface.StrokeColor = outlineColor
+	surface.StrokeWidth = 2
+	surface.CornerRadius = 6
+	surface.Resize(size)
+
+	t := &touchpadView{}
+	objects := []fyne.CanvasObject{surface}
+	for range len(input.Snapshot{}.Touch) {
+		point := canvas.NewCircle(theme.Color(theme.ColorNamePrimary))
+		point.Resize(fyne.NewSize(stickDotSize, stickDotSize))
+		point.Hide()
+		t.points = append(t.points, point)
+		objects = append(objects, point)
+	}
+	t.Container = fixedSize(size, objects...)
+	return t
+}
+
+func (t *touchpadView) update(touch [2]input.TouchPoint) {
+	for i, point := range touch {
+		if !point.Active {
+			t.points[i].Hide()
+			continue
+		}
+		t.points[i].Move(fyne.NewPos(
+			float32(point.X)*touchpadScale-stickDotSize/2,
+			float32(point.Y)*touchpadScale-stickDotSize/2,
+		))
+		t.points[i].Show()
+	}
+	t.Container.Refresh()
+}
+
+// fixedSize returns a container of the given minimum size whose objects are placed manually.
+func fixedSize(size fyne.Size, objects ...fyne.CanvasObject) *fyne.Container {
+	spacer := canvas.NewRectangle(color.Transparent)
+	spacer.SetMinSize(size)
+	return container.NewStack(spacer, container.NewWithoutLayout(objects...))
+}
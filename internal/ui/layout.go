@@ -2,7 +2,12 @@
 package ui
 
 import (
+	"context"
 	"dualsense/internal/config"
+	"dualsense/internal/service/discovery"
+	"dualsense/internal/service/leds"
+	"dualsense/internal/service/rumble"
+	"dualsense/internal/service/triggers"
 	"fmt"
 	"log"
 	"regexp"
@@ -29,15 +34,21 @@ type GlobalState struct {
 type ControllerState struct {
 	ControllerID        binding.Int
 	BatteryValue        binding.Float
+	Estimate            binding.String
+	Health              binding.String
 	State               binding.String
 	LastActivityBinding binding.String
+	Connection          binding.String
+	Signal              binding.String
+	Drift               binding.String
 	Mac                 binding.String
-	DeadzoneValue       binding.Float
 	LedPlayerPreference binding.Int
 	LedRGBPreference    binding.Int
 	LedRGBStaticColor   binding.String
 	GlobalState         *GlobalState
-	Status              string
+	Path                string
+	Model               discovery.Model
+	Transport           discovery.Transport
 }
 
 // Player and RGB modes used in UI selections.
@@ -45,20 +56,35 @@ const (
 	PlayerModeBattery = 0
 	PlayerModeNumber  = 1
 
-	RGBModeBattery = 0
-	RGBModeStatic  = 1
-	RGBModeOff     = 2
+	RGBModeBattery  = 0
+	RGBModeStatic   = 1
+	RGBModeOff      = 2
+	RGBModeAnimated = 3
 )
 
+var deadzoneShapeOptions = map[string]string{
+	config.DeadzoneRadial: "Radial",
+	config.DeadzoneAxial:  "Axial",
+}
+
+var idleDelayOptions = []string{"Global", "1 min", "2 min", "5 min", "10 min", "20 min", "30 min", "40 min", "Never"}
+
+var idlePolicyOptions = map[string]string{
+	config.IdlePolicyDisconnect: "Disconnect",
+	config.IdlePolicyDim:        "Dim lightbar",
+	config.IdlePolicyNone:       "Do nothing",
+}
+
 var playerOptions = map[int]string{
 	PlayerModeBattery: "Battery level",
 	PlayerModeNumber:  "Player number",
 }
 
 var rgbOptions = map[int]string{
-	RGBModeBattery: "Battery level",
-	RGBModeStatic:  "Static color",
-	RGBModeOff:     "Disable",
+	RGBModeBattery:  "Battery level",
+	RGBModeStatic:   "Static color",
+	RGBModeOff:      "Disable",
+	RGBModeAnimated: "Animated",
 }
 
 // CreateContent builds the controller UI content for a tab.
@@ -75,22 +101,41 @@ func CreateContent(conf *config.Config, ctrlConf *config.ControllerConfig, state
 		controllerID = 0
 	}
 
-	deadzoneLabel, deadzoneSlider := createDeadzoneInput(state, mac, conf, ctrlConf)
+	leftStickLabel, leftStickSlider, leftStickShape := createStickDeadzoneInput(mac, conf, ctrlConf, true)
+	rightStickLabel, rightStickSlider, rightStickShape := createStickDeadzoneInput(mac, conf, ctrlConf, false)
+	leftTriggerLabel, leftTriggerSlider := createTriggerDeadzoneInput(mac, conf, ctrlConf, true)
+	rightTriggerLabel, rightTriggerSlider := createTriggerDeadzoneInput(mac, conf, ctrlConf, false)
 	ledSelect := createPlayerLedSelect(state, mac, conf, ctrlConf)
 	rgbSelect := createRgbLedSelect(state, ctrlConf)
 	staticColorContainer := createStaticColorContainer(state, mac, conf, ctrlConf)
+	animationSelect := createAnimationSelect(mac, conf, ctrlConf)
+	animationRow := container.NewBorder(nil, nil, widget.NewLabel("Animation :"), nil, animationSelect)
+	leftTriggerSelect := createTriggerSelect(state, mac, conf, ctrlConf, true)
+	rightTriggerSelect := createTriggerSelect(state, mac, conf, ctrlConf, false)
+	rumbleLabel, rumbleSlider, rumbleButton := createRumbleInput(state, mac, conf, ctrlConf)
+	idleDelaySelect := createIdleDelaySelect(mac, conf, ctrlConf)
+	idlePolicySelect := createIdlePolicySelect(mac, conf, ctrlConf)
 
 	currentIDRGB, err := state.LedRGBPreference.Get()
 	if err != nil {
 		currentIDRGB = RGBModeBattery
 	}
-	if currentIDRGB == RGBModeStatic {
-		staticColorContainer.Show()
-	} else {
-		staticColorContainer.Hide()
+	// the built-in animations are played with the static color
+	showRGBRows := func(id int) {
+		if id == RGBModeStatic || id == RGBModeAnimated {
+			staticColorContainer.Show()
+		} else {
+			staticColorContainer.Hide()
+		}
+		if id == RGBModeAnimated {
+			animationRow.Show()
+		} else {
+			animationRow.Hide()
+		}
 	}
+	showRGBRows(currentIDRGB)
 
-	// ensure rgbSelect shows or hides the static-color entry when changed
+	// ensure rgbSelect shows or hides the static-color entry and animation select when changed
 	rgbSelect.OnChanged = func(selected string) {
 		for id, name := range rgbOptions {
 			if name == selected {
@@ -102,60 +147,123 @@ func CreateContent(conf *config.Config, ctrlConf *config.ControllerConfig, state
 					ctrlConf.LedRGBPreference = id
 					config.SaveControllerConfig(mac, conf, ctrlConf)
 				}
-				if id == RGBModeStatic {
-					staticColorContainer.Show()
-				} else {
-					staticColorContainer.Hide()
-				}
+				showRGBRows(id)
 				break
 			}
 		}
 	}
+
+	ledRow := container.NewBorder(nil, nil, widget.NewLabel("Player LED :"), nil, ledSelect)
+	leftTriggerRow := container.NewBorder(nil, nil, widget.NewLabel("L2 trigger :"), nil, leftTriggerSelect)
+	rightTriggerRow := container.NewBorder(nil, nil, widget.NewLabel("R2 trigger :"), nil, rightTriggerSelect)
+	rumbleRow := container.NewBorder(nil, nil, nil, rumbleButton, rumbleSlider)
+	// hide the settings the controller model has no hardware or output report for
+	if !state.Model.HasPlayerLeds() {
+		ledRow.Hide()
+	}
+	if !state.Model.HasAdaptiveTriggers() {
+		leftTriggerRow.Hide()
+		rightTriggerRow.Hide()
+	}
+	if state.Model.IsDualShock4() {
+		rumbleLabel.Hide()
+		rumbleRow.Hide()
+	}
+	bluetoothRow := container.NewHBox(widget.NewLabel("Bluetooth :"), widget.NewLabelWithData(state.Connection))
+	signalRow := container.NewHBox(widget.NewLabel("Signal :"), widget.NewLabelWithData(state.Signal))
+	if state.Transport == discovery.TransportUSB {
+		bluetoothRow.Hide()
+		signalRow.Hide()
+	}
 	return container.NewVBox(
 		widget.NewLabel("Controller n°"+strconv.Itoa(controllerID)),
-		widget.NewLabel("Battery :"),
+		container.NewHBox(widget.NewLabel("Battery :"), widget.NewLabelWithData(state.Estimate)),
 		widget.NewProgressBarWithData(state.BatteryValue),
 		container.NewHBox(widget.NewLabel("State :"), widget.NewLabelWithData(state.State)),
+		widget.NewLabel(fmt.Sprintf("Model : %s", state.Model)),
 		widget.NewLabel(fmt.Sprintf("MAC : %s", mac)),
-		container.NewBorder(nil, nil, widget.NewLabel("Player LED :"), nil, ledSelect),
+		widget.NewLabel(fmt.Sprintf("Connection : %s", state.Transport)),
+		bluetoothRow,
+		signalRow,
+		container.NewHBox(widget.NewLabel("Drift :"), widget.NewLabelWithData(state.Drift)),
+		container.NewHBox(widget.NewLabel("Battery health :"), widget.NewLabelWithData(state.Health)),
+		ledRow,
 		container.NewBorder(nil, nil, widget.NewLabel("RGB LED :"), nil, rgbSelect),
+		animationRow,
 		staticColorContainer,
-		deadzoneLabel,
-		deadzoneSlider,
+		leftTriggerRow,
+		rightTriggerRow,
+		rumbleLabel,
+		rumbleRow,
+		leftStickLabel,
+		container.NewBorder(nil, nil, nil, leftStickShape, leftStickSlider),
+		rightStickLabel,
+		container.NewBorder(nil, nil, nil, rightStickShape, rightStickSlider),
+		leftTriggerLabel,
+		leftTriggerSlider,
+		rightTriggerLabel,
+		rightTriggerSlider,
+		container.NewBorder(nil, nil, widget.NewLabel("Auto-off :"), nil, idleDelaySelect),
+		container.NewBorder(nil, nil, widget.NewLabel("When idle :"), nil, idlePolicySelect),
 		widget.NewLabelWithData(state.LastActivityBinding),
 	)
 }
 
-func createDeadzoneInput(state *ControllerState, mac string, conf *config.Config, ctrlConf *config.ControllerConfig) (*widget.Label, *widget.Slider) {
-	deadzoneSlider := widget.NewSliderWithData(0, 10000, state.DeadzoneValue)
-	deadzoneSlider.Step = 250
-	// initialize deadzone label from per-controller config when available,
-	// otherwise keep current state binding value
-	initialDeadzone := 0
-	if ctrlConf != nil {
-		initialDeadzone = ctrlConf.Deadzone
-	} else {
-		if v, err := state.DeadzoneValue.Get(); err == nil {
-			initialDeadzone = int(v)
-		}
+func createStickDeadzoneInput(mac string, conf *config.Config, ctrlConf *config.ControllerConfig, left bool) (*widget.Label, *widget.Slider, *widget.Select) {
+	name, stick := "Right stick", &ctrlConf.Deadzones.RightStick
+	if left {
+		name, stick = "Left stick", &ctrlConf.Deadzones.LeftStick
 	}
-	deadzoneLabel := widget.NewLabel(fmt.Sprintf("Deadzone : %d", initialDeadzone))
+
+	deadzoneLabel := widget.NewLabel(fmt.Sprintf("%s deadzone : %d", name, stick.Size))
+	deadzoneSlider := widget.NewSlider(0, 10000)
+	deadzoneSlider.Step = 250
+	deadzoneSlider.SetValue(float64(stick.Size))
 	deadzoneSlider.OnChanged = func(v float64) {
 		val := int(v)
-		deadzoneLabel.SetText(fmt.Sprintf("Deadzone : %d", val))
+		deadzoneLabel.SetText(fmt.Sprintf("%s deadzone : %d", name, val))
 		// save per-controller if mac known
 		if mac != "" {
-			err := state.DeadzoneValue.Set(v)
-			if err != nil {
-				log.Default().Println("Error setting deadzone value:", err)
+			stick.Size = val
+			config.SaveControllerConfig(mac, conf, ctrlConf)
+		}
+	}
+
+	shapeSelect := widget.NewSelect([]string{deadzoneShapeOptions[config.DeadzoneRadial], deadzoneShapeOptions[config.DeadzoneAxial]}, nil)
+	shapeSelect.SetSelected(deadzoneShapeOptions[stick.Shape])
+	shapeSelect.OnChanged = func(selected string) {
+		for shape, label := range deadzoneShapeOptions {
+			if label == selected && mac != "" {
+				stick.Shape = shape
+				config.SaveControllerConfig(mac, conf, ctrlConf)
+				break
 			}
-			ctrlConf.Deadzone = val
+		}
+	}
+
+	return deadzoneLabel, deadzoneSlider, shapeSelect
+}
+
+func createTriggerDeadzoneInput(mac string, conf *config.Config, ctrlConf *config.ControllerConfig, left bool) (*widget.Label, *widget.Slider) {
+	name, deadzone := "R2", &ctrlConf.Deadzones.RightTrigger
+	if left {
+		name, deadzone = "L2", &ctrlConf.Deadzones.LeftTrigger
+	}
+
+	deadzoneLabel := widget.NewLabel(fmt.Sprintf("%s deadzone : %d", name, *deadzone))
+	deadzoneSlider := widget.NewSlider(0, 10000)
+	deadzoneSlider.Step = 250
+	deadzoneSlider.SetValue(float64(*deadzone))
+	deadzoneSlider.OnChanged = func(v float64) {
+		val := int(v)
+		deadzoneLabel.SetText(fmt.Sprintf("%s deadzone : %d", name, val))
+		if mac != "" {
+			*deadzone = val
 			config.SaveControllerConfig(mac, conf, ctrlConf)
 		}
 	}
 
 	return deadzoneLabel, deadzoneSlider
-
 }
 
 func createPlayerLedSelect(state *ControllerState, mac string, conf *config.Config, ctrlConf *config.ControllerConfig) *widget.Select {
@@ -197,17 +305,13 @@ func createPlayerLedSelect(state *ControllerState, mac string, conf *config.Conf
 
 func createRgbLedSelect(state *ControllerState, ctrlConf *config.ControllerConfig) *widget.Select {
 
-	namesRgb := []string{rgbOptions[0], rgbOptions[1], rgbOptions[2]}
+	namesRgb := []string{rgbOptions[0], rgbOptions[1], rgbOptions[2], rgbOptions[3]}
 
 	rgbSelect := widget.NewSelect(namesRgb, nil)
 
 	// initialize state bindings from controller config only when MAC present
 	if ctrlConf != nil {
-		err := state.DeadzoneValue.Set(float64(ctrlConf.Deadzone))
-		if err != nil {
-			fmt.Println("Error setting deadzone value:", err)
-		}
-		err = state.LedRGBPreference.Set(ctrlConf.LedRGBPreference)
+		err := state.LedRGBPreference.Set(ctrlConf.LedRGBPreference)
 		if err != nil {
 			fmt.Println("Error setting LED RGB preference:", err)
 		}
@@ -288,6 +392,158 @@ func createStaticColorContainer(state *ControllerState, mac string, conf *config
 	return staticColorContainer
 }
 
+func createAnimationSelect(mac string, conf *config.Config, ctrlConf *config.ControllerConfig) *widget.Select {
+
+	animationSelect := widget.NewSelect(leds.AnimationNames(conf.Animations), nil)
+	current := ctrlConf.LedRGBAnimation
+	if current == "" {
+		current = leds.DefaultAnimation
+	}
+	animationSelect.SetSelected(current)
+
+	animationSelect.OnChanged = func(selected string) {
+		ctrlConf.LedRGBAnimation = selected
+		if mac != "" {
+			config.SaveControllerConfig(mac, conf, ctrlConf)
+		}
+	}
+
+	return animationSelect
+}
+
+func createTriggerSelect(state *ControllerState, mac string, conf *config.Config, ctrlConf *config.ControllerConfig, left bool) *widget.Select {
+
+	var names []string
+	for _, preset := range triggers.Presets {
+		names = append(names, preset.Name)
+	}
+
+	current := ctrlConf.TriggerRight
+	if left {
+		current = ctrlConf.TriggerLeft
+	}
+
+	triggerSelect := widget.NewSelect(names, nil)
+	triggerSelect.SetSelected(triggers.PresetName(current))
+
+	triggerSelect.OnChanged = func(selected string) {
+		for _, preset := range triggers.Presets {
+			if preset.Name != selected {
+				continue
+			}
+			if left {
+				ctrlConf.TriggerLeft = preset.Effect
+			} else {
+				ctrlConf.TriggerRight = preset.Effect
+			}
+			if mac != "" {
+				config.SaveControllerConfig(mac, conf, ctrlConf)
+			}
+			err := triggers.Apply(state.Path, ctrlConf.TriggerLeft, ctrlConf.TriggerRight)
+			if err != nil {
+				log.Default().Println("Error applying trigger effects:", err)
+			}
+			break
+		}
+	}
+
+	return triggerSelect
+}
+
+func createIdleDelaySelect(mac string, conf *config.Config, ctrlConf *config.ControllerConfig) *widget.Select {
+
+	idleSelect := widget.NewSelect(idleDelayOptions, nil)
+
+	switch ctrlConf.IdleMinutes {
+	case 0:
+		idleSelect.SetSelected("Global")
+	case config.IdleNever:
+		idleSelect.SetSelected("Never")
+	default:
+		idleSelect.SetSelected(fmt.Sprintf("%d min", ctrlConf.IdleMinutes))
+	}
+
+	idleSelect.OnChanged = func(value string) {
+		switch value {
+		case "Global":
+			ctrlConf.IdleMinutes = 0
+		case "Never":
+			ctrlConf.IdleMinutes = config.IdleNever
+		default:
+			minutes, err := strconv.Atoi(strings.Split(value, " ")[0])
+			if err != nil {
+				log.Default().Printf("Unable to parse delay : %s", value)
+				return
+			}
+			ctrlConf.IdleMinutes = minutes
+		}
+		if mac != "" {
+			config.SaveControllerConfig(mac, conf, ctrlConf)
+		}
+	}
+
+	return idleSelect
+}
+
+func createIdlePolicySelect(mac string, conf *config.Config, ctrlConf *config.ControllerConfig) *widget.Select {
+
+	names := []string{
+		idlePolicyOptions[config.IdlePolicyDisconnect],
+		idlePolicyOptions[config.IdlePolicyDim],
+		idlePolicyOptions[config.IdlePolicyNone],
+	}
+
+	policySelect := widget.NewSelect(names, nil)
+	policySelect.SetSelected(idlePolicyOptions[ctrlConf.IdlePolicy])
+
+	policySelect.OnChanged = func(selected string) {
+		for policy, name := range idlePolicyOptions {
+			if name == selected {
+				ctrlConf.IdlePolicy = policy
+				if mac != "" {
+					config.SaveControllerConfig(mac, conf, ctrlConf)
+				}
+				break
+			}
+		}
+	}
+
+	return policySelect
+}
+
+func createRumbleInput(state *ControllerState, mac string, conf *config.Config, ctrlConf *config.ControllerConfig) (*widget.Label, *widget.Slider, *widget.Button) {
+	rumbleLabel := widget.NewLabel(fmt.Sprintf("Rumble strength : %.0f %%", ctrlConf.RumbleScale*100))
+
+	rumbleSlider := widget.NewSlider(0.1, 1)
+	rumbleSlider.Step = 0.1
+	rumbleSlider.SetValue(ctrlConf.RumbleScale)
+	rumbleSlider.OnChanged = func(v float64) {
+		rumbleLabel.SetText(fmt.Sprintf("Rumble strength : %.0f %%", v*100))
+		ctrlConf.RumbleScale = v
+		if mac != "" {
+			config.SaveControllerConfig(mac, conf, ctrlConf)
+		}
+		err := rumble.SetPowerLimit(state.Path, v)
+		if err != nil {
+			log.Default().Println("Error applying rumble limit:", err)
+		}
+	}
+
+	var rumbleButton *widget.Button
+	rumbleButton = widget.NewButton("Test rumble", func() {
+		rumbleButton.Disable()
+		go func() {
+			err := rumble.Play(context.Background(), state.Path, rumble.TestPattern)
+			if err != nil {
+				log.Default().Println("Error playing rumble test:", err)
+			}
+			fyne.Do(rumbleButton.Enable)
+		}()
+	})
+
+	return rumbleLabel, rumbleSlider, rumbleButton
+}
+
 func CreateBatteryWidget(globalState *GlobalState, conf *config.Config) *widget.Select {
 	optionsBattery := []string{"5 %", "15 %", "25 %", "Never"}
 
@@ -315,6 +571,10 @@ func CreateBatteryWidget(globalState *GlobalState, conf *config.Config) *widget.
 	} else {
 		selectBatteryWidget.SetSelected(fmt.Sprintf("%d %%", conf.BatteryAlert))
 	}
+	// the thresholds listed in the config file replace the single one
+	if len(conf.BatteryAlerts) > 0 {
+		selectBatteryWidget.Disable()
+	}
 	return selectBatteryWidget
 
 }
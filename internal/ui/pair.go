@@ -0,0 +1,55 @@
+package ui
+
+import (
+	"context"
+	"dualsense/internal/service/bluetooth"
+	"log"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/data/binding"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// PairTimeout is how long the pairing dialog searches for a controller in pairing mode.
+const PairTimeout = 60 * time.Second
+
+// ShowPairDialog searches for a DualSense in pairing mode and pairs, trusts and connects it,
+// showing progress in a dialog. Closing the dialog cancels the search.
+func ShowPairDialog(win fyne.Window) {
+	status := binding.NewString()
+	setStatus := func(msg string) {
+		err := status.Set(msg)
+		if err != nil {
+			log.Default().Println("Error setting pairing status:", err)
+		}
+	}
+	setStatus("Connecting to BlueZ…")
+
+	progress := widget.NewProgressBarInfinite()
+	label := widget.NewLabelWithData(status)
+	label.Wrapping = fyne.TextWrapWord
+
+	ctx, cancel := context.WithTimeout(context.Background(), PairTimeout)
+	d := dialog.NewCustom("Pair new controller", "Cancel", container.NewVBox(label, progress), win)
+	d.SetOnClosed(cancel)
+	d.Resize(fyne.NewSize(280, 0))
+	d.Show()
+
+	go func() {
+		mac, err := bluetooth.PairNewController(ctx, setStatus)
+		if err != nil {
+			log.Default().Println("Pairing failed:", err)
+			setStatus("Pairing failed: " + err.Error())
+		} else {
+			log.Default().Println("Paired controller", mac)
+		}
+		fyne.Do(func() {
+			progress.Stop()
+			progress.Hide()
+			d.SetDismissText("Close")
+		})
+	}()
+}
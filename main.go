@@ -4,7 +4,13 @@ package main
 import (
 	"dualsense/internal/config"
 	"dualsense/internal/service"
+	"dualsense/internal/service/audio"
+	"dualsense/internal/service/battery"
+	"dualsense/internal/service/discovery"
 	"dualsense/internal/service/leds"
+	"dualsense/internal/service/power"
+	"dualsense/internal/service/rumble"
+	"dualsense/internal/service/triggers"
 	"dualsense/internal/ui"
 	"fmt"
 	"log"
@@ -16,6 +22,7 @@ import (
 	"fyne.io/fyne/v2/driver/desktop"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
+	"fyne.io/systray"
 
 	"github.com/spf13/cobra"
 )
@@ -35,6 +42,23 @@ func main() {
 	versionPtr := rootCmd.PersistentFlags().BoolP("version", "v", false, "Show version information")
 	cliPtr := rootCmd.PersistentFlags().BoolP("cli", "c", false, "Run in CLI mode without UI")
 
+	rootCmd.PersistentPreRun = func(_ *cobra.Command, _ []string) {
+		service.Debug = *debugPtr
+		leds.Debug = *debugPtr
+		discovery.Debug = *debugPtr
+		triggers.Debug = *debugPtr
+		rumble.Debug = *debugPtr
+		battery.Debug = *debugPtr
+		power.Debug = *debugPtr
+		audio.Debug = *debugPtr
+	}
+
+	rootCmd.AddCommand(newRumbleCmd())
+	rootCmd.AddCommand(newPairCmd())
+	rootCmd.AddCommand(newCalibrateCmd())
+	rootCmd.AddCommand(newInfoCmd())
+	rootCmd.AddCommand(newBatteryCmd())
+
 	rootCmd.Run = func(_ *cobra.Command, _ []string) {
 
 		if *versionPtr {
@@ -47,28 +71,29 @@ func main() {
 			log.Fatalf("Error loading configuration: %s\n", err)
 			return
 		}
+		battery.UseSource(conf.BatterySource)
+		if conf.AnimationFPS > 0 {
+			leds.FrameRate = conf.AnimationFPS
+		}
 		myApp := app.NewWithID("com.dualsense.manager")
 		myWindow := myApp.NewWindow("DualSense Manager")
 
 		myApp.SetIcon(resourceIconPng)
 		myWindow.SetIcon(resourceIconPng)
 
-		if desk, ok := myApp.(desktop.App); ok {
-			menu := fyne.NewMenu("DualSense",
-				fyne.NewMenuItem("Display", func() { myWindow.Show() }),
-				fyne.NewMenuItem("Quit", func() { myApp.Quit() }),
-			)
-			desk.SetSystemTrayMenu(menu)
+		desk, hasTray := myApp.(desktop.App)
+		if hasTray {
+			desk.SetSystemTrayMenu(trayMenu(myApp, myWindow, conf, nil))
 			desk.SetSystemTrayIcon(resourceIconPng)
+			// fyne has no tooltip API, its tray is the systray singleton
+			systray.SetTooltip("DualSense Manager")
+			service.Tooltip = systray.SetTooltip
 		}
 
 		myWindow.SetCloseIntercept(func() {
 			myWindow.Hide()
 		})
 
-		service.Debug = *debugPtr
-		leds.Debug = *debugPtr
-
 		globalState := &ui.GlobalState{
 			DelayIdleMinutes: conf.IdleMinutes,
 			BatteryAlert:     conf.BatteryAlert,
@@ -80,7 +105,11 @@ func main() {
 
 		} else {
 
-			controllerTabs := service.StartControllerManager(globalState, conf)
+			controllerTabs := service.StartControllerManager(globalState, conf, func(connected []*service.ControllerLoop) {
+				if hasTray {
+					desk.SetSystemTrayMenu(trayMenu(myApp, myWindow, conf, connected))
+				}
+			})
 
 			selectBatteryWidget := ui.CreateBatteryWidget(globalState, conf)
 			selectDelayWidget := ui.CreateDelayIdleSelect(globalState, conf)
@@ -92,6 +121,7 @@ func main() {
 				thickSeparator,
 				container.NewBorder(nil, nil, widget.NewLabel("Battery alert :"), nil, selectBatteryWidget),
 				container.NewBorder(nil, nil, widget.NewLabel("Delay :"), nil, selectDelayWidget),
+				widget.NewButton("Pair new controller", func() { ui.ShowPairDialog(myWindow) }),
 			)
 
 			appContainer := container.NewBorder(nil, bottomControls, nil, nil, container.NewStack(controllerTabs))
@@ -111,3 +141,43 @@ func main() {
 		log.Fatalf("Error executing command: %s\n", err)
 	}
 }
+
+// trayMenu builds the system tray menu, listing the known controllers with connect/disconnect toggles.
+func trayMenu(myApp fyne.App, myWindow fyne.Window, conf *config.Config, connected []*service.ControllerLoop) *fyne.Menu {
+	items := []*fyne.MenuItem{
+		fyne.NewMenuItem("Display", func() { myWindow.Show() }),
+		fyne.NewMenuItem("Pair new controller", func() {
+			myWindow.Show()
+			ui.ShowPairDialog(myWindow)
+		}),
+		fyne.NewMenuItemSeparator(),
+	}
+
+	for _, entry := range service.ControllerEntries(conf, connected) {
+		item := fyne.NewMenuItem(entry.Label(), func() {
+			go func() {
+				if err := service.ToggleConnection(entry); err != nil {
+					log.Default().Println("Error toggling connection of", entry.MAC, ":", err)
+				}
+			}()
+		})
+		item.Checked = entry.Connected
+		items = append(items, item)
+	}
+
+	disconnectAll := fyne.NewMenuItem("Disconnect all controllers", func() {
+		go func() {
+			if err := service.DisconnectAll(connected); err != nil {
+				log.Default().Println("Error disconnecting controllers:", err)
+			}
+		}()
+	})
+	disconnectAll.Disabled = len(connected) == 0
+
+	items = append(items,
+		disconnectAll,
+		fyne.NewMenuItemSeparator(),
+		fyne.NewMenuItem("Quit", func() { myApp.Quit() }),
+	)
+	return fyne.NewMenu("DualSense", items...)
+}